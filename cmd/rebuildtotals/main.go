@@ -0,0 +1,40 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"log"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/apiserver"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store/sqlstore"
+	"github.com/BurntSushi/toml"
+)
+
+var (
+	configPath string
+)
+
+func init() {
+	flag.StringVar(&configPath, "config-path", "configs/apiserver.toml",
+		"Config path for APIServer configuration")
+}
+
+// rebuildtotals recomputes per-account daily totals used by summaries
+// from the transactions table.
+func main() {
+	flag.Parse()
+	config := apiserver.NewConfig()
+	if _, err := toml.DecodeFile(configPath, config); err != nil {
+		log.Fatal(err)
+	}
+	db, err := sql.Open("postgres", config.DatabaseURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	count, err := sqlstore.New(db).RebuildDailyTotals()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("rebuilt %d daily totals", count)
+}
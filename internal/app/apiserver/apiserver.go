@@ -3,12 +3,20 @@ package apiserver
 import (
 	"database/sql"
 	"net/http"
+	"time"
 
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store/eventstore"
 	"github.com/Aza-9798/costs-rest-api/internal/app/store/sqlstore"
+	"github.com/Aza-9798/costs-rest-api/internal/app/webhook"
+	"github.com/google/uuid"
 	"github.com/gorilla/sessions"
 )
 
 func Start(config *Config) error {
+	if err := model.SetPasswordPolicy(config.passwordPolicy()); err != nil {
+		return err
+	}
 	db, err := newDB(config.DatabaseURL)
 	if err != nil {
 		return err
@@ -21,6 +29,26 @@ func Start(config *Config) error {
 	if err != nil {
 		return err
 	}
+	if config.LegacySunset != "" {
+		srv.legacySunset, err = time.Parse("2006-01-02", config.LegacySunset)
+		if err != nil {
+			return err
+		}
+	}
+	if config.TrashRetentionDays > 0 {
+		go srv.purgeTrash(time.Duration(config.TrashRetentionDays)*24*time.Hour, time.Hour)
+	}
+	if config.IdempotencyKeyTTLHours > 0 {
+		go srv.purgeIdempotencyKeys(time.Duration(config.IdempotencyKeyTTLHours)*time.Hour, time.Hour)
+	}
+
+	if config.InterestAccrualHours > 0 {
+		go srv.accrueInterest(time.Duration(config.InterestAccrualHours) * time.Hour)
+	}
+
+	if config.WebhookIntervalSeconds > 0 {
+		go webhook.NewWorker(store, srv.logger).Run(time.Duration(config.WebhookIntervalSeconds) * time.Second)
+	}
 
 	return http.ListenAndServe(config.BindAddr, srv)
 }
@@ -37,3 +65,57 @@ func newDB(databaseURL string) (*sql.DB, error) {
 
 	return db, nil
 }
+
+// purgeTrash periodically removes transactions that stayed in the trash longer than retention.
+func (s *server) purgeTrash(retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for ; true; <-ticker.C {
+		count, err := s.store.Transaction().PurgeTrash(time.Now().Add(-retention))
+		if err != nil {
+			s.logger.Errorf("purge trash: %v", err)
+			continue
+		}
+		if count > 0 {
+			s.logger.Infof("purged %d transactions from trash", count)
+		}
+	}
+}
+
+// purgeIdempotencyKeys periodically forgets responses older than ttl.
+func (s *server) purgeIdempotencyKeys(ttl, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for ; true; <-ticker.C {
+		if _, err := s.store.IdempotencyKey().Purge(time.Now().Add(-ttl)); err != nil {
+			s.logger.Errorf("purge idempotency keys: %v", err)
+		}
+	}
+}
+
+// accrueInterest periodically charges Debt accounts interest for the months
+// that ended since they were last charged. Each run is audited as one request
+// of model.SystemActor and publishes events like the handlers do.
+func (s *server) accrueInterest(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for ; true; <-ticker.C {
+		st := eventstore.New(s.store.WithActor(model.SystemActor, uuid.New().String()), s.broker)
+		through := model.TruncateDate(time.Now(), model.IntervalMonth).AddDate(0, 0, -1)
+		ids, err := st.Account().GetInterestDue(through)
+		if err != nil {
+			s.logger.Errorf("accrue interest: %v", err)
+			continue
+		}
+		for _, id := range ids {
+			ts, err := st.Transaction().AccrueInterest(id, through)
+			if err != nil {
+				s.logger.Errorf("accrue interest on account %d: %v", id, err)
+				continue
+			}
+			if len(ts) > 0 {
+				s.logger.Infof("accrued interest on account %d for %d months", id, len(ts))
+			}
+		}
+	}
+}
@@ -1,15 +1,58 @@
 package apiserver
 
+import "github.com/Aza-9798/costs-rest-api/internal/app/model"
+
 type Config struct {
-	BindAddr    string `toml:"bind_addr"`
-	LogLevel    string `toml:"log_level"`
-	DatabaseURL string `toml:"database_url"`
-	SessionKey  string `toml:"session_key"`
+	BindAddr      string `toml:"bind_addr"`
+	LogLevel      string `toml:"log_level"`
+	DatabaseURL   string `toml:"database_url"`
+	SessionKey    string `toml:"session_key"`
+	PasswordHash  string `toml:"password_hash"`
+	BcryptCost    int    `toml:"bcrypt_cost"`
+	Argon2Time    uint32 `toml:"argon2_time"`
+	Argon2Memory  uint32 `toml:"argon2_memory"`
+	Argon2Threads uint8  `toml:"argon2_threads"`
+	// TrashRetentionDays is how long deleted transactions can be restored.
+	// Zero keeps them forever.
+	TrashRetentionDays int `toml:"trash_retention_days"`
+	// IdempotencyKeyTTLHours is how long responses to requests
+	// with an Idempotency-Key header are kept for replays.
+	IdempotencyKeyTTLHours int `toml:"idempotency_key_ttl_hours"`
+	// WebhookIntervalSeconds is how often queued webhook deliveries are sent.
+	// Zero disables sending.
+	WebhookIntervalSeconds int `toml:"webhook_interval_seconds"`
+	// InterestAccrualHours is how often Debt accounts are checked
+	// for interest due for the past month. Zero disables accrual.
+	InterestAccrualHours int `toml:"interest_accrual_hours"`
+	// LegacySunset is the date (YYYY-MM-DD) announced in the Sunset header
+	// of the unversioned routes. Empty keeps the default date.
+	LegacySunset string `toml:"legacy_sunset"`
 }
 
 func NewConfig() *Config {
+	p := model.DefaultPasswordPolicy()
 	return &Config{
-		BindAddr: ":8080",
-		LogLevel: "debug",
+		BindAddr:      ":8080",
+		LogLevel:      "debug",
+		PasswordHash:  p.Algorithm,
+		BcryptCost:    p.BcryptCost,
+		Argon2Time:    p.Argon2Time,
+		Argon2Memory:  p.Argon2Memory,
+		Argon2Threads: p.Argon2Threads,
+
+		TrashRetentionDays:     30,
+		IdempotencyKeyTTLHours: 24,
+		WebhookIntervalSeconds: 5,
+		InterestAccrualHours:   1,
+	}
+}
+
+func (c *Config) passwordPolicy() *model.PasswordPolicy {
+	return &model.PasswordPolicy{
+		Algorithm:     c.PasswordHash,
+		BcryptCost:    c.BcryptCost,
+		Argon2Time:    c.Argon2Time,
+		Argon2Memory:  c.Argon2Memory,
+		Argon2Threads: c.Argon2Threads,
 	}
 }
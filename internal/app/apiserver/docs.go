@@ -0,0 +1,52 @@
+package apiserver
+
+import (
+	"embed"
+	"mime"
+	"net/http"
+	"path"
+
+	"github.com/gorilla/mux"
+)
+
+//go:embed docs/openapi.json docs/index.html docs/swagger-ui
+var docs embed.FS
+
+// handleOpenAPI serves the OpenAPI document describing every route of the router.
+func (s *server) handleOpenAPI() http.HandlerFunc {
+	return s.serveDoc("docs/openapi.json", "application/json")
+}
+
+// handleDocs serves Swagger UI for the OpenAPI document.
+func (s *server) handleDocs() http.HandlerFunc {
+	return s.serveDoc("docs/index.html", "text/html; charset=utf-8")
+}
+
+// handleDocAsset serves the Swagger UI scripts and styles embedded in the binary,
+// so the docs page does not depend on a CDN.
+func (s *server) handleDocAsset() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["file"]
+		b, err := docs.ReadFile(path.Join("docs/swagger-ui", name))
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+		w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(name)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
+
+func (s *server) serveDoc(name, contentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b, err := docs.ReadFile(name)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
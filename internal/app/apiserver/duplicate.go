@@ -0,0 +1,103 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/gorilla/mux"
+)
+
+func (s *server) handleDuplicateGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		res, err := s.store.Duplicate().GetPending(u.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, res)
+	}
+}
+
+// handleDuplicateMerge keeps one transaction of the pair and moves the other
+// to the trash, which reverts its effect on the balances. The earlier
+// transaction is kept unless keep names the other one. A pair resolved
+// meanwhile is left as is and reported with 409.
+func (s *server) handleDuplicateMerge() http.HandlerFunc {
+	type request struct {
+		Keep int `json:"keep"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &request{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil && err != io.EOF {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		d, err := s.findDuplicate(r)
+		if err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		drop := d.Transaction
+		switch req.Keep {
+		case 0, d.DuplicateOf.ID:
+		case d.Transaction.ID:
+			drop = d.DuplicateOf
+		default:
+			s.error(w, r, http.StatusUnprocessableEntity, errInvalidKeep)
+			return
+		}
+		if err := s.storeFor(r).Duplicate().Merge(d.ID, drop.ID); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respondDuplicate(w, r, d.ID)
+	}
+}
+
+// handleDuplicateDismiss marks the pair as not duplicates,
+// so it is not reported again.
+func (s *server) handleDuplicateDismiss() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		d, err := s.findDuplicate(r)
+		if err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		s.resolveDuplicate(w, r, d.ID, model.DuplicateDismissed)
+	}
+}
+
+func (s *server) resolveDuplicate(w http.ResponseWriter, r *http.Request, id int, status string) {
+	if err := s.store.Duplicate().Resolve(id, status); err != nil {
+		s.error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	s.respondDuplicate(w, r, id)
+}
+
+func (s *server) respondDuplicate(w http.ResponseWriter, r *http.Request, id int) {
+	d, err := s.store.Duplicate().Find(id)
+	if err != nil {
+		s.authorizationError(w, r, err)
+		return
+	}
+	s.respond(w, r, http.StatusOK, d)
+}
+
+// findDuplicate loads the pair from the route. Both transactions
+// move money between the same accounts, the user must be able to edit them.
+func (s *server) findDuplicate(r *http.Request) (*model.DuplicateCandidate, error) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	d, err := s.store.Duplicate().Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorizeAccounts(r, model.EditorRole, d.Transaction.Source, d.Transaction.Destination); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
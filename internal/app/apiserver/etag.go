@@ -0,0 +1,33 @@
+package apiserver
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// setETag exposes the resource version as a strong ETag.
+func setETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", strconv.Quote(strconv.Itoa(version)))
+}
+
+// parseIfMatch returns the version the client expects to overwrite.
+// "*" matches any version, in which case current is returned.
+func parseIfMatch(r *http.Request, current int) (int, error) {
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" {
+		return 0, errPreconditionRequired
+	}
+	if v == "*" {
+		return current, nil
+	}
+	unquoted, err := strconv.Unquote(v)
+	if err != nil {
+		return 0, errInvalidIfMatch
+	}
+	version, err := strconv.Atoi(unquoted)
+	if err != nil {
+		return 0, errInvalidIfMatch
+	}
+	return version, nil
+}
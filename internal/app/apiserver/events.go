@@ -0,0 +1,53 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+)
+
+var errStreamingUnsupported = errors.New("streaming is not supported")
+
+// handleEvents streams changes visible to the user as Server-Sent Events.
+func (s *server) handleEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			s.error(w, r, http.StatusInternalServerError, errStreamingUnsupported)
+			return
+		}
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		ch, cancel := s.broker.Subscribe(u.ID)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		ping := time.NewTicker(eventStreamPing)
+		defer ping.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ping.C:
+				//комментарий не дает прокси закрыть простаивающее соединение
+				fmt.Fprint(w, ": ping\n\n")
+			case e := <-ch:
+				data, err := json.Marshal(e.Data)
+				if err != nil {
+					s.logger.Errorf("encode event %s: %v", e.Type, err)
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/gorilla/mux"
+)
+
+func (s *server) handleGoalGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		res, err := s.store.Goal().GetAllByUser(u.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, res)
+	}
+}
+
+func (s *server) handleGoalCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g := &model.Goal{}
+		if err := json.NewDecoder(r.Body).Decode(g); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if err := s.authorizeAccount(r, g.Account, model.EditorRole); err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		a, err := s.store.Account().Find(g.Account)
+		if err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		if a.Type != model.SavingAccount {
+			s.error(w, r, http.StatusUnprocessableEntity, model.ErrNotSavingAccount)
+			return
+		}
+		g.TargetDate = model.TruncateDate(g.TargetDate, model.IntervalDay)
+		if err := s.store.Goal().Create(g); err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+		s.respond(w, r, http.StatusCreated, g)
+	}
+}
+
+func (s *server) handleGoalGet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g, err := s.findGoal(r, model.ViewerRole)
+		if err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, g)
+	}
+}
+
+// handleGoalUpdate changes the goal. The account it is set on stays the same.
+func (s *server) handleGoalUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g, err := s.findGoal(r, model.EditorRole)
+		if err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		req := &model.Goal{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		req.ID = g.ID
+		req.Account = g.Account
+		req.CreatedAt = g.CreatedAt
+		req.TargetDate = model.TruncateDate(req.TargetDate, model.IntervalDay)
+		if err := s.store.Goal().Save(req); err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, req)
+	}
+}
+
+func (s *server) handleGoalDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g, err := s.findGoal(r, model.EditorRole)
+		if err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		if err := s.store.Goal().Delete(g.ID); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, nil)
+	}
+}
+
+func (s *server) handleGoalProgress() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g, err := s.findGoal(r, model.ViewerRole)
+		if err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		a, err := s.store.Account().Find(g.Account)
+		if err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		today := time.Now()
+		ts, err := s.store.Transaction().GetAllByAccountAndPeriod(a.ID, model.GoalHistoryStart(today), today)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, model.NewGoalProgress(g, a, ts, today))
+	}
+}
+
+// findGoal loads the goal from the route, checking the role on its account.
+func (s *server) findGoal(r *http.Request, required string) (*model.Goal, error) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	g, err := s.store.Goal().Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorizeAccount(r, g.Account, required); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
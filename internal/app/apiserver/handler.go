@@ -57,6 +57,9 @@ func (s *server) handleSessionCreate() http.HandlerFunc {
 			s.error(w, r, http.StatusUnauthorized, errIncorrectEmailOrPassword)
 			return
 		}
+		if u.NeedsRehash() {
+			s.rehashPassword(u, req.Password)
+		}
 		session, err := s.sessionStore.Get(r, sessionName)
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
@@ -71,21 +74,41 @@ func (s *server) handleSessionCreate() http.HandlerFunc {
 	}
 }
 
+// rehashPassword upgrades the stored hash to the current password policy.
+// The login has already succeeded, so failures are only logged.
+func (s *server) rehashPassword(u *model.User, password string) {
+	u.Password = password
+	defer u.Sanitize()
+	if err := u.BeforeCreate(); err != nil {
+		s.logger.Warnf("rehash password for user %d: %v", u.ID, err)
+		return
+	}
+	if err := s.store.User().Save(u); err != nil {
+		s.logger.Warnf("rehash password for user %d: %v", u.ID, err)
+	}
+}
+
 func (s *server) handleAccountGet() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		accountId, _ := strconv.Atoi(mux.Vars(r)["id"])
+		if err := s.authorizeAccount(r, accountId, model.ViewerRole); err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
 		a, err := s.store.Account().Find(accountId)
 		if err != nil {
 			s.error(w, r, http.StatusBadRequest, err)
 			return
 		}
+		setETag(w, a.Version)
 		s.respond(w, r, http.StatusOK, a)
 	}
 }
 func (s *server) handleAccountGetAll() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		u := r.Context().Value(ctxKeyUser).(*model.User)
-		res, err := s.store.Account().GetAllByUser(u.ID)
+		withArchived := r.URL.Query().Get("archived") == "true"
+		res, err := s.store.Account().GetAllByUser(u.ID, withArchived)
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
 		} else {
@@ -94,12 +117,40 @@ func (s *server) handleAccountGetAll() http.HandlerFunc {
 	}
 }
 
+func (s *server) handleAccountTree() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dateStart, dateEnd, err := parsePeriod(r)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		accounts, err := s.store.Account().GetAllByUser(u.ID, true)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		totals, err := s.store.Transaction().GetCategoryTotals(u.ID, dateStart, dateEnd)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, model.BuildCategoryTree(accounts, totals))
+	}
+}
+
 func (s *server) handleAccountCreate() http.HandlerFunc {
 	type request struct {
 		Name        string  `json:"name"`
 		Type        string  `json:"type"`
 		Description string  `json:"description"`
 		Balance     float64 `json:"balance"`
+		Parent      *int    `json:"parent"`
+
+		CreditLimit    float64 `json:"credit_limit"`
+		APR            float64 `json:"apr"`
+		MinimumPayment float64 `json:"minimum_payment"`
+		DueDay         int     `json:"due_day"`
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		req := &request{}
@@ -109,13 +160,18 @@ func (s *server) handleAccountCreate() http.HandlerFunc {
 		}
 		u := r.Context().Value(ctxKeyUser).(*model.User)
 		acc := &model.Account{
-			Name:        req.Name,
-			Type:        req.Type,
-			Description: req.Description,
-			Balance:     req.Balance,
-			User:        u.ID,
-		}
-		if err := s.store.Account().Create(acc); err != nil {
+			Name:           req.Name,
+			Type:           req.Type,
+			Description:    req.Description,
+			Balance:        req.Balance,
+			User:           u.ID,
+			Parent:         req.Parent,
+			CreditLimit:    req.CreditLimit,
+			APR:            req.APR,
+			MinimumPayment: req.MinimumPayment,
+			DueDay:         req.DueDay,
+		}
+		if err := s.storeFor(r).Account().Create(acc); err != nil {
 			s.error(w, r, http.StatusUnprocessableEntity, err)
 			return
 		}
@@ -127,8 +183,16 @@ func (s *server) handleAccountCreate() http.HandlerFunc {
 func (s *server) handleAccountDelete() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		accountId, _ := strconv.Atoi(mux.Vars(r)["id"])
-		err := s.store.Account().Delete(accountId)
+		if err := s.authorizeAccount(r, accountId, model.OwnerRole); err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		err := s.storeFor(r).Account().Delete(accountId)
 		if err != nil {
+			if err == store.ErrAccountHasHistory {
+				s.error(w, r, http.StatusConflict, err)
+				return
+			}
 			s.error(w, r, http.StatusNotFound, err)
 			return
 		}
@@ -136,16 +200,237 @@ func (s *server) handleAccountDelete() http.HandlerFunc {
 	}
 }
 
+func (s *server) handleAccountArchive() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountID, _ := strconv.Atoi(mux.Vars(r)["id"])
+		if err := s.authorizeAccount(r, accountID, model.OwnerRole); err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		if err := s.storeFor(r).Account().Archive(accountID); err != nil {
+			switch err {
+			case store.ErrRecordNotFound:
+				s.error(w, r, http.StatusNotFound, err)
+			case store.ErrAccountArchived, store.ErrAccountNotArchived:
+				s.error(w, r, http.StatusConflict, err)
+			default:
+				s.error(w, r, http.StatusInternalServerError, err)
+			}
+			return
+		}
+		s.respond(w, r, http.StatusOK, nil)
+	}
+}
+
+func (s *server) handleAccountUnarchive() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountID, _ := strconv.Atoi(mux.Vars(r)["id"])
+		if err := s.authorizeAccount(r, accountID, model.OwnerRole); err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		if err := s.storeFor(r).Account().Unarchive(accountID); err != nil {
+			switch err {
+			case store.ErrRecordNotFound:
+				s.error(w, r, http.StatusNotFound, err)
+			case store.ErrAccountArchived, store.ErrAccountNotArchived:
+				s.error(w, r, http.StatusConflict, err)
+			default:
+				s.error(w, r, http.StatusInternalServerError, err)
+			}
+			return
+		}
+		s.respond(w, r, http.StatusOK, nil)
+	}
+}
+
 func (s *server) handleAccountUpdate() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		a := &model.Account{}
 		if err := json.NewDecoder(r.Body).Decode(a); err != nil {
 			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+		accountId, _ := strconv.Atoi(mux.Vars(r)["id"])
+		if err := s.authorizeAccount(r, accountId, model.EditorRole); err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		current, err := s.store.Account().Find(accountId)
+		if err != nil {
+			s.authorizationError(w, r, err)
+			return
 		}
+		s.saveAccount(w, r, current, a)
+	}
+}
+
+func (s *server) handleAccountPatch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		accountId, _ := strconv.Atoi(mux.Vars(r)["id"])
-		a.ID = accountId
-		err := s.store.Account().Save(a)
+		if err := s.authorizeAccount(r, accountId, model.EditorRole); err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		current, err := s.store.Account().Find(accountId)
 		if err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		a := &model.Account{}
+		if err := applyMergePatch(r, current, a, accountReadOnlyFields...); err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+		s.saveAccount(w, r, current, a)
+	}
+}
+
+// saveAccount stores a as the new state of current.
+// Fields that can't be edited through the API are taken from current.
+func (s *server) saveAccount(w http.ResponseWriter, r *http.Request, current *model.Account, a *model.Account) {
+	version, err := parseIfMatch(r, current.Version)
+	if err != nil {
+		s.error(w, r, http.StatusBadRequest, err)
+		return
+	}
+	a.ID = current.ID
+	a.User = current.User
+	a.Type = current.Type
+	a.ArchivedAt = current.ArchivedAt
+	a.InterestAccruedThrough = current.InterestAccruedThrough
+	a.Version = version
+	if err := s.storeFor(r).Account().Save(a); err != nil {
+		switch err {
+		case store.ErrRecordNotFound:
+			s.error(w, r, http.StatusNotFound, err)
+		case store.ErrVersionConflict:
+			s.error(w, r, http.StatusPreconditionFailed, err)
+		default:
+			s.error(w, r, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	setETag(w, a.Version)
+	s.respond(w, r, http.StatusOK, nil)
+}
+
+func (s *server) handleAccountPayoff() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountID, _ := strconv.Atoi(mux.Vars(r)["id"])
+		if err := s.authorizeAccount(r, accountID, model.ViewerRole); err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		a, err := s.store.Account().Find(accountID)
+		if err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		payment := a.MinimumPayment
+		if v := r.URL.Query().Get("payment"); v != "" {
+			if payment, err = strconv.ParseFloat(v, 64); err != nil {
+				s.error(w, r, http.StatusBadRequest, err)
+				return
+			}
+		}
+		if payment <= 0 {
+			s.error(w, r, http.StatusBadRequest, errPaymentRequired)
+			return
+		}
+		res, err := model.NewPayoffSchedule(a, payment, time.Now())
+		if err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, res)
+	}
+}
+
+func (s *server) handleAccountHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountID, _ := strconv.Atoi(mux.Vars(r)["id"])
+		if err := s.authorizeAccount(r, accountID, model.ViewerRole); err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		res, err := s.store.Audit().GetByEntity(model.AuditEntityAccount, accountID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, res)
+	}
+}
+
+func (s *server) handleAccountMemberGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountID, _ := strconv.Atoi(mux.Vars(r)["id"])
+		if err := s.authorizeAccount(r, accountID, model.ViewerRole); err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		res, err := s.store.Account().GetMembers(accountID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, res)
+	}
+}
+
+func (s *server) handleAccountMemberCreate() http.HandlerFunc {
+	type request struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &request{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		accountID, _ := strconv.Atoi(mux.Vars(r)["id"])
+		if err := s.authorizeAccount(r, accountID, model.OwnerRole); err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		u, err := s.store.User().FindByEmail(req.Email)
+		if err != nil {
+			s.error(w, r, http.StatusNotFound, err)
+			return
+		}
+		m := &model.AccountMember{
+			AccountID: accountID,
+			UserID:    u.ID,
+			Email:     u.Email,
+			Role:      req.Role,
+		}
+		if err := s.storeFor(r).Account().AddMember(m); err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+		s.respond(w, r, http.StatusCreated, m)
+	}
+}
+
+func (s *server) handleAccountMemberDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountID, _ := strconv.Atoi(mux.Vars(r)["id"])
+		userID, _ := strconv.Atoi(mux.Vars(r)["userID"])
+		//участник может сам покинуть счет
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		if u.ID != userID {
+			if err := s.authorizeAccount(r, accountID, model.OwnerRole); err != nil {
+				s.authorizationError(w, r, err)
+				return
+			}
+		}
+		if err := s.storeFor(r).Account().RemoveMember(accountID, userID); err != nil {
+			if err == store.ErrRecordNotFound {
+				s.error(w, r, http.StatusNotFound, err)
+				return
+			}
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
@@ -160,30 +445,144 @@ func (s *server) handleTransactionCreate() http.HandlerFunc {
 			s.error(w, r, http.StatusBadRequest, err)
 			return
 		}
-		SourceAcc, err := s.store.Account().Find(req.Source)
-		if err != nil {
-			s.error(w, r, http.StatusBadRequest, err)
+		if err := s.applyRules(r, req); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
-		DestinationAcc, err := s.store.Account().Find(req.Destination)
+		t, code, err := s.prepareTransaction(r, req)
 		if err != nil {
+			s.error(w, r, code, err)
+			return
+		}
+		if err := s.storeFor(r).Transaction().Create(t); err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+		s.respond(w, r, http.StatusCreated, t.ToJSON())
+	}
+}
+
+// prepareTransaction checks that the user can edit both accounts of req and
+// loads them. On failure it also returns the status to respond with.
+func (s *server) prepareTransaction(r *http.Request, req *model.TransactionJSON) (*model.TransactionDB, int, error) {
+	if err := s.authorizeAccounts(r, model.EditorRole, req.Source, req.Destination); err != nil {
+		return nil, authorizationStatus(err), err
+	}
+	SourceAcc, err := s.store.Account().Find(req.Source)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	DestinationAcc, err := s.store.Account().Find(req.Destination)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	return &model.TransactionDB{
+		TransactionDate: req.TransactionDate,
+		Source:          SourceAcc,
+		Destination:     DestinationAcc,
+		Type:            req.Type,
+		Amount:          req.Amount,
+		Description:     req.Description,
+		Tags:            req.Tags,
+	}, 0, nil
+}
+
+// handleTransactionBatch creates many transactions at once. By default the batch
+// is atomic: if any item fails, none is created. With mode=partial valid items
+// are created and the failed ones are reported.
+func (s *server) handleTransactionBatch() http.HandlerFunc {
+	type result struct {
+		Index       int                    `json:"index"`
+		Status      int                    `json:"status"`
+		Transaction *model.TransactionJSON `json:"transaction,omitempty"`
+		Error       *problem               `json:"error,omitempty"`
+	}
+	type response struct {
+		Mode    string    `json:"mode"`
+		Created int       `json:"created"`
+		Failed  int       `json:"failed"`
+		Results []*result `json:"results"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		mode := r.URL.Query().Get("mode")
+		if mode == "" {
+			mode = batchAtomic
+		}
+		if mode != batchAtomic && mode != batchPartial {
+			s.error(w, r, http.StatusBadRequest, errInvalidBatchMode)
+			return
+		}
+		reqs := make([]*model.TransactionJSON, 0)
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
 			s.error(w, r, http.StatusBadRequest, err)
 			return
 		}
-		t := &model.TransactionDB{
-			TransactionDate: req.TransactionDate,
-			Source:          SourceAcc,
-			Destination:     DestinationAcc,
-			Type:            req.Type,
-			Amount:          req.Amount,
-			Description:     req.Description,
+		if len(reqs) == 0 || len(reqs) > maxBatchSize {
+			s.error(w, r, http.StatusUnprocessableEntity, errInvalidBatchSize)
+			return
 		}
 
-		if err := s.store.Transaction().Create(t); err != nil {
-			s.error(w, r, http.StatusUnprocessableEntity, err)
+		if err := s.applyRules(r, reqs...); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
-		s.respond(w, r, http.StatusCreated, t.ToJSON())
+
+		res := &response{Mode: mode, Results: make([]*result, len(reqs))}
+		fail := func(i, code int, err error) {
+			p := newProblem(r, code, err)
+			p.Instance, p.RequestID = "", ""
+			res.Results[i] = &result{Index: i, Status: p.Status, Error: p}
+			res.Failed++
+		}
+
+		//сначала проверяем все элементы, в базу идут только корректные
+		valid := make([]*model.TransactionDB, 0, len(reqs))
+		indexes := make([]int, 0, len(reqs))
+		for i, req := range reqs {
+			t, code, err := s.prepareTransaction(r, req)
+			if err == nil {
+				err = t.Validate()
+				code = http.StatusUnprocessableEntity
+			}
+			if err != nil {
+				fail(i, code, err)
+				continue
+			}
+			valid = append(valid, t)
+			indexes = append(indexes, i)
+		}
+
+		atomic := mode == batchAtomic
+		if !atomic || res.Failed == 0 {
+			errs, err := s.storeFor(r).Transaction().CreateBatch(valid, atomic)
+			if err != nil {
+				s.error(w, r, http.StatusInternalServerError, err)
+				return
+			}
+			for j, t := range valid {
+				if errs[j] != nil {
+					fail(indexes[j], http.StatusUnprocessableEntity, errs[j])
+				} else {
+					res.Results[indexes[j]] = &result{Index: indexes[j], Status: http.StatusCreated, Transaction: t.ToJSON()}
+				}
+			}
+		}
+
+		if atomic && res.Failed > 0 {
+			for i := range res.Results {
+				if res.Results[i] == nil || res.Results[i].Error == nil {
+					res.Results[i] = &result{Index: i, Status: http.StatusFailedDependency}
+				}
+			}
+			s.respond(w, r, http.StatusUnprocessableEntity, res)
+			return
+		}
+		res.Created = len(reqs) - res.Failed
+		if atomic {
+			s.respond(w, r, http.StatusCreated, res)
+			return
+		}
+		s.respond(w, r, http.StatusOK, res)
 	}
 }
 
@@ -199,6 +598,14 @@ func (s *server) handleTransactionGet() http.HandlerFunc {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
+		//достаточно доступа к одному из счетов перевода
+		if err := s.authorizeAccount(r, t.Source, model.ViewerRole); err != nil {
+			if err := s.authorizeAccount(r, t.Destination, model.ViewerRole); err != nil {
+				s.authorizationError(w, r, err)
+				return
+			}
+		}
+		setETag(w, t.Version)
 		s.respond(w, r, http.StatusOK, t)
 	}
 }
@@ -206,12 +613,8 @@ func (s *server) handleTransactionGet() http.HandlerFunc {
 func (s *server) handleTransactionGetAll() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		accountID, _ := strconv.Atoi(mux.Vars(r)["accountID"])
-		u := r.Context().Value(ctxKeyUser).(*model.User)
-		if ok, err := s.store.Account().IsAccountBelongsUser(accountID, u.ID); err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
-			return
-		} else if !ok {
-			s.error(w, r, http.StatusUnauthorized, nil)
+		if err := s.authorizeAccount(r, accountID, model.ViewerRole); err != nil {
+			s.authorizationError(w, r, err)
 			return
 		}
 		tr, err := s.store.Transaction().GetAllByAccount(accountID)
@@ -231,7 +634,11 @@ func (s *server) handleTransactionDelete() http.HandlerFunc {
 			s.error(w, r, http.StatusNotFound, err)
 			return
 		}
-		if err := s.store.Transaction().Delete(t); err != nil {
+		if err := s.authorizeAccounts(r, model.EditorRole, t.Source, t.Destination); err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		if err := s.storeFor(r).Transaction().Delete(t); err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
@@ -239,35 +646,175 @@ func (s *server) handleTransactionDelete() http.HandlerFunc {
 	}
 }
 
+func (s *server) handleTransactionTrash() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		res, err := s.store.Transaction().GetTrash(u.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, res)
+	}
+}
+
+func (s *server) handleTransactionRestore() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(mux.Vars(r)["id"])
+		t, err := s.store.Transaction().FindDeleted(id)
+		if err != nil {
+			if err == store.ErrRecordNotFound {
+				s.error(w, r, http.StatusNotFound, err)
+				return
+			}
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if err := s.authorizeAccounts(r, model.EditorRole, t.Source, t.Destination); err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		if err := s.storeFor(r).Transaction().Restore(id); err != nil {
+			switch err {
+			case store.ErrInsufficientFunds, store.ErrAccountArchived:
+				s.error(w, r, http.StatusUnprocessableEntity, err)
+			default:
+				s.error(w, r, http.StatusInternalServerError, err)
+			}
+			return
+		}
+		t.DeletedAt = nil
+		s.respond(w, r, http.StatusOK, t)
+	}
+}
+
+func (s *server) handleTransactionHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(mux.Vars(r)["id"])
+		t, err := s.store.Transaction().Find(id)
+		if err == store.ErrRecordNotFound {
+			t, err = s.store.Transaction().FindDeleted(id)
+		}
+		if err != nil {
+			if err == store.ErrRecordNotFound {
+				s.error(w, r, http.StatusNotFound, err)
+				return
+			}
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if err := s.authorizeAccount(r, t.Source, model.ViewerRole); err != nil {
+			if err := s.authorizeAccount(r, t.Destination, model.ViewerRole); err != nil {
+				s.authorizationError(w, r, err)
+				return
+			}
+		}
+		res, err := s.store.Audit().GetByEntity(model.AuditEntityTransaction, id)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, res)
+	}
+}
+
 func (s *server) handleTransactionUpdate() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id, _ := strconv.Atoi(mux.Vars(r)["id"])
 		t := &model.TransactionJSON{}
 		if err := json.NewDecoder(r.Body).Decode(t); err != nil {
 			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
 		}
-		source, err := s.store.Account().Find(t.Source)
+		current, err := s.store.Transaction().Find(id)
 		if err != nil {
+			if err == store.ErrRecordNotFound {
+				s.error(w, r, http.StatusNotFound, err)
+				return
+			}
 			s.error(w, r, http.StatusInternalServerError, err)
+			return
 		}
-		destination, err := s.store.Account().Find(t.Destination)
+		s.saveTransaction(w, r, current, t)
+	}
+}
+
+func (s *server) handleTransactionPatch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(mux.Vars(r)["id"])
+		current, err := s.store.Transaction().Find(id)
 		if err != nil {
+			if err == store.ErrRecordNotFound {
+				s.error(w, r, http.StatusNotFound, err)
+				return
+			}
 			s.error(w, r, http.StatusInternalServerError, err)
+			return
 		}
-		tDB := &model.TransactionDB{
-			ID:              id,
-			TransactionDate: t.TransactionDate,
-			Source:          source,
-			Destination:     destination,
-			Amount:          t.Amount,
-			Type:            t.Type,
-			Description:     t.Description,
+		//проверяем доступ до разбора патча, чтобы не раскрывать детали чужих переводов
+		if err := s.authorizeAccounts(r, model.EditorRole, current.Source, current.Destination); err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		t := &model.TransactionJSON{}
+		if err := applyMergePatch(r, current, t, transactionReadOnlyFields...); err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
 		}
-		if err := s.store.Transaction().Save(tDB); err != nil {
+		s.saveTransaction(w, r, current, t)
+	}
+}
+
+// saveTransaction stores t as the new state of current.
+func (s *server) saveTransaction(w http.ResponseWriter, r *http.Request, current *model.TransactionJSON, t *model.TransactionJSON) {
+	if err := s.authorizeAccounts(r, model.EditorRole,
+		current.Source,
+		current.Destination,
+		t.Source,
+		t.Destination,
+	); err != nil {
+		s.authorizationError(w, r, err)
+		return
+	}
+	version, err := parseIfMatch(r, current.Version)
+	if err != nil {
+		s.error(w, r, http.StatusBadRequest, err)
+		return
+	}
+	source, err := s.store.Account().Find(t.Source)
+	if err != nil {
+		s.authorizationError(w, r, err)
+		return
+	}
+	destination, err := s.store.Account().Find(t.Destination)
+	if err != nil {
+		s.authorizationError(w, r, err)
+		return
+	}
+	tDB := &model.TransactionDB{
+		ID:              current.ID,
+		TransactionDate: t.TransactionDate,
+		Source:          source,
+		Destination:     destination,
+		Amount:          t.Amount,
+		Type:            t.Type,
+		Description:     t.Description,
+		Tags:            t.Tags,
+		Version:         version,
+	}
+	if err := s.storeFor(r).Transaction().Save(tDB); err != nil {
+		switch err {
+		case store.ErrRecordNotFound:
+			s.error(w, r, http.StatusNotFound, err)
+		case store.ErrVersionConflict:
+			s.error(w, r, http.StatusPreconditionFailed, err)
+		default:
 			s.error(w, r, http.StatusInternalServerError, err)
 		}
-		s.respond(w, r, http.StatusAccepted, tDB)
+		return
 	}
+	setETag(w, tDB.Version)
+	s.respond(w, r, http.StatusAccepted, tDB)
 }
 
 func (s *server) handleSummaryGet() http.HandlerFunc {
@@ -279,11 +826,13 @@ func (s *server) handleSummaryGet() http.HandlerFunc {
 		req := &request{}
 		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
 			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
 		}
 		u := r.Context().Value(ctxKeyUser).(*model.User)
 		res, err := s.store.Transaction().GetSummary(u.ID, req.DateStart, req.DateEnd)
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
+			return
 		}
 		s.respond(w, r, http.StatusOK, res)
 	}
@@ -298,24 +847,41 @@ func (s *server) handleSummaryAccountGet() http.HandlerFunc {
 		req := &request{}
 		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
 			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
 		}
 		accountID, _ := strconv.Atoi(mux.Vars(r)["accountID"])
+		if err := s.authorizeAccount(r, accountID, model.ViewerRole); err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
 		acc, err := s.store.Account().Find(accountID)
 		if err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
+			s.authorizationError(w, r, err)
+			return
 		}
-		res, err := model.GetSummaryByAccount(acc)
+		var descendants []int
+		if acc.IsCategory() {
+			descendants, err = s.store.Account().GetDescendants(accountID)
+			if err != nil {
+				s.error(w, r, http.StatusInternalServerError, err)
+				return
+			}
+		}
+		res, err := model.GetSummaryByAccount(acc, descendants...)
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
+			return
 		}
 		if err := res.SetPeriod(req.DateStart, req.DateEnd); err != nil {
 			s.error(w, r, http.StatusBadRequest, err)
+			return
 		}
-		transactions, err := s.store.Transaction().GetAllByAccountAndPeriod(accountID, req.DateStart, req.DateEnd)
+		flows, err := s.store.Transaction().GetAccountFlows(append([]int{accountID}, descendants...), req.DateStart, req.DateEnd)
 		if err != nil {
 			s.error(w, r, http.StatusInternalServerError, err)
+			return
 		}
-		res.CalculateSummary(transactions)
+		res.ApplyFlows(flows)
 		s.respond(w, r, http.StatusOK, res)
 	}
 }
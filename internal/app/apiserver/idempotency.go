@@ -0,0 +1,124 @@
+package apiserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// replayedHeaders are the response headers stored together with the body.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// recordingWriter keeps a copy of the response so it can be replayed later.
+type recordingWriter struct {
+	http.ResponseWriter
+	code int
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(statusCode int) {
+	w.code = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// idempotent makes mutating requests with an Idempotency-Key header safe to retry.
+// The first response for a key is stored per user and sent again on replays
+// of the same request. Reusing the key for a different request is rejected.
+func (s *server) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			s.error(w, r, http.StatusBadRequest, errIdempotencyKeyTooLong)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		k := &model.IdempotencyKey{
+			UserID:      u.ID,
+			Key:         key,
+			RequestHash: requestHash(r, body),
+		}
+		if err := s.store.IdempotencyKey().Create(k); err != nil {
+			if err != store.ErrIdempotencyKeyUsed {
+				s.error(w, r, http.StatusInternalServerError, err)
+				return
+			}
+			s.replay(w, r, k)
+			return
+		}
+
+		rw := &recordingWriter{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		//ошибку сервера можно повторить с тем же ключом
+		if rw.code >= http.StatusInternalServerError {
+			if err := s.store.IdempotencyKey().Delete(k.UserID, k.Key); err != nil {
+				s.logger.Errorf("release idempotency key: %v", err)
+			}
+			return
+		}
+		k.StatusCode = rw.code
+		k.Header = make(http.Header)
+		for _, h := range replayedHeaders {
+			if v := w.Header().Get(h); v != "" {
+				k.Header.Set(h, v)
+			}
+		}
+		k.Body = rw.body.Bytes()
+		if err := s.store.IdempotencyKey().Save(k); err != nil {
+			s.logger.Errorf("save idempotency key: %v", err)
+		}
+	})
+}
+
+// replay answers a repeated request with the stored response of the first one.
+func (s *server) replay(w http.ResponseWriter, r *http.Request, k *model.IdempotencyKey) {
+	stored, err := s.store.IdempotencyKey().Find(k.UserID, k.Key)
+	if err != nil {
+		s.error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if stored.RequestHash != k.RequestHash {
+		s.error(w, r, http.StatusUnprocessableEntity, errIdempotencyKeyReused)
+		return
+	}
+	if !stored.IsCompleted() {
+		s.error(w, r, http.StatusConflict, errIdempotencyKeyInProgress)
+		return
+	}
+	for h, v := range stored.Header {
+		w.Header()[h] = v
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(stored.StatusCode)
+	w.Write(stored.Body)
+}
+
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -2,10 +2,12 @@ package apiserver
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
@@ -57,3 +59,16 @@ func (s *server) authenticateUser(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKeyUser, u)))
 	})
 }
+
+// deprecated marks responses of legacy routes with Deprecation and Sunset headers
+// and links to the same route under prefix.
+func (s *server) deprecated(prefix string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Sunset", s.legacySunset.Format(http.TimeFormat))
+			w.Header().Set("Link", fmt.Sprintf("<%s%s>; rel=\"successor-version\"", prefix, r.URL.Path))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package apiserver
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+)
+
+const dateLayout = "2006-01-02"
+
+var maxDate = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// parseDate accepts both RFC 3339 timestamps and plain dates.
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(dateLayout, value)
+}
+
+// parsePeriod reads the optional date_start and date_end query parameters.
+// A missing bound leaves the period open on that side.
+func parsePeriod(r *http.Request) (time.Time, time.Time, error) {
+	dateStart, dateEnd := time.Time{}, maxDate
+	q := r.URL.Query()
+	if v := q.Get("date_start"); v != "" {
+		t, err := parseDate(v)
+		if err != nil {
+			return dateStart, dateEnd, err
+		}
+		dateStart = t
+	}
+	if v := q.Get("date_end"); v != "" {
+		t, err := parseDate(v)
+		if err != nil {
+			return dateStart, dateEnd, err
+		}
+		dateEnd = t
+	}
+	return dateStart, dateEnd, nil
+}
+
+// parseRange reads the required from and to query parameters of reports
+// as calendar dates, so a time of day or offset does not shift the buckets.
+func parseRange(r *http.Request) (time.Time, time.Time, error) {
+	q := r.URL.Query()
+	if q.Get("from") == "" || q.Get("to") == "" {
+		return time.Time{}, time.Time{}, errRangeRequired
+	}
+	from, err := parseDate(q.Get("from"))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, err := parseDate(q.Get("to"))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	from, to = model.TruncateDate(from, model.IntervalDay), model.TruncateDate(to, model.IntervalDay)
+	if from.After(to) {
+		return time.Time{}, time.Time{}, model.ErrInvalidPeriod
+	}
+	return from, to, nil
+}
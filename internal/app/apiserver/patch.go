@@ -0,0 +1,79 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+const mergePatchType = "application/merge-patch+json"
+
+var (
+	accountReadOnlyFields     = []string{"id", "user", "type", "balance", "archived_at", "version"}
+	transactionReadOnlyFields = []string{"id", "deleted_at", "version"}
+)
+
+type readOnlyFieldError struct {
+	field string
+}
+
+func (e *readOnlyFieldError) Error() string {
+	return fmt.Sprintf("%s is read-only", e.field)
+}
+
+// applyMergePatch applies the RFC 7396 merge patch from the request body
+// to current and decodes the result into dst.
+// Patches touching any of readOnly fields are rejected.
+func applyMergePatch(r *http.Request, current interface{}, dst interface{}, readOnly ...string) error {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mediaType != mergePatchType && mediaType != "application/json") {
+			return errUnsupportedPatchType
+		}
+	}
+	var patch interface{}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		return errMalformedPatch
+	}
+	if fields, ok := patch.(map[string]interface{}); ok {
+		for _, f := range readOnly {
+			if _, ok := fields[f]; ok {
+				return &readOnlyFieldError{field: f}
+			}
+		}
+	}
+
+	b, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	if b, err = json.Marshal(mergePatch(doc, patch)); err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dst)
+}
+
+// mergePatch implements the MergePatch function from RFC 7396.
+func mergePatch(target, patch interface{}) interface{} {
+	fields, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	doc, ok := target.(map[string]interface{})
+	if !ok {
+		doc = make(map[string]interface{})
+	}
+	for name, value := range fields {
+		if value == nil {
+			delete(doc, name)
+		} else {
+			doc[name] = mergePatch(doc[name], value)
+		}
+	}
+	return doc
+}
@@ -0,0 +1,53 @@
+package apiserver
+
+import (
+	"net/http"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+)
+
+// authorizeAccount checks that the current user has at least the required
+// role on the account. Accounts the user has no access to are reported as
+// not found, so their existence is not disclosed.
+func (s *server) authorizeAccount(r *http.Request, accountID int, required string) error {
+	u := r.Context().Value(ctxKeyUser).(*model.User)
+	role, err := s.store.Account().GetRole(accountID, u.ID)
+	if err != nil {
+		return err
+	}
+	if role == "" {
+		return store.ErrRecordNotFound
+	}
+	if !model.RoleAllows(role, required) {
+		return errForbidden
+	}
+	return nil
+}
+
+func (s *server) authorizeAccounts(r *http.Request, required string, accountIDs ...int) error {
+	for _, id := range accountIDs {
+		if err := s.authorizeAccount(r, id, required); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// authorizationError reports an error returned by authorizeAccount or by
+// loading a record the user asked for. Records the user can't see are not found.
+func (s *server) authorizationError(w http.ResponseWriter, r *http.Request, err error) {
+	s.error(w, r, authorizationStatus(err), err)
+}
+
+// authorizationStatus is the status to respond with for such errors.
+func authorizationStatus(err error) int {
+	switch err {
+	case store.ErrRecordNotFound:
+		return http.StatusNotFound
+	case errForbidden:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,168 @@
+package apiserver
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+const problemContentType = "application/problem+json"
+
+// problem is an RFC 7807 error response. Code is stable and meant for
+// clients, Detail is a human readable message that may change.
+type problem struct {
+	Type      string          `json:"type"`
+	Title     string          `json:"title"`
+	Status    int             `json:"status"`
+	Code      string          `json:"code"`
+	Detail    string          `json:"detail,omitempty"`
+	Instance  string          `json:"instance,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+	Errors    []*fieldProblem `json:"errors,omitempty"`
+}
+
+type fieldProblem struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type errorKind struct {
+	status int
+	code   string
+}
+
+// errorKinds maps known errors to their status and code. The status passed
+// to server.error is only used for errors missing here.
+var errorKinds = map[error]errorKind{
+	store.ErrRecordNotFound:      {http.StatusNotFound, "not_found"},
+	store.ErrUserAlreadyExists:   {http.StatusConflict, "user_already_exists"},
+	store.ErrInsufficientFunds:   {http.StatusUnprocessableEntity, "insufficient_funds"},
+	store.ErrAccountOwner:        {http.StatusUnprocessableEntity, "account_owner"},
+	store.ErrInvalidParent:       {http.StatusUnprocessableEntity, "invalid_parent"},
+	store.ErrCategoryCycle:       {http.StatusUnprocessableEntity, "category_cycle"},
+	store.ErrAccountArchived:     {http.StatusConflict, "account_archived"},
+	store.ErrAccountNotArchived:  {http.StatusConflict, "account_not_archived"},
+	store.ErrAccountHasHistory:   {http.StatusConflict, "account_has_history"},
+	store.ErrVersionConflict:     {http.StatusPreconditionFailed, "version_conflict"},
+	store.ErrCreditLimitExceeded: {http.StatusUnprocessableEntity, "credit_limit_exceeded"},
+	store.ErrDuplicateResolved:   {http.StatusConflict, "duplicate_resolved"},
+
+	model.ErrInvalidPeriod:        {http.StatusUnprocessableEntity, "invalid_period"},
+	model.ErrUnknownInterval:      {http.StatusBadRequest, "unknown_interval"},
+	model.ErrUnknownGroupBy:       {http.StatusBadRequest, "unknown_group_by"},
+	model.ErrNotDebtAccount:       {http.StatusUnprocessableEntity, "not_debt_account"},
+	model.ErrPaymentTooLow:        {http.StatusUnprocessableEntity, "payment_too_low"},
+	model.ErrNotSavingAccount:     {http.StatusUnprocessableEntity, "not_saving_account"},
+	model.ErrRuleWithoutCondition: {http.StatusUnprocessableEntity, "rule_without_condition"},
+	model.ErrRuleWithoutAction:    {http.StatusUnprocessableEntity, "rule_without_action"},
+
+	errIncorrectEmailOrPassword: {http.StatusUnauthorized, "incorrect_email_or_password"},
+	errNotAuthenticated:         {http.StatusUnauthorized, "not_authenticated"},
+	errForbidden:                {http.StatusForbidden, "forbidden"},
+	errPreconditionRequired:     {http.StatusPreconditionRequired, "precondition_required"},
+	errInvalidIfMatch:           {http.StatusBadRequest, "invalid_if_match"},
+	errUnsupportedPatchType:     {http.StatusUnsupportedMediaType, "unsupported_patch_type"},
+	errMalformedPatch:           {http.StatusBadRequest, "malformed_patch"},
+	errInvalidBatchMode:         {http.StatusBadRequest, "invalid_batch_mode"},
+	errInvalidBatchSize:         {http.StatusUnprocessableEntity, "invalid_batch_size"},
+	errIdempotencyKeyTooLong:    {http.StatusBadRequest, "idempotency_key_too_long"},
+	errIdempotencyKeyReused:     {http.StatusUnprocessableEntity, "idempotency_key_reused"},
+	errIdempotencyKeyInProgress: {http.StatusConflict, "idempotency_key_in_progress"},
+	errRangeRequired:            {http.StatusBadRequest, "range_required"},
+	errTooManyBuckets:           {http.StatusUnprocessableEntity, "too_many_buckets"},
+	errInvalidForecastDays:      {http.StatusBadRequest, "invalid_forecast_days"},
+	errPaymentRequired:          {http.StatusBadRequest, "payment_required"},
+	errInvalidKeep:              {http.StatusUnprocessableEntity, "invalid_keep"},
+}
+
+// fieldCodes are codes of validation errors reported for a single field.
+var fieldCodes = map[error]string{
+	model.ErrTransactionTypeMismatch: "transaction_type_mismatch",
+	model.ErrParentNotCategory:       "parent_not_category",
+	model.ErrUnknownWebhookEvent:     "unknown_webhook_event",
+	model.ErrWebhookScheme:           "webhook_scheme",
+	model.ErrWebhookPrivateAddress:   "webhook_private_address",
+	model.ErrDebtOnly:                "debt_only",
+	model.ErrInvalidRegexp:           "invalid_regexp",
+	model.ErrInvalidAmountRange:      "invalid_amount_range",
+}
+
+func newProblem(r *http.Request, status int, err error) *problem {
+	p := &problem{
+		Type:     "about:blank",
+		Status:   status,
+		Instance: r.URL.Path,
+	}
+	if id, ok := r.Context().Value(ctxKeyRequestID).(string); ok {
+		p.RequestID = id
+	}
+
+	var readOnly *readOnlyFieldError
+	if errs, ok := err.(validation.Errors); ok {
+		p.Status, p.Code = http.StatusUnprocessableEntity, "validation_failed"
+		p.Errors = fieldProblems("", errs)
+	} else if kind, ok := lookupErrorKind(err); ok {
+		p.Status, p.Code = kind.status, kind.code
+	} else if errors.As(err, &readOnly) {
+		p.Status, p.Code = http.StatusUnprocessableEntity, "read_only_field"
+		p.Errors = []*fieldProblem{{Field: readOnly.field, Code: "read_only", Message: readOnly.Error()}}
+	} else {
+		p.Code = statusCode(p.Status)
+	}
+	p.Title = http.StatusText(p.Status)
+
+	//детали внутренних ошибок остаются только в логе
+	if err != nil && p.Status < http.StatusInternalServerError {
+		p.Detail = err.Error()
+	}
+	return p
+}
+
+// lookupErrorKind compares with errors.Is, since err may be of a type
+// that can't be used as a map key, such as validation.Errors.
+func lookupErrorKind(err error) (errorKind, bool) {
+	if err == nil {
+		return errorKind{}, false
+	}
+	for target, kind := range errorKinds {
+		if errors.Is(err, target) {
+			return kind, true
+		}
+	}
+	return errorKind{}, false
+}
+
+// fieldProblems flattens possibly nested validation errors,
+// naming nested fields with dots.
+func fieldProblems(prefix string, errs validation.Errors) []*fieldProblem {
+	res := make([]*fieldProblem, 0, len(errs))
+	for field, err := range errs {
+		if prefix != "" {
+			field = prefix + "." + field
+		}
+		if nested, ok := err.(validation.Errors); ok {
+			res = append(res, fieldProblems(field, nested)...)
+			continue
+		}
+		code := "invalid"
+		for target, c := range fieldCodes {
+			if errors.Is(err, target) {
+				code = c
+			}
+		}
+		res = append(res, &fieldProblem{Field: field, Code: code, Message: err.Error()})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Field < res[j].Field })
+	return res
+}
+
+// statusCode derives a code for errors without a specific one, e.g. "bad_request".
+func statusCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
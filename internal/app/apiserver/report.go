@@ -0,0 +1,205 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+)
+
+func (s *server) handleReportTimeSeries() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := parseRange(r)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		q := r.URL.Query()
+		interval := q.Get("interval")
+		if interval == "" {
+			interval = model.IntervalMonth
+		}
+		if err := model.ValidateInterval(interval); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		groupBy := q.Get("group_by")
+		if err := model.ValidateGroupBy(groupBy); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if model.CountBuckets(from, to, interval) > maxReportBuckets {
+			s.error(w, r, http.StatusUnprocessableEntity, errTooManyBuckets)
+			return
+		}
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		rows, err := s.store.Transaction().GetTimeSeries(u.ID, from, to, interval, groupBy)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, model.NewTimeSeries(from, to, interval, groupBy, rows))
+	}
+}
+
+func (s *server) handleReportNetWorth() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := parseRange(r)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		interval := r.URL.Query().Get("interval")
+		if interval == "" {
+			interval = model.IntervalMonth
+		}
+		if err := model.ValidateInterval(interval); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if model.CountBuckets(from, to, interval) > maxReportBuckets {
+			s.error(w, r, http.StatusUnprocessableEntity, errTooManyBuckets)
+			return
+		}
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		accounts, err := s.store.Account().GetAllByUser(u.ID, true)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		changes, err := s.store.Transaction().GetBalanceChanges(u.ID, model.TruncateDate(from, interval), interval)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, model.NewNetWorth(from, to, interval, accounts, changes))
+	}
+}
+
+func (s *server) handleReportCategories() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := parseRange(r)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		accounts, err := s.store.Account().GetAllByUser(u.ID, true)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		prevStart, _ := model.PreviousPeriod(from, to)
+		totals, err := s.store.Transaction().GetCategoryComparison(u.ID, from, to, prevStart)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, model.NewCategoryBreakdown(from, to, accounts, totals))
+	}
+}
+
+func (s *server) handleReportAnomalies() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := parseRange(r)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		settings, err := s.store.AnomalySettings().Find(u.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		accounts, err := s.store.Account().GetAllByUser(u.ID, true)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		ts, err := s.store.Transaction().GetAllByUserAndPeriod(u.ID, settings.LookbackStart(from, to), to)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, model.NewAnomalyReport(from, to, settings, accounts, ts))
+	}
+}
+
+func (s *server) handleAnomalySettingsGet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		settings, err := s.store.AnomalySettings().Find(u.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, settings)
+	}
+}
+
+func (s *server) handleAnomalySettingsUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		settings := &model.AnomalySettings{}
+		if err := json.NewDecoder(r.Body).Decode(settings); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		settings.User = u.ID
+		if err := settings.Validate(); err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+		if err := s.store.AnomalySettings().Save(settings); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, settings)
+	}
+}
+
+func (s *server) handleReportForecast() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		days := defaultForecastDays
+		if v := q.Get("days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxForecastDays {
+				s.error(w, r, http.StatusBadRequest, errInvalidForecastDays)
+				return
+			}
+			days = n
+		}
+		var threshold float64
+		if v := q.Get("threshold"); v != "" {
+			t, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				s.error(w, r, http.StatusBadRequest, err)
+				return
+			}
+			threshold = t
+		}
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		accounts, err := s.store.Account().GetAllByUser(u.ID, false)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		today := model.TruncateDate(time.Now(), model.IntervalDay)
+		history, err := s.store.Transaction().GetAllByUserAndPeriod(u.ID, today.AddDate(0, 0, -forecastLookbackDays), today)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		scheduled, err := s.store.Transaction().GetAllByUserAndPeriod(u.ID, today.AddDate(0, 0, 1), maxDate)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		recurring := model.DetectRecurring(history, today)
+		s.respond(w, r, http.StatusOK, model.NewForecast(today, days, threshold, accounts, recurring, scheduled))
+	}
+}
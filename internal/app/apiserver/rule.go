@@ -0,0 +1,244 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+	"github.com/gorilla/mux"
+)
+
+func (s *server) handleRuleGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		res, err := s.store.Rule().GetAllByUser(u.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, res)
+	}
+}
+
+func (s *server) handleRuleCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rule := &model.Rule{}
+		if err := json.NewDecoder(r.Body).Decode(rule); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if err := s.authorizeRule(r, rule); err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		rule.User = u.ID
+		if err := s.store.Rule().Create(rule); err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+		s.respond(w, r, http.StatusCreated, rule)
+	}
+}
+
+func (s *server) handleRuleGet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rule, err := s.findRule(r)
+		if err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, rule)
+	}
+}
+
+func (s *server) handleRuleUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		current, err := s.findRule(r)
+		if err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		rule := &model.Rule{}
+		if err := json.NewDecoder(r.Body).Decode(rule); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if err := s.authorizeRule(r, rule); err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		rule.ID = current.ID
+		rule.User = current.User
+		rule.CreatedAt = current.CreatedAt
+		if err := s.store.Rule().Save(rule); err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, rule)
+	}
+}
+
+func (s *server) handleRuleDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rule, err := s.findRule(r)
+		if err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		if err := s.store.Rule().Delete(rule.ID); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, nil)
+	}
+}
+
+// handleRuleRun applies the rules to the user's stored transactions,
+// overwriting their destination and type. Unless dry_run=false is passed
+// nothing is saved and the response only lists what would change.
+func (s *server) handleRuleRun() http.HandlerFunc {
+	type change struct {
+		*model.RuleChange
+		Status int      `json:"status,omitempty"`
+		Error  *problem `json:"error,omitempty"`
+	}
+	type response struct {
+		DryRun  bool      `json:"dry_run"`
+		Applied int       `json:"applied"`
+		Failed  int       `json:"failed"`
+		Changes []*change `json:"changes"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		dryRun := true
+		if v := r.URL.Query().Get("dry_run"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				s.error(w, r, http.StatusBadRequest, err)
+				return
+			}
+			dryRun = b
+		}
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		rules, err := s.store.Rule().GetAllByUser(u.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		ts, err := s.store.Transaction().GetAllByUser(u.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		//переводы по счетам, где пользователь только наблюдатель, не трогаем
+		editable := make(map[int]bool)
+		canEdit := func(accountID int) bool {
+			if ok, checked := editable[accountID]; checked {
+				return ok
+			}
+			editable[accountID] = s.authorizeAccount(r, accountID, model.EditorRole) == nil
+			return editable[accountID]
+		}
+		own := make([]*model.TransactionJSON, 0, len(ts))
+		for _, t := range ts {
+			if canEdit(t.Source) && canEdit(t.Destination) {
+				own = append(own, t)
+			}
+		}
+
+		res := &response{DryRun: dryRun, Changes: make([]*change, 0)}
+		for _, c := range model.RerunRules(rules, own) {
+			item := &change{RuleChange: c}
+			res.Changes = append(res.Changes, item)
+			if dryRun {
+				continue
+			}
+			if err := s.applyRuleChange(r, c); err != nil {
+				p := newProblem(r, http.StatusUnprocessableEntity, err)
+				p.Instance, p.RequestID = "", ""
+				item.Status, item.Error = p.Status, p
+				res.Failed++
+				continue
+			}
+			res.Applied++
+		}
+		s.respond(w, r, http.StatusOK, res)
+	}
+}
+
+// applyRules fills in new transactions with what the user's rules define.
+// Fields the user has set are kept.
+func (s *server) applyRules(r *http.Request, ts ...*model.TransactionJSON) error {
+	u := r.Context().Value(ctxKeyUser).(*model.User)
+	rules, err := s.store.Rule().GetAllByUser(u.ID)
+	if err != nil {
+		return err
+	}
+	for _, t := range ts {
+		model.ApplyRules(rules, t, false)
+	}
+	return nil
+}
+
+// applyRuleChange saves the transaction as the rule changed it.
+func (s *server) applyRuleChange(r *http.Request, c *model.RuleChange) error {
+	if err := s.authorizeAccount(r, c.After.Destination, model.EditorRole); err != nil {
+		return err
+	}
+	source, err := s.store.Account().Find(c.After.Source)
+	if err != nil {
+		return err
+	}
+	destination, err := s.store.Account().Find(c.After.Destination)
+	if err != nil {
+		return err
+	}
+	t := &model.TransactionDB{
+		ID:              c.After.ID,
+		TransactionDate: c.After.TransactionDate,
+		Source:          source,
+		Destination:     destination,
+		Amount:          c.After.Amount,
+		Type:            c.After.Type,
+		Description:     c.After.Description,
+		Tags:            c.After.Tags,
+		Version:         c.After.Version,
+	}
+	if err := s.storeFor(r).Transaction().Save(t); err != nil {
+		return err
+	}
+	c.After = t.ToJSON()
+	return nil
+}
+
+// authorizeRule checks that the user can see the source the rule matches
+// and edit the destination it sets.
+func (s *server) authorizeRule(r *http.Request, rule *model.Rule) error {
+	if rule.Source != nil {
+		if err := s.authorizeAccount(r, *rule.Source, model.ViewerRole); err != nil {
+			return err
+		}
+	}
+	if rule.Destination != nil {
+		if err := s.authorizeAccount(r, *rule.Destination, model.EditorRole); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findRule loads the rule from the route. Rules of other users are not found.
+func (s *server) findRule(r *http.Request) (*model.Rule, error) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	rule, err := s.store.Rule().Find(id)
+	if err != nil {
+		return nil, err
+	}
+	u := r.Context().Value(ctxKeyUser).(*model.User)
+	if rule.User != u.ID {
+		return nil, store.ErrRecordNotFound
+	}
+	return rule, nil
+}
@@ -4,8 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"time"
 
+	"github.com/Aza-9798/costs-rest-api/internal/app/events"
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
 	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store/eventstore"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
@@ -21,9 +25,44 @@ const (
 
 type ctxKey int8
 
+const (
+	batchAtomic  = "atomic"
+	batchPartial = "partial"
+
+	maxBatchSize = 500
+
+	eventBuffer     = 64
+	eventStreamPing = 30 * time.Second
+
+	maxReportBuckets = 1000
+
+	defaultForecastDays  = 30
+	maxForecastDays      = 365
+	forecastLookbackDays = 180
+)
+
+// defaultLegacySunset is when the unversioned aliases of /api/v1 routes stop
+// working, unless Config.LegacySunset sets another date.
+var defaultLegacySunset = time.Date(2027, time.June, 30, 0, 0, 0, 0, time.UTC)
+
 var (
 	errIncorrectEmailOrPassword = errors.New("incorrect email or password")
 	errNotAuthenticated         = errors.New("not authenticated")
+	errForbidden                = errors.New("not enough permissions for the account")
+	errPreconditionRequired     = errors.New("If-Match header is required")
+	errInvalidIfMatch           = errors.New("If-Match header must contain an ETag of the resource")
+	errUnsupportedPatchType     = errors.New("patch must be sent as application/merge-patch+json")
+	errMalformedPatch           = errors.New("patch is not a valid JSON document")
+	errInvalidBatchMode         = errors.New("batch mode must be atomic or partial")
+	errInvalidBatchSize         = errors.New("batch must contain from 1 to 500 transactions")
+	errIdempotencyKeyTooLong    = errors.New("Idempotency-Key header is too long")
+	errIdempotencyKeyReused     = errors.New("Idempotency-Key was already used for a different request")
+	errIdempotencyKeyInProgress = errors.New("request with this Idempotency-Key is still being processed")
+	errRangeRequired            = errors.New("from and to query parameters are required")
+	errTooManyBuckets           = errors.New("report must contain at most 1000 buckets")
+	errInvalidForecastDays      = errors.New("days must be from 1 to 365")
+	errPaymentRequired          = errors.New("payment must be a positive amount when the account has no minimum payment")
+	errInvalidKeep              = errors.New("keep must be one of the two transactions of the pair")
 )
 
 type server struct {
@@ -31,6 +70,8 @@ type server struct {
 	logger       *logrus.Logger
 	store        store.Store
 	sessionStore sessions.Store
+	broker       events.Broker
+	legacySunset time.Time
 }
 
 func newServer(store store.Store, sessionStore sessions.Store, logLevel string) (*server, error) {
@@ -45,6 +86,8 @@ func newServer(store store.Store, sessionStore sessions.Store, logLevel string)
 		logger:       logger,
 		store:        store,
 		sessionStore: sessionStore,
+		broker:       events.NewMemory(eventBuffer),
+		legacySunset: defaultLegacySunset,
 	}
 
 	s.configureRouter()
@@ -60,30 +103,110 @@ func (s *server) configureRouter() {
 	s.router.Use(s.setRequestID)
 	s.router.Use(s.logRequest)
 	s.router.Use(handlers.CORS(handlers.AllowedOrigins([]string{"*"})))
-	s.router.HandleFunc("/user", s.handleUserCreate()).Methods("POST")
-	s.router.HandleFunc("/session", s.handleSessionCreate()).Methods("POST")
+	s.router.HandleFunc("/openapi.json", s.handleOpenAPI()).Methods("GET")
+	s.router.HandleFunc("/docs", s.handleDocs()).Methods("GET")
+	s.router.HandleFunc("/docs/{file}", s.handleDocAsset()).Methods("GET")
 
-	private := s.router.PathPrefix("/private").Subrouter()
+	s.registerV1(s.router.PathPrefix("/api/v1").Subrouter())
+
+	//старые пути без версии, оставлены до legacySunset
+	legacy := s.router.NewRoute().Subrouter()
+	legacy.Use(s.deprecated("/api/v1"))
+	s.registerV1(legacy)
+}
+
+// registerV1 adds routes of the first API version to r.
+// A new version gets its own register function mounted under its prefix
+// and reuses the handlers whose payloads did not change.
+func (s *server) registerV1(r *mux.Router) {
+	r.HandleFunc("/user", s.handleUserCreate()).Methods("POST")
+	r.HandleFunc("/session", s.handleSessionCreate()).Methods("POST")
+
+	private := r.PathPrefix("/private").Subrouter()
 	private.Use(s.authenticateUser)
+	private.Use(s.idempotent)
 
 	private.HandleFunc("/summary", s.handleSummaryGet()).Methods("POST")
+	private.HandleFunc("/report/timeseries", s.handleReportTimeSeries()).Methods("GET")
+	private.HandleFunc("/report/networth", s.handleReportNetWorth()).Methods("GET")
+	private.HandleFunc("/report/categories", s.handleReportCategories()).Methods("GET")
+	private.HandleFunc("/report/anomalies", s.handleReportAnomalies()).Methods("GET")
+	private.HandleFunc("/report/forecast", s.handleReportForecast()).Methods("GET")
+	private.HandleFunc("/report/anomalies/settings", s.handleAnomalySettingsGet()).Methods("GET")
+	private.HandleFunc("/report/anomalies/settings", s.handleAnomalySettingsUpdate()).Methods("PUT")
+	private.HandleFunc("/events", s.handleEvents()).Methods("GET")
 	//счета
 	private.HandleFunc("/account", s.handleAccountCreate()).Methods("POST")
 	private.HandleFunc("/account/{id:[0-9]+}", s.handleAccountGet()).Methods("GET")
 	private.HandleFunc("/account/{id:[0-9]+}", s.handleAccountDelete()).Methods("DELETE")
 	private.HandleFunc("/account/{id:[0-9]+}", s.handleAccountUpdate()).Methods("PUT")
+	private.HandleFunc("/account/{id:[0-9]+}", s.handleAccountPatch()).Methods("PATCH")
+	private.HandleFunc("/account/{id:[0-9]+}/history", s.handleAccountHistory()).Methods("GET")
+	private.HandleFunc("/account/{id:[0-9]+}/archive", s.handleAccountArchive()).Methods("POST")
+	private.HandleFunc("/account/{id:[0-9]+}/unarchive", s.handleAccountUnarchive()).Methods("POST")
 	private.HandleFunc("/account/all", s.handleAccountGetAll()).Methods("GET")
+	private.HandleFunc("/account/tree", s.handleAccountTree()).Methods("GET")
 	private.HandleFunc("/account/{accountID:[0-9]+}/all_transactions", s.handleTransactionGetAll()).Methods("GET")
 	private.HandleFunc("/account/{accountID:[0-9]+}/summary", s.handleSummaryAccountGet()).Methods("POST")
+	private.HandleFunc("/account/{id:[0-9]+}/payoff", s.handleAccountPayoff()).Methods("GET")
+	private.HandleFunc("/account/{id:[0-9]+}/member", s.handleAccountMemberGetAll()).Methods("GET")
+	private.HandleFunc("/account/{id:[0-9]+}/member", s.handleAccountMemberCreate()).Methods("POST")
+	private.HandleFunc("/account/{id:[0-9]+}/member/{userID:[0-9]+}", s.handleAccountMemberDelete()).Methods("DELETE")
 	//переводы
 	private.HandleFunc("/transaction", s.handleTransactionCreate()).Methods("POST")
+	private.HandleFunc("/transaction/batch", s.handleTransactionBatch()).Methods("POST")
 	private.HandleFunc("/transaction/{id:[0-9]+}", s.handleTransactionGet()).Methods("GET")
 	private.HandleFunc("/transaction/{id:[0-9]+}", s.handleTransactionDelete()).Methods("DELETE")
 	private.HandleFunc("/transaction/{id:[0-9]+}", s.handleTransactionUpdate()).Methods("PUT")
+	private.HandleFunc("/transaction/{id:[0-9]+}", s.handleTransactionPatch()).Methods("PATCH")
+	private.HandleFunc("/transaction/{id:[0-9]+}/history", s.handleTransactionHistory()).Methods("GET")
+	private.HandleFunc("/transaction/trash", s.handleTransactionTrash()).Methods("GET")
+	private.HandleFunc("/transaction/{id:[0-9]+}/restore", s.handleTransactionRestore()).Methods("POST")
+	private.HandleFunc("/transaction/duplicates", s.handleDuplicateGetAll()).Methods("GET")
+	private.HandleFunc("/transaction/duplicates/{id:[0-9]+}/merge", s.handleDuplicateMerge()).Methods("POST")
+	private.HandleFunc("/transaction/duplicates/{id:[0-9]+}/dismiss", s.handleDuplicateDismiss()).Methods("POST")
+	//правила
+	private.HandleFunc("/rule", s.handleRuleGetAll()).Methods("GET")
+	private.HandleFunc("/rule", s.handleRuleCreate()).Methods("POST")
+	private.HandleFunc("/rule/run", s.handleRuleRun()).Methods("POST")
+	private.HandleFunc("/rule/{id:[0-9]+}", s.handleRuleGet()).Methods("GET")
+	private.HandleFunc("/rule/{id:[0-9]+}", s.handleRuleUpdate()).Methods("PUT")
+	private.HandleFunc("/rule/{id:[0-9]+}", s.handleRuleDelete()).Methods("DELETE")
+	//цели
+	private.HandleFunc("/goal", s.handleGoalGetAll()).Methods("GET")
+	private.HandleFunc("/goal", s.handleGoalCreate()).Methods("POST")
+	private.HandleFunc("/goal/{id:[0-9]+}", s.handleGoalGet()).Methods("GET")
+	private.HandleFunc("/goal/{id:[0-9]+}", s.handleGoalUpdate()).Methods("PUT")
+	private.HandleFunc("/goal/{id:[0-9]+}", s.handleGoalDelete()).Methods("DELETE")
+	private.HandleFunc("/goal/{id:[0-9]+}/progress", s.handleGoalProgress()).Methods("GET")
+	//вебхуки
+	private.HandleFunc("/webhook", s.handleWebhookGetAll()).Methods("GET")
+	private.HandleFunc("/webhook", s.handleWebhookCreate()).Methods("POST")
+	private.HandleFunc("/webhook/{id:[0-9]+}", s.handleWebhookDelete()).Methods("DELETE")
+	private.HandleFunc("/webhook/{id:[0-9]+}/delivery", s.handleWebhookDeliveries()).Methods("GET")
 }
 
+// storeFor returns the store to use while serving r. Changes made through it
+// are written to the audit log on behalf of the authenticated user
+// and published to the event broker.
+func (s *server) storeFor(r *http.Request) store.Store {
+	u, ok := r.Context().Value(ctxKeyUser).(*model.User)
+	if !ok {
+		return s.store
+	}
+	requestID, _ := r.Context().Value(ctxKeyRequestID).(string)
+	return eventstore.New(s.store.WithActor(u.ID, requestID), s.broker)
+}
+
+// error responds with a problem describing err. Known errors get their own
+// status, code is used for the rest. err may be nil.
 func (s *server) error(w http.ResponseWriter, r *http.Request, code int, err error) {
-	s.respond(w, r, code, map[string]string{"error": err.Error()})
+	p := newProblem(r, code, err)
+	if p.Status >= http.StatusInternalServerError {
+		s.logger.WithField("request_id", p.RequestID).Errorf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	s.respond(w, r, p.Status, p)
 }
 
 func (s *server) respond(w http.ResponseWriter, r *http.Request, code int, data interface{}) {
@@ -1,15 +1,20 @@
 package apiserver
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Aza-9798/costs-rest-api/internal/app/model"
 	"github.com/Aza-9798/costs-rest-api/internal/app/store/teststore"
+	"github.com/gorilla/mux"
 	"github.com/gorilla/securecookie"
 	"github.com/gorilla/sessions"
 	"github.com/stretchr/testify/assert"
@@ -160,6 +165,42 @@ func TestServer_HandleSessionCreate(t *testing.T) {
 	}
 }
 
+func TestServer_HandleSessionCreateRehash(t *testing.T) {
+	defer model.SetPasswordPolicy(model.DefaultPasswordPolicy())
+	st := teststore.New()
+	u := model.TestUser(t)
+	password := u.Password
+	st.User().Create(u)
+	oldHash := u.EncryptedPassword
+
+	assert.NoError(t, model.SetPasswordPolicy(&model.PasswordPolicy{
+		Algorithm:     model.Argon2idAlgorithm,
+		Argon2Time:    1,
+		Argon2Memory:  1024,
+		Argon2Threads: 1,
+	}))
+	s, err := newServer(st, sessions.NewCookieStore([]byte("secret")), logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	b := &bytes.Buffer{}
+	json.NewEncoder(b).Encode(map[string]string{
+		"email":    u.Email,
+		"password": password,
+	})
+	req, _ := http.NewRequest(http.MethodPost, "/session", b)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	u1, err := st.User().FindByEmail(u.Email)
+	assert.NoError(t, err)
+	assert.NotEqual(t, oldHash, u1.EncryptedPassword)
+	assert.False(t, u1.NeedsRehash())
+	assert.True(t, u1.ComparePassword(password))
+	assert.Empty(t, u1.Password)
+}
+
 func TestServer_HandelAccountCreate(t *testing.T) {
 	st := teststore.New()
 	u := model.TestUser(t)
@@ -229,3 +270,907 @@ func TestServer_HandelAccountCreate(t *testing.T) {
 		})
 	}
 }
+
+func testSession(t *testing.T, s *server, email, password string) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	b := &bytes.Buffer{}
+	json.NewEncoder(b).Encode(map[string]string{
+		"email":    email,
+		"password": password,
+	})
+	req, _ := http.NewRequest(http.MethodPost, "/session", b)
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login failed with %d", rec.Code)
+	}
+	return rec.Header().Get("Set-Cookie")
+}
+
+// testRequest sends a JSON request to the private API in a user's session.
+type testRequest func(method, path string, payload interface{}) *httptest.ResponseRecorder
+
+// testServer returns a server over an empty teststore, a user that is
+// already logged in and a function sending requests on their behalf.
+func testServer(t *testing.T) (*server, *model.User, testRequest) {
+	t.Helper()
+	st := teststore.New()
+	u := model.TestUser(t)
+	password := u.Password
+	st.User().Create(u)
+	s, err := newServer(st, sessions.NewCookieStore([]byte("secret")), logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, u, testRequester(s, testSession(t, s, u.Email, password))
+}
+
+// testRequester returns a testRequest that sends the given session cookie.
+func testRequester(s *server, cookie string) testRequest {
+	return func(method, path string, payload interface{}) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		b := &bytes.Buffer{}
+		if payload != nil {
+			json.NewEncoder(b).Encode(payload)
+		}
+		req, _ := http.NewRequest(method, "/api/v1/private"+path, b)
+		req.Header.Set("Cookie", cookie)
+		//ETag проверяется в отдельных тестах
+		req.Header.Set("If-Match", "*")
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+}
+
+func TestServer_AccountSharing(t *testing.T) {
+	svr, owner, ownerDo := testServer(t)
+	st := svr.store
+	member := model.TestUser(t)
+	member.Email = "member@example.org"
+	memberPassword := member.Password
+	st.User().Create(member)
+	memberDo := testRequester(svr, testSession(t, svr, member.Email, memberPassword))
+	a := model.TestAccount(t, owner)
+	st.Account().Create(a)
+
+	do := func(method, path string, as testRequest, payload interface{}) int {
+		return as(method, path, payload).Code
+	}
+	accountPath := fmt.Sprintf("/account/%d", a.ID)
+	update := map[string]interface{}{
+		"name":    "Joint card",
+		"type":    model.CurrentAccount,
+		"balance": a.Balance,
+	}
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, accountPath, memberDo, nil))
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, accountPath+"/member", memberDo, map[string]string{
+		"email": member.Email,
+		"role":  model.EditorRole,
+	}))
+
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, accountPath+"/member", ownerDo, map[string]string{
+		"email": member.Email,
+		"role":  model.ViewerRole,
+	}))
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, accountPath, memberDo, nil))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPut, accountPath, memberDo, update))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, accountPath, memberDo, nil))
+
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, accountPath+"/member", ownerDo, map[string]string{
+		"email": member.Email,
+		"role":  model.EditorRole,
+	}))
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, accountPath, memberDo, update))
+
+	accounts, err := st.Account().GetAllByUser(member.ID, false)
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 1)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, do(http.MethodPost, accountPath+"/member", ownerDo, map[string]string{
+		"email": owner.Email,
+		"role":  model.EditorRole,
+	}))
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, fmt.Sprintf("%s/member/%d", accountPath, member.ID), memberDo, nil))
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, accountPath, memberDo, nil))
+}
+
+func TestServer_HandleAccountUpdateIfMatch(t *testing.T) {
+	st := teststore.New()
+	u := model.TestUser(t)
+	password := u.Password
+	st.User().Create(u)
+	a := model.TestAccount(t, u)
+	st.Account().Create(a)
+
+	svr, err := newServer(st, sessions.NewCookieStore([]byte("secret")), logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie := testSession(t, svr, u.Email, password)
+	accountPath := fmt.Sprintf("/private/account/%d", a.ID)
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, accountPath, nil)
+	req.Header.Set("Cookie", cookie)
+	svr.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get("ETag")
+	assert.Equal(t, `"1"`, etag)
+
+	update := func(ifMatch string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		b := &bytes.Buffer{}
+		json.NewEncoder(b).Encode(map[string]interface{}{
+			"name":    "Renamed",
+			"type":    model.CurrentAccount,
+			"balance": a.Balance,
+		})
+		req, _ := http.NewRequest(http.MethodPut, accountPath, b)
+		req.Header.Set("Cookie", cookie)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		svr.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusPreconditionRequired, update("").Code)
+	assert.Equal(t, http.StatusBadRequest, update("1").Code)
+
+	rec = update(etag)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+	assert.Equal(t, http.StatusPreconditionFailed, update(etag).Code)
+}
+
+func TestServer_HandleAccountPatch(t *testing.T) {
+	st := teststore.New()
+	u := model.TestUser(t)
+	password := u.Password
+	st.User().Create(u)
+	a := model.TestAccount(t, u)
+	a.Description = "keep me"
+	st.Account().Create(a)
+
+	svr, err := newServer(st, sessions.NewCookieStore([]byte("secret")), logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie := testSession(t, svr, u.Email, password)
+	patch := func(contentType string, payload interface{}) int {
+		rec := httptest.NewRecorder()
+		b := &bytes.Buffer{}
+		json.NewEncoder(b).Encode(payload)
+		req, _ := http.NewRequest(http.MethodPatch, fmt.Sprintf("/private/account/%d", a.ID), b)
+		req.Header.Set("Cookie", cookie)
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("If-Match", "*")
+		svr.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, patch(mergePatchType, map[string]interface{}{"name": "Renamed"}))
+	a1, err := st.Account().Find(a.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Renamed", a1.Name)
+	assert.Equal(t, "keep me", a1.Description)
+	assert.Equal(t, a.Balance, a1.Balance)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, patch(mergePatchType, map[string]interface{}{"balance": 100}))
+	assert.Equal(t, http.StatusUnprocessableEntity, patch(mergePatchType, map[string]interface{}{"type": model.SavingAccount}))
+	assert.Equal(t, http.StatusUnprocessableEntity, patch(mergePatchType, map[string]interface{}{"name": nil}))
+	assert.Equal(t, http.StatusUnsupportedMediaType, patch("text/plain", map[string]interface{}{"name": "x"}))
+}
+
+func TestServer_HandleAccountArchive(t *testing.T) {
+	svr, u, request := testServer(t)
+	a := model.TestAccount(t, u)
+	svr.store.Account().Create(a)
+	accountPath := fmt.Sprintf("/account/%d", a.ID)
+
+	assert.Equal(t, http.StatusOK, request(http.MethodPost, accountPath+"/archive", nil).Code)
+	rec := request(http.MethodPost, accountPath+"/archive", nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "account_archived")
+
+	assert.Equal(t, http.StatusOK, request(http.MethodPost, accountPath+"/unarchive", nil).Code)
+	rec = request(http.MethodPost, accountPath+"/unarchive", nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "account_not_archived")
+
+	assert.Equal(t, http.StatusNotFound, request(http.MethodPost, fmt.Sprintf("/account/%d/archive", a.ID+1), nil).Code)
+}
+
+func TestServer_HandleAccountPatchAfterTransaction(t *testing.T) {
+	svr, u, request := testServer(t)
+	a := model.TestAccount(t, u)
+	svr.store.Account().Create(a)
+	food := model.TestAccount(t, u)
+	food.Type = model.ExpenseCatogoryAccount
+	food.Balance = 0
+	svr.store.Account().Create(food)
+	accountPath := fmt.Sprintf("/account/%d", a.ID)
+
+	rec := request(http.MethodGet, accountPath, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get("ETag")
+	assert.Equal(t, http.StatusCreated, request(http.MethodPost, "/transaction", map[string]interface{}{
+		"source":      a.ID,
+		"destination": food.ID,
+		"amount":      10,
+		"type":        model.ExpenseTransaction,
+	}).Code)
+
+	//операция по счету не меняет его версию
+	rec = request(http.MethodGet, accountPath, nil)
+	assert.Equal(t, etag, rec.Header().Get("ETag"))
+	b := &bytes.Buffer{}
+	json.NewEncoder(b).Encode(map[string]interface{}{"name": "Renamed"})
+	req, _ := http.NewRequest(http.MethodPatch, "/api/v1/private"+accountPath, b)
+	req.Header.Set("Cookie", testSession(t, svr, u.Email, model.TestUser(t).Password))
+	req.Header.Set("Content-Type", mergePatchType)
+	req.Header.Set("If-Match", etag)
+	rec = httptest.NewRecorder()
+	svr.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	a1, err := svr.store.Account().Find(a.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Renamed", a1.Name)
+	assert.Equal(t, 90.0, a1.Balance)
+}
+
+func TestServer_IdempotentTransactionCreate(t *testing.T) {
+	st := teststore.New()
+	u := model.TestUser(t)
+	password := u.Password
+	st.User().Create(u)
+	source := model.TestAccount(t, u)
+	st.Account().Create(source)
+	destination := model.TestAccount(t, u)
+	destination.Balance = 0
+	st.Account().Create(destination)
+
+	svr, err := newServer(st, sessions.NewCookieStore([]byte("secret")), logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie := testSession(t, svr, u.Email, password)
+	create := func(key string, amount float64) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		b := &bytes.Buffer{}
+		json.NewEncoder(b).Encode(map[string]interface{}{
+			"source":      source.ID,
+			"destination": destination.ID,
+			"amount":      amount,
+			"type":        model.StandardTransaction,
+		})
+		req, _ := http.NewRequest(http.MethodPost, "/private/transaction", b)
+		req.Header.Set("Cookie", cookie)
+		req.Header.Set("Idempotency-Key", key)
+		svr.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := create("retry-me", 10)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	second := create("retry-me", 10)
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+
+	assert.Equal(t, http.StatusUnprocessableEntity, create("retry-me", 20).Code)
+
+	transactions, err := st.Transaction().GetAllByAccount(source.ID)
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 1)
+	assert.Equal(t, float64(90), source.Balance)
+}
+
+func TestServer_ErrorProblem(t *testing.T) {
+	svr, _, request := testServer(t)
+	do := func(method, path string, payload interface{}) (*httptest.ResponseRecorder, *problem) {
+		rec := request(method, path, payload)
+		p := &problem{}
+		json.NewDecoder(rec.Body).Decode(p)
+		return rec, p
+	}
+
+	rec, p := do(http.MethodPost, "/account", map[string]interface{}{"type": model.CurrentAccount})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, problemContentType, rec.Header().Get("Content-Type"))
+	assert.Equal(t, "validation_failed", p.Code)
+	assert.Equal(t, rec.Header().Get("X-Request-ID"), p.RequestID)
+	if assert.Len(t, p.Errors, 1) {
+		assert.Equal(t, "name", p.Errors[0].Field)
+	}
+
+	rec, p = do(http.MethodGet, "/account/42", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "not_found", p.Code)
+	assert.Equal(t, "/api/v1/private/account/42", p.Instance)
+
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	svr.error(rec, req, http.StatusInternalServerError, nil)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
+
+func TestServer_HandleDocAsset(t *testing.T) {
+	s, err := newServer(teststore.New(), sessions.NewCookieStore([]byte("secret")), logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name         string
+		path         string
+		expectedCode int
+	}{
+		{
+			name:         "stylesheet",
+			path:         "/docs/swagger-ui.css",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "script",
+			path:         "/docs/swagger-ui-bundle.js",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "unknown",
+			path:         "/docs/missing.js",
+			expectedCode: http.StatusNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, tc.path, nil)
+			s.ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+		})
+	}
+}
+
+func TestServer_OpenAPICoversRoutes(t *testing.T) {
+	s, err := newServer(teststore.New(), sessions.NewCookieStore([]byte("secret")), logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/openapi.json", nil)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	spec := struct {
+		Paths map[string]map[string]interface{} `json:"paths"`
+	}{}
+	if err := json.NewDecoder(rec.Body).Decode(&spec); err != nil {
+		t.Fatal(err)
+	}
+
+	//{id:[0-9]+} в mux соответствует {id} в OpenAPI
+	pathVar := regexp.MustCompile(`\{(\w+):[^}]*\}`)
+	err = s.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		//старые пути совпадают с путями /api/v1 без префикса
+		path := pathVar.ReplaceAllString(strings.TrimPrefix(tpl, "/api/v1"), "{$1}")
+		for _, m := range methods {
+			_, ok := spec.Paths[path][strings.ToLower(m)]
+			assert.True(t, ok, "%s %s is missing from openapi.json", m, path)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestServer_LegacyRoutes(t *testing.T) {
+	s, err := newServer(teststore.New(), sessions.NewCookieStore([]byte("secret")), logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	register := func(path, email string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		b := &bytes.Buffer{}
+		json.NewEncoder(b).Encode(map[string]string{
+			"email":    email,
+			"password": "password",
+		})
+		req, _ := http.NewRequest(http.MethodPost, path, b)
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := register("/api/v1/user", "v1@example.org")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, rec.Header().Get("Deprecation"))
+
+	rec = register("/user", "legacy@example.org")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get("Deprecation"))
+	assert.Equal(t, s.legacySunset.Format(http.TimeFormat), rec.Header().Get("Sunset"))
+	assert.Equal(t, `</api/v1/user>; rel="successor-version"`, rec.Header().Get("Link"))
+
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/private/account/all", nil)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestServer_HandleTransactionBatch(t *testing.T) {
+	svr, u, do := testServer(t)
+	st := svr.store
+	card := model.TestAccount(t, u)
+	st.Account().Create(card)
+	food := model.TestAccount(t, u)
+	food.Type = model.ExpenseCatogoryAccount
+	food.Balance = 0
+	st.Account().Create(food)
+
+	item := func(amount float64, tType string) map[string]interface{} {
+		return map[string]interface{}{
+			"source":      card.ID,
+			"destination": food.ID,
+			"amount":      amount,
+			"type":        tType,
+		}
+	}
+	post := func(query string, items ...map[string]interface{}) (int, map[string]interface{}) {
+		rec := do(http.MethodPost, "/transaction/batch"+query, items)
+		res := map[string]interface{}{}
+		json.NewDecoder(rec.Body).Decode(&res)
+		return rec.Code, res
+	}
+
+	code, res := post("", item(10, model.ExpenseTransaction), item(10, model.IncomeTransaction))
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Equal(t, float64(1), res["failed"])
+	assert.Equal(t, 100.0, card.Balance)
+
+	code, res = post("?mode=partial", item(10, model.ExpenseTransaction), item(10, model.IncomeTransaction), item(500, model.ExpenseTransaction))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(1), res["created"])
+	assert.Equal(t, float64(2), res["failed"])
+	assert.Equal(t, 90.0, card.Balance)
+
+	code, res = post("", item(10, model.ExpenseTransaction), item(20, model.ExpenseTransaction))
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, float64(2), res["created"])
+	assert.Equal(t, 60.0, card.Balance)
+
+	code, _ = post("?mode=maybe", item(10, model.ExpenseTransaction))
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestServer_HandleEvents(t *testing.T) {
+	st := teststore.New()
+	u := model.TestUser(t)
+	password := u.Password
+	st.User().Create(u)
+	card := model.TestAccount(t, u)
+	st.Account().Create(card)
+	food := model.TestAccount(t, u)
+	food.Type = model.ExpenseCatogoryAccount
+	food.Balance = 0
+	st.Account().Create(food)
+
+	svr, err := newServer(st, sessions.NewCookieStore([]byte("secret")), logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie := testSession(t, svr, u.Email, password)
+	ts := httptest.NewServer(svr)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/v1/private/events", nil)
+	req.Header.Set("Cookie", cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	b := &bytes.Buffer{}
+	json.NewEncoder(b).Encode(map[string]interface{}{
+		"source":      card.ID,
+		"destination": food.ID,
+		"amount":      25,
+		"type":        model.ExpenseTransaction,
+	})
+	req, _ = http.NewRequest(http.MethodPost, ts.URL+"/api/v1/private/transaction", b)
+	req.Header.Set("Cookie", cookie)
+	created, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created.Body.Close()
+	assert.Equal(t, http.StatusCreated, created.StatusCode)
+
+	scanner := bufio.NewScanner(resp.Body)
+	eventTypes := make([]string, 0)
+	for len(eventTypes) < 3 && scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "event: ") {
+			eventTypes = append(eventTypes, strings.TrimPrefix(line, "event: "))
+		}
+	}
+	assert.Equal(t, []string{"transaction.created", "account.balance", "account.balance"}, eventTypes)
+}
+
+func TestServer_Webhooks(t *testing.T) {
+	svr, _, userDo := testServer(t)
+	other := model.TestUser(t)
+	other.Email = "other@example.org"
+	otherPassword := other.Password
+	svr.store.User().Create(other)
+	otherDo := testRequester(svr, testSession(t, svr, other.Email, otherPassword))
+	do := func(method, path string, as testRequest, payload interface{}) (int, []byte) {
+		rec := as(method, path, payload)
+		return rec.Code, rec.Body.Bytes()
+	}
+
+	code, _ := do(http.MethodPost, "/webhook", userDo, map[string]interface{}{
+		"url":    "https://example.org/hook",
+		"events": []string{"transaction.exploded"},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+
+	code, _ = do(http.MethodPost, "/webhook", userDo, map[string]interface{}{
+		"url":    "http://169.254.169.254/latest/meta-data",
+		"events": []string{"transaction.created"},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+
+	code, body := do(http.MethodPost, "/webhook", userDo, map[string]interface{}{
+		"url":    "https://example.org/hook",
+		"events": []string{"transaction.created"},
+	})
+	assert.Equal(t, http.StatusCreated, code)
+	created := &model.Webhook{}
+	json.Unmarshal(body, created)
+	assert.NotEmpty(t, created.Secret)
+
+	code, body = do(http.MethodGet, "/webhook", userDo, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotContains(t, string(body), created.Secret)
+
+	path := fmt.Sprintf("/webhook/%d", created.ID)
+	code, _ = do(http.MethodGet, path+"/delivery", otherDo, nil)
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = do(http.MethodGet, path+"/delivery", userDo, nil)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = do(http.MethodDelete, path, otherDo, nil)
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = do(http.MethodDelete, path, userDo, nil)
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestServer_HandleReportCategories(t *testing.T) {
+	svr, u, do := testServer(t)
+	st := svr.store
+	card := model.TestAccount(t, u)
+	st.Account().Create(card)
+	food := model.TestAccount(t, u)
+	food.Name = "Food"
+	food.Type = model.ExpenseCatogoryAccount
+	food.Balance = 0
+	st.Account().Create(food)
+	rent := model.TestAccount(t, u)
+	rent.Name = "Rent"
+	rent.Type = model.ExpenseCatogoryAccount
+	rent.Balance = 0
+	st.Account().Create(rent)
+	for _, tr := range []*model.TransactionDB{
+		{TransactionDate: time.Date(2023, time.February, 20, 0, 0, 0, 0, time.UTC), Source: card, Destination: food, Amount: 20, Type: model.ExpenseTransaction},
+		{TransactionDate: time.Date(2023, time.March, 5, 0, 0, 0, 0, time.UTC), Source: card, Destination: food, Amount: 30, Type: model.ExpenseTransaction},
+		{TransactionDate: time.Date(2023, time.March, 6, 0, 0, 0, 0, time.UTC), Source: card, Destination: rent, Amount: 10, Type: model.ExpenseTransaction},
+	} {
+		st.Transaction().Create(tr)
+	}
+
+	get := func(query string) (int, *model.CategoryBreakdown) {
+		rec := do(http.MethodGet, "/report/categories"+query, nil)
+		res := &model.CategoryBreakdown{}
+		json.NewDecoder(rec.Body).Decode(res)
+		return rec.Code, res
+	}
+
+	code, res := get("?from=2023-03-01&to=2023-03-31")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, time.Date(2023, time.January, 29, 0, 0, 0, 0, time.UTC), res.PreviousStart)
+	assert.Equal(t, 40.0, res.Expense.Total)
+	assert.Equal(t, 20.0, res.Expense.PreviousTotal)
+	assert.Len(t, res.Expense.Categories, 2)
+	assert.Equal(t, "Food", res.Expense.Categories[0].Name)
+	assert.Equal(t, 75.0, res.Expense.Categories[0].Percent)
+	assert.Equal(t, 50.0, *res.Expense.Categories[0].ChangePercent)
+	assert.Nil(t, res.Expense.Categories[1].ChangePercent)
+	assert.Empty(t, res.Income.Categories)
+
+	code, _ = get("?from=2023-03-31&to=2023-03-01")
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	code, _ = get("?from=2023-03-01")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestServer_HandleReportForecast(t *testing.T) {
+	svr, u, do := testServer(t)
+	st := svr.store
+	card := model.TestAccount(t, u)
+	card.Balance = 1000
+	st.Account().Create(card)
+	gym := model.TestAccount(t, u)
+	gym.Type = model.ExpenseCatogoryAccount
+	gym.Balance = 0
+	st.Account().Create(gym)
+
+	get := func(query string) (int, *model.Forecast) {
+		rec := do(http.MethodGet, "/report/forecast"+query, nil)
+		res := &model.Forecast{}
+		json.NewDecoder(rec.Body).Decode(res)
+		return rec.Code, res
+	}
+
+	//без повторяющихся операций баланс не меняется
+	code, res := get("?days=10&threshold=900")
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotNil(t, res.Recurring)
+	assert.Empty(t, res.Recurring)
+	assert.Empty(t, res.Warnings)
+	assert.Len(t, res.Accounts, 1)
+	assert.Len(t, res.Accounts[0].Days, 10)
+	for _, d := range res.Accounts[0].Days {
+		assert.Equal(t, 1000.0, d.Balance)
+	}
+
+	today := model.TruncateDate(time.Now(), model.IntervalDay)
+	for _, weeks := range []int{3, 2, 1} {
+		st.Transaction().Create(&model.TransactionDB{
+			TransactionDate: today.AddDate(0, 0, -7*weeks),
+			Source:          card,
+			Destination:     gym,
+			Amount:          30,
+			Type:            model.ExpenseTransaction,
+		})
+	}
+	code, res = get("?days=10&threshold=900")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, res.Recurring, 1)
+	assert.Equal(t, model.RecurrenceWeekly, res.Recurring[0].Interval)
+	days := res.Accounts[0].Days
+	assert.Equal(t, 910.0, days[5].Balance)
+	assert.Equal(t, 880.0, days[6].Balance)
+	assert.Equal(t, 880.0, days[9].Balance)
+	assert.Len(t, res.Warnings, 1)
+	assert.Equal(t, today.AddDate(0, 0, 7), res.Warnings[0].Date)
+
+	code, _ = get("?days=0")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = get("?days=366")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = get("?threshold=low")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestServer_HandleAnomalySettings(t *testing.T) {
+	_, _, request := testServer(t)
+	do := func(method string, payload interface{}) (int, *model.AnomalySettings) {
+		rec := request(method, "/report/anomalies/settings", payload)
+		res := &model.AnomalySettings{}
+		json.NewDecoder(rec.Body).Decode(res)
+		return rec.Code, res
+	}
+
+	code, res := do(http.MethodGet, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 6, res.LookbackPeriods)
+
+	settings := map[string]interface{}{
+		"lookback_periods":      12,
+		"category_threshold":    1.5,
+		"min_change_percent":    10,
+		"transaction_threshold": 2.5,
+	}
+	code, _ = do(http.MethodPut, settings)
+	assert.Equal(t, http.StatusOK, code)
+	_, res = do(http.MethodGet, nil)
+	assert.Equal(t, 12, res.LookbackPeriods)
+	assert.Equal(t, 1.5, res.CategoryThreshold)
+
+	settings["lookback_periods"] = 100
+	code, _ = do(http.MethodPut, settings)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+
+	//нулевой порог отключает проверку
+	settings["lookback_periods"] = 12
+	settings["category_threshold"] = 0
+	settings["transaction_threshold"] = 0
+	code, _ = do(http.MethodPut, settings)
+	assert.Equal(t, http.StatusOK, code)
+	_, res = do(http.MethodGet, nil)
+	assert.Equal(t, 0.0, res.CategoryThreshold)
+	assert.Equal(t, 0.0, res.TransactionThreshold)
+
+	settings["transaction_threshold"] = -1
+	code, _ = do(http.MethodPut, settings)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+}
+
+func TestServer_HandleGoals(t *testing.T) {
+	svr, u, do := testServer(t)
+	st := svr.store
+	current := model.TestAccount(t, u)
+	st.Account().Create(current)
+	saving := model.TestAccount(t, u)
+	saving.Type = model.SavingAccount
+	st.Account().Create(saving)
+	goal := map[string]interface{}{
+		"account":       current.ID,
+		"name":          "Vacation",
+		"target_amount": saving.Balance * 2,
+		"target_date":   time.Now().AddDate(1, 0, 0),
+	}
+
+	rec := do(http.MethodPost, "/goal", goal)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "not_saving_account")
+
+	goal["account"] = saving.ID
+	rec = do(http.MethodPost, "/goal", goal)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	created := &model.Goal{}
+	json.NewDecoder(rec.Body).Decode(created)
+
+	rec = do(http.MethodGet, fmt.Sprintf("/goal/%d/progress", created.ID), nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	progress := &model.GoalProgress{}
+	json.NewDecoder(rec.Body).Decode(progress)
+	assert.Equal(t, 50.0, progress.Percent)
+	assert.Equal(t, saving.Balance, progress.Remaining)
+
+	goal["account"] = current.ID
+	goal["target_amount"] = saving.Balance
+	rec = do(http.MethodPut, fmt.Sprintf("/goal/%d", created.ID), goal)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	updated, _ := st.Goal().Find(created.ID)
+	assert.Equal(t, saving.ID, updated.Account)
+	assert.Equal(t, saving.Balance, updated.TargetAmount)
+
+	rec = do(http.MethodDelete, fmt.Sprintf("/goal/%d", created.ID), nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = do(http.MethodGet, fmt.Sprintf("/goal/%d", created.ID), nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestServer_HandleRules(t *testing.T) {
+	svr, u, do := testServer(t)
+	st := svr.store
+	card := model.TestAccount(t, u)
+	st.Account().Create(card)
+	food := model.TestAccount(t, u)
+	food.Type = model.ExpenseCatogoryAccount
+	food.Balance = 0
+	st.Account().Create(food)
+	cafe := model.TestAccount(t, u)
+	cafe.Type = model.ExpenseCatogoryAccount
+	cafe.Balance = 0
+	st.Account().Create(cafe)
+
+	rec := do(http.MethodPost, "/rule", map[string]interface{}{"name": "Food"})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "rule_without_condition")
+
+	rec = do(http.MethodPost, "/rule", map[string]interface{}{
+		"name":                 "Food",
+		"description_contains": "market",
+		"destination":          food.ID,
+		"type":                 model.ExpenseTransaction,
+		"tags":                 []string{"groceries"},
+	})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = do(http.MethodPost, "/transaction", map[string]interface{}{
+		"source":      card.ID,
+		"amount":      10,
+		"description": "Market",
+	})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	created := &model.TransactionJSON{}
+	json.NewDecoder(rec.Body).Decode(created)
+	assert.Equal(t, food.ID, created.Destination)
+	assert.Equal(t, []string{"groceries"}, created.Tags)
+
+	rec = do(http.MethodPost, "/rule", map[string]interface{}{
+		"name":                 "Cafe",
+		"priority":             -1,
+		"description_contains": "market",
+		"destination":          cafe.ID,
+	})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	type run struct {
+		DryRun  bool `json:"dry_run"`
+		Applied int  `json:"applied"`
+		Changes []*model.RuleChange
+	}
+	rec = do(http.MethodPost, "/rule/run", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	res := &run{}
+	json.NewDecoder(rec.Body).Decode(res)
+	assert.True(t, res.DryRun)
+	assert.Len(t, res.Changes, 1)
+	assert.Equal(t, cafe.ID, res.Changes[0].After.Destination)
+	stored, _ := st.Transaction().Find(created.ID)
+	assert.Equal(t, food.ID, stored.Destination)
+
+	rec = do(http.MethodPost, "/rule/run?dry_run=false", nil)
+	res = &run{}
+	json.NewDecoder(rec.Body).Decode(res)
+	assert.Equal(t, 1, res.Applied)
+	stored, _ = st.Transaction().Find(created.ID)
+	assert.Equal(t, cafe.ID, stored.Destination)
+}
+
+func TestServer_HandleDuplicates(t *testing.T) {
+	svr, u, do := testServer(t)
+	st := svr.store
+	card := model.TestAccount(t, u)
+	st.Account().Create(card)
+	food := model.TestAccount(t, u)
+	food.Type = model.ExpenseCatogoryAccount
+	food.Balance = 0
+	st.Account().Create(food)
+	for _, description := range []string{"Coffee", "Coffee", "Coffee shop"} {
+		st.Transaction().Create(&model.TransactionDB{
+			TransactionDate: time.Now(),
+			Source:          card,
+			Destination:     food,
+			Amount:          5,
+			Type:            model.ExpenseTransaction,
+			Description:     description,
+		})
+	}
+	assert.Equal(t, 85.0, card.Balance)
+
+	rec := do(http.MethodGet, "/transaction/duplicates", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	pending := make([]*model.DuplicateCandidate, 0)
+	json.NewDecoder(rec.Body).Decode(&pending)
+	assert.Len(t, pending, 3)
+
+	rec = do(http.MethodPost, fmt.Sprintf("/transaction/duplicates/%d/merge", pending[0].ID), map[string]int{"keep": food.ID + 100})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = do(http.MethodPost, fmt.Sprintf("/transaction/duplicates/%d/merge", pending[0].ID), nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 90.0, card.Balance)
+	_, err := st.Transaction().FindDeleted(pending[0].Transaction.ID)
+	assert.NoError(t, err)
+
+	rec = do(http.MethodPost, fmt.Sprintf("/transaction/duplicates/%d/dismiss", pending[0].ID), nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	//повторное слияние не удаляет второй перевод пары
+	rec = do(http.MethodPost, fmt.Sprintf("/transaction/duplicates/%d/merge", pending[0].ID), map[string]int{"keep": pending[0].Transaction.ID})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, 90.0, card.Balance)
+
+	rec = do(http.MethodGet, "/transaction/duplicates", nil)
+	pending = make([]*model.DuplicateCandidate, 0)
+	json.NewDecoder(rec.Body).Decode(&pending)
+	assert.Len(t, pending, 1)
+	rec = do(http.MethodPost, fmt.Sprintf("/transaction/duplicates/%d/dismiss", pending[0].ID), nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), model.DuplicateDismissed)
+	assert.Equal(t, 90.0, card.Balance)
+
+	rec = do(http.MethodPost, fmt.Sprintf("/transaction/duplicates/%d/merge", pending[0].ID), nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, 90.0, card.Balance)
+}
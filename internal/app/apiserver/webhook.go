@@ -0,0 +1,108 @@
+package apiserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+	"github.com/gorilla/mux"
+)
+
+const webhookSecretLength = 32
+
+func (s *server) handleWebhookGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		res, err := s.store.Webhook().GetAllByUser(u.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		for _, hook := range res {
+			hook.Sanitize()
+		}
+		s.respond(w, r, http.StatusOK, res)
+	}
+}
+
+// handleWebhookCreate registers a webhook. The response is the only place
+// where the signing secret is shown.
+func (s *server) handleWebhookCreate() http.HandlerFunc {
+	type request struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &request{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		secret := make([]byte, webhookSecretLength)
+		if _, err := rand.Read(secret); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		u := r.Context().Value(ctxKeyUser).(*model.User)
+		hook := &model.Webhook{
+			User:   u.ID,
+			URL:    req.URL,
+			Secret: hex.EncodeToString(secret),
+			Events: req.Events,
+		}
+		if err := s.store.Webhook().Create(hook); err != nil {
+			s.error(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+		s.respond(w, r, http.StatusCreated, hook)
+	}
+}
+
+func (s *server) handleWebhookDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hook, err := s.findWebhook(r)
+		if err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		if err := s.store.Webhook().Delete(hook.ID); err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, nil)
+	}
+}
+
+func (s *server) handleWebhookDeliveries() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hook, err := s.findWebhook(r)
+		if err != nil {
+			s.authorizationError(w, r, err)
+			return
+		}
+		res, err := s.store.Webhook().GetDeliveries(hook.ID)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, res)
+	}
+}
+
+// findWebhook loads the webhook from the route. Webhooks of other users are not found.
+func (s *server) findWebhook(r *http.Request) (*model.Webhook, error) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	hook, err := s.store.Webhook().Find(id)
+	if err != nil {
+		return nil, err
+	}
+	u := r.Context().Value(ctxKeyUser).(*model.User)
+	if hook.User != u.ID {
+		return nil, store.ErrRecordNotFound
+	}
+	return hook, nil
+}
@@ -0,0 +1,32 @@
+package events
+
+const (
+	AccountBalance      = "account.balance"
+	TransactionCreated  = "transaction.created"
+	TransactionUpdated  = "transaction.updated"
+	TransactionDeleted  = "transaction.deleted"
+	TransactionRestored = "transaction.restored"
+)
+
+// Event is a change pushed to the users in Users.
+type Event struct {
+	Type  string      `json:"type"`
+	Data  interface{} `json:"data"`
+	Users []int       `json:"-"`
+}
+
+// Balance is the data of AccountBalance events.
+type Balance struct {
+	AccountID int     `json:"account_id"`
+	Balance   float64 `json:"balance"`
+	Version   int     `json:"version"`
+}
+
+// Broker fans events out to subscribers. Publish must not block on slow
+// subscribers. The in-process Memory broker serves a single instance,
+// a broker backed by Postgres LISTEN/NOTIFY can replace it for several.
+type Broker interface {
+	Publish(*Event)
+	// Subscribe returns the events for userID until cancel is called.
+	Subscribe(userID int) (events <-chan *Event, cancel func())
+}
@@ -0,0 +1,53 @@
+package events
+
+import "sync"
+
+// Memory is a Broker delivering events within the current process.
+// Events for a subscriber whose buffer is full are dropped.
+type Memory struct {
+	mu     sync.RWMutex
+	buffer int
+	subs   map[int]map[chan *Event]struct{}
+}
+
+func NewMemory(buffer int) *Memory {
+	return &Memory{
+		buffer: buffer,
+		subs:   make(map[int]map[chan *Event]struct{}),
+	}
+}
+
+func (b *Memory) Publish(e *Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, userID := range e.Users {
+		for ch := range b.subs[userID] {
+			select {
+			case ch <- e:
+			default:
+			}
+		}
+	}
+}
+
+func (b *Memory) Subscribe(userID int) (<-chan *Event, func()) {
+	ch := make(chan *Event, b.buffer)
+	b.mu.Lock()
+	if b.subs[userID] == nil {
+		b.subs[userID] = make(map[chan *Event]struct{})
+	}
+	b.subs[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs[userID], ch)
+			if len(b.subs[userID]) == 0 {
+				delete(b.subs, userID)
+			}
+			b.mu.Unlock()
+		})
+	}
+}
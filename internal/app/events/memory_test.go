@@ -0,0 +1,31 @@
+package events_test
+
+import (
+	"testing"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemory_Publish(t *testing.T) {
+	b := events.NewMemory(1)
+	ch1, cancel1 := b.Subscribe(1)
+	ch2, cancel2 := b.Subscribe(2)
+	defer cancel2()
+
+	e := &events.Event{Type: events.TransactionCreated, Users: []int{1}}
+	b.Publish(e)
+	assert.Equal(t, e, <-ch1)
+	assert.Len(t, ch2, 0)
+
+	//переполненный буфер не блокирует публикацию
+	b.Publish(e)
+	b.Publish(e)
+	assert.Len(t, ch1, 1)
+
+	cancel1()
+	cancel1()
+	<-ch1
+	b.Publish(e)
+	assert.Len(t, ch1, 0)
+}
@@ -15,13 +15,23 @@ const (
 )
 
 type Account struct {
-	ID           int       `json:"id"`
-	CreationDate time.Time `json:"-"`
-	User         int       `json:"user"`
-	Name         string    `json:"name"`
-	Type         string    `json:"type"`
-	Description  string    `json:"description"`
-	Balance      float64   `json:"balance"`
+	ID           int        `json:"id"`
+	CreationDate time.Time  `json:"-"`
+	User         int        `json:"user"`
+	Name         string     `json:"name"`
+	Type         string     `json:"type"`
+	Description  string     `json:"description"`
+	Balance      float64    `json:"balance"`
+	Parent       *int       `json:"parent,omitempty"`
+	ArchivedAt   *time.Time `json:"archived_at,omitempty"`
+	Version      int        `json:"version"`
+	// Debt accounts only. A debt balance below zero is the amount owed.
+	CreditLimit    float64 `json:"credit_limit,omitempty"`
+	APR            float64 `json:"apr,omitempty"`
+	MinimumPayment float64 `json:"minimum_payment,omitempty"`
+	DueDay         int     `json:"due_day,omitempty"`
+	// InterestAccruedThrough is the last day interest was charged for.
+	InterestAccruedThrough time.Time `json:"-"`
 }
 
 func (a *Account) Validate() error {
@@ -36,5 +46,31 @@ func (a *Account) Validate() error {
 				IncomeSourceAccount,
 				ExpenseCatogoryAccount,
 			)),
+		validation.Field(&a.Parent, validation.By(validateAccountParent(a))),
+		validation.Field(&a.CreditLimit, validation.Min(0.0), validation.By(validateDebtOnly(a))),
+		validation.Field(&a.APR, validation.Min(0.0), validation.Max(100.0), validation.By(validateDebtOnly(a))),
+		validation.Field(&a.MinimumPayment, validation.Min(0.0), validation.By(validateDebtOnly(a))),
+		validation.Field(&a.DueDay, validation.Min(0), validation.Max(28), validation.By(validateDebtOnly(a))),
 	)
 }
+
+func (a *Account) IsArchived() bool {
+	return a.ArchivedAt != nil
+}
+
+// IsCategory reports whether the account groups income or expense
+// and therefore can be nested under a parent of the same type.
+func (a *Account) IsCategory() bool {
+	return a.Type == IncomeSourceAccount || a.Type == ExpenseCatogoryAccount
+}
+
+// IsAsset reports whether the account holds the user's money.
+func (a *Account) IsAsset() bool {
+	return a.Type == CurrentAccount || a.Type == SavingAccount
+}
+
+// IsLiability reports whether the account balance is owed by the user.
+// A Debt balance goes below zero as money is borrowed.
+func (a *Account) IsLiability() bool {
+	return a.Type == DebtAccount
+}
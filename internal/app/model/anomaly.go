@@ -0,0 +1,235 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+// minTransactionSamples is how many earlier transactions of a category
+// are needed before a single transaction can be called unusual.
+const minTransactionSamples = 3
+
+// AnomalySettings are the user's thresholds for the anomaly report.
+// Category and transaction thresholds are in standard deviations,
+// zero turns the corresponding check off.
+type AnomalySettings struct {
+	User                 int     `json:"-"`
+	LookbackPeriods      int     `json:"lookback_periods"`
+	CategoryThreshold    float64 `json:"category_threshold"`
+	MinChangePercent     float64 `json:"min_change_percent"`
+	TransactionThreshold float64 `json:"transaction_threshold"`
+}
+
+func DefaultAnomalySettings(userID int) *AnomalySettings {
+	return &AnomalySettings{
+		User:                 userID,
+		LookbackPeriods:      6,
+		CategoryThreshold:    2,
+		MinChangePercent:     25,
+		TransactionThreshold: 3,
+	}
+}
+
+func (s *AnomalySettings) Validate() error {
+	return validation.ValidateStruct(
+		s,
+		validation.Field(&s.LookbackPeriods, validation.Required, validation.Min(2), validation.Max(24)),
+		validation.Field(&s.CategoryThreshold, validation.Min(0.0)),
+		validation.Field(&s.MinChangePercent, validation.Min(0.0)),
+		validation.Field(&s.TransactionThreshold, validation.Min(0.0)),
+	)
+}
+
+// LookbackStart returns the first day of the earliest period
+// the report compares the given one with.
+func (s *AnomalySettings) LookbackStart(DateStart, DateEnd time.Time) time.Time {
+	return DateStart.AddDate(0, 0, -periodDays(DateStart, DateEnd)*s.LookbackPeriods)
+}
+
+type CategoryAnomaly struct {
+	ID            int      `json:"id"`
+	Name          string   `json:"name"`
+	Type          string   `json:"type"`
+	Amount        float64  `json:"amount"`
+	Average       float64  `json:"average"`
+	StdDev        float64  `json:"std_dev"`
+	ZScore        *float64 `json:"z_score,omitempty"`
+	ChangePercent *float64 `json:"change_percent,omitempty"`
+	Anomalous     bool     `json:"anomalous"`
+	Message       string   `json:"message,omitempty"`
+}
+
+type TransactionAnomaly struct {
+	Transaction *TransactionJSON `json:"transaction"`
+	Category    int              `json:"category"`
+	Average     float64          `json:"average"`
+	StdDev      float64          `json:"std_dev"`
+	ZScore      float64          `json:"z_score"`
+	Message     string           `json:"message"`
+}
+
+type AnomalyReport struct {
+	DateStart    time.Time             `json:"date_start"`
+	DateEnd      time.Time             `json:"date_end"`
+	Settings     *AnomalySettings      `json:"settings"`
+	Categories   []*CategoryAnomaly    `json:"categories"`
+	Transactions []*TransactionAnomaly `json:"transactions"`
+}
+
+// NewAnomalyReport compares the period with the equal-length periods before it.
+// A category is flagged when its amount differs from the trailing average by
+// at least MinChangePercent and by CategoryThreshold standard deviations.
+// A transaction is flagged when its amount is TransactionThreshold standard
+// deviations above earlier transactions of the same category.
+// ts must cover the period and the lookback, see AnomalySettings.LookbackStart.
+func NewAnomalyReport(DateStart, DateEnd time.Time, settings *AnomalySettings, accounts []*Account, ts []*TransactionJSON) *AnomalyReport {
+	res := &AnomalyReport{
+		DateStart:    DateStart,
+		DateEnd:      DateEnd,
+		Settings:     settings,
+		Categories:   make([]*CategoryAnomaly, 0),
+		Transactions: make([]*TransactionAnomaly, 0),
+	}
+	categories := make(map[int]*Account)
+	for _, a := range accounts {
+		if a.IsCategory() {
+			categories[a.ID] = a
+		}
+	}
+	days := periodDays(DateStart, DateEnd)
+	totals := make(map[int][]float64)
+	history := make(map[int][]float64)
+	current := make([]*TransactionJSON, 0)
+	for _, t := range ts {
+		c := transactionCategory(t, categories)
+		if c == nil {
+			continue
+		}
+		period := 0
+		if date := TruncateDate(t.TransactionDate, IntervalDay); date.Before(DateStart) {
+			period = (int(DateStart.Sub(date).Hours()/24)-1)/days + 1
+		}
+		if period > settings.LookbackPeriods || t.TransactionDate.After(DateEnd) {
+			continue
+		}
+		if totals[c.ID] == nil {
+			totals[c.ID] = make([]float64, settings.LookbackPeriods+1)
+		}
+		totals[c.ID][period] += t.Amount
+		if period == 0 {
+			current = append(current, t)
+		} else {
+			history[c.ID] = append(history[c.ID], t.Amount)
+		}
+	}
+
+	for id, amounts := range totals {
+		c := categories[id]
+		mean, sd := meanStdDev(amounts[1:])
+		a := &CategoryAnomaly{
+			ID:      id,
+			Name:    c.Name,
+			Type:    c.Type,
+			Amount:  amounts[0],
+			Average: round2(mean),
+			StdDev:  round2(sd),
+		}
+		if sd > 0 {
+			z := round2((a.Amount - mean) / sd)
+			a.ZScore = &z
+		}
+		if mean > 0 {
+			change := percent(a.Amount-mean, mean)
+			a.ChangePercent = &change
+			if settings.CategoryThreshold > 0 && math.Abs(change) >= settings.MinChangePercent &&
+				(a.ZScore == nil || math.Abs(*a.ZScore) >= settings.CategoryThreshold) {
+				a.Anomalous = true
+				direction := "above"
+				if change < 0 {
+					direction = "below"
+				}
+				a.Message = fmt.Sprintf("%s is %.0f%% %s your average of the previous %d periods",
+					c.Name, math.Abs(change), direction, settings.LookbackPeriods)
+			}
+		}
+		res.Categories = append(res.Categories, a)
+	}
+	sort.Slice(res.Categories, func(i, j int) bool {
+		if res.Categories[i].Anomalous != res.Categories[j].Anomalous {
+			return res.Categories[i].Anomalous
+		}
+		return res.Categories[i].ID < res.Categories[j].ID
+	})
+
+	if settings.TransactionThreshold == 0 {
+		current = nil
+	}
+	for _, t := range current {
+		c := transactionCategory(t, categories)
+		samples := history[c.ID]
+		if len(samples) < minTransactionSamples {
+			continue
+		}
+		mean, sd := meanStdDev(samples)
+		if sd == 0 {
+			continue
+		}
+		if z := (t.Amount - mean) / sd; z >= settings.TransactionThreshold {
+			res.Transactions = append(res.Transactions, &TransactionAnomaly{
+				Transaction: t,
+				Category:    c.ID,
+				Average:     round2(mean),
+				StdDev:      round2(sd),
+				ZScore:      round2(z),
+				Message: fmt.Sprintf("%.2f in %s is %.1f standard deviations above the usual %.2f",
+					t.Amount, c.Name, z, mean),
+			})
+		}
+	}
+	sort.Slice(res.Transactions, func(i, j int) bool {
+		return res.Transactions[i].ZScore > res.Transactions[j].ZScore
+	})
+	return res
+}
+
+// transactionCategory returns the income source or expense category
+// of the transaction, or nil for transfers.
+func transactionCategory(t *TransactionJSON, categories map[int]*Account) *Account {
+	switch t.Type {
+	case IncomeTransaction:
+		return categories[t.Source]
+	case ExpenseTransaction:
+		return categories[t.Destination]
+	}
+	return nil
+}
+
+// periodDays returns the number of calendar days in the period.
+func periodDays(DateStart, DateEnd time.Time) int {
+	return int(DateEnd.Sub(DateStart).Hours()/24) + 1
+}
+
+// meanStdDev returns the mean and the population standard deviation.
+func meanStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)))
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,81 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAnomalyReport(t *testing.T) {
+	accounts := []*model.Account{
+		{ID: 1, Name: "Card", Type: model.CurrentAccount},
+		{ID: 2, Name: "Restaurants", Type: model.ExpenseCatogoryAccount},
+		{ID: 3, Name: "Groceries", Type: model.ExpenseCatogoryAccount},
+	}
+	from := time.Date(2023, time.July, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, time.July, 30, 0, 0, 0, 0, time.UTC)
+	settings := model.DefaultAnomalySettings(1)
+	settings.LookbackPeriods = 4
+
+	ts := make([]*model.TransactionJSON, 0)
+	expense := func(date time.Time, category int, amount float64) {
+		ts = append(ts, &model.TransactionJSON{
+			ID:              len(ts),
+			TransactionDate: date,
+			Source:          1,
+			Destination:     category,
+			Amount:          amount,
+			Type:            model.ExpenseTransaction,
+		})
+	}
+	//четыре предыдущих периода по 30 дней
+	for k, amount := range []float64{90, 110, 100, 100} {
+		day := from.AddDate(0, 0, -30*(k+1))
+		expense(day, 2, amount/2)
+		expense(day.AddDate(0, 0, 1), 2, amount/2)
+		expense(day, 3, 200)
+	}
+	expense(from, 2, 60)
+	expense(from.AddDate(0, 0, 5), 2, 120)
+	expense(from, 3, 210)
+	//слишком давно
+	expense(from.AddDate(0, 0, -121), 2, 1000)
+
+	assert.Equal(t, from.AddDate(0, 0, -120), settings.LookbackStart(from, to))
+	report := model.NewAnomalyReport(from, to, settings, accounts, ts)
+	assert.Len(t, report.Categories, 2)
+	restaurants := report.Categories[0]
+	assert.Equal(t, 2, restaurants.ID)
+	assert.True(t, restaurants.Anomalous)
+	assert.Equal(t, 100.0, restaurants.Average)
+	assert.Equal(t, 80.0, *restaurants.ChangePercent)
+	assert.Equal(t, "Restaurants is 80% above your average of the previous 4 periods", restaurants.Message)
+	//у продуктов нет разброса, а изменение меньше порога
+	assert.False(t, report.Categories[1].Anomalous)
+	assert.Nil(t, report.Categories[1].ZScore)
+
+	assert.Len(t, report.Transactions, 1)
+	assert.Equal(t, 120.0, report.Transactions[0].Transaction.Amount)
+
+	//нулевые пороги отключают обе проверки
+	settings.CategoryThreshold = 0
+	settings.TransactionThreshold = 0
+	report = model.NewAnomalyReport(from, to, settings, accounts, ts)
+	assert.False(t, report.Categories[0].Anomalous)
+	assert.Empty(t, report.Transactions)
+}
+
+func TestAnomalySettings_Validate(t *testing.T) {
+	s := model.DefaultAnomalySettings(1)
+	assert.NoError(t, s.Validate())
+	s.LookbackPeriods = 1
+	assert.Error(t, s.Validate())
+	s.LookbackPeriods = 6
+	s.CategoryThreshold = -1
+	assert.Error(t, s.Validate())
+	s.CategoryThreshold = 0
+	s.TransactionThreshold = 0
+	assert.NoError(t, s.Validate())
+}
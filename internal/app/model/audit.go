@@ -0,0 +1,65 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditEntityAccount     = "account"
+	AuditEntityTransaction = "transaction"
+
+	AuditCreate       = "create"
+	AuditUpdate       = "update"
+	AuditDelete       = "delete"
+	AuditRestore      = "restore"
+	AuditArchive      = "archive"
+	AuditUnarchive    = "unarchive"
+	AuditAddMember    = "add_member"
+	AuditRemoveMember = "remove_member"
+)
+
+// SystemActor is the actor of changes the server makes on its own,
+// such as accrued interest.
+const SystemActor = 0
+
+// AuditEntry is one record of the append-only audit log.
+// Before and After hold JSON snapshots of the entity and are empty
+// when it did not exist before or after the operation.
+type AuditEntry struct {
+	ID        int             `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Actor     int             `json:"actor"`
+	RequestID string          `json:"request_id"`
+	Entity    string          `json:"entity"`
+	EntityID  int             `json:"entity_id"`
+	Operation string          `json:"operation"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+}
+
+// NewAuditEntry serializes before and after right away, so later in-place
+// changes of the same objects do not leak into the recorded state.
+// A nil snapshot is left empty.
+func NewAuditEntry(actor int, requestID, entity string, entityID int, operation string, before, after interface{}) *AuditEntry {
+	return &AuditEntry{
+		Actor:     actor,
+		RequestID: requestID,
+		Entity:    entity,
+		EntityID:  entityID,
+		Operation: operation,
+		Before:    auditSnapshot(before),
+		After:     auditSnapshot(after),
+	}
+}
+
+func auditSnapshot(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
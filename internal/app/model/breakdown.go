@@ -0,0 +1,110 @@
+package model
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// CategoryComparison is the amount that went through a category
+// in the period and in the previous one, as returned by the store.
+type CategoryComparison struct {
+	Account  int
+	Current  float64
+	Previous float64
+}
+
+type CategoryShare struct {
+	ID       int     `json:"id"`
+	Name     string  `json:"name"`
+	Parent   *int    `json:"parent,omitempty"`
+	Amount   float64 `json:"amount"`
+	Percent  float64 `json:"percent"`
+	Previous float64 `json:"previous"`
+	Change   float64 `json:"change"`
+	// ChangePercent is omitted when nothing went through the category
+	// in the previous period.
+	ChangePercent *float64 `json:"change_percent,omitempty"`
+}
+
+type BreakdownSide struct {
+	Total         float64          `json:"total"`
+	PreviousTotal float64          `json:"previous_total"`
+	Categories    []*CategoryShare `json:"categories"`
+}
+
+type CategoryBreakdown struct {
+	DateStart     time.Time      `json:"date_start"`
+	DateEnd       time.Time      `json:"date_end"`
+	PreviousStart time.Time      `json:"previous_start"`
+	PreviousEnd   time.Time      `json:"previous_end"`
+	Income        *BreakdownSide `json:"income"`
+	Expense       *BreakdownSide `json:"expense"`
+}
+
+// PreviousPeriod returns the period of the same number of days
+// that ends the day before DateStart.
+func PreviousPeriod(DateStart, DateEnd time.Time) (time.Time, time.Time) {
+	days := periodDays(DateStart, DateEnd)
+	return DateStart.AddDate(0, 0, -days), DateStart.AddDate(0, 0, -1)
+}
+
+// NewCategoryBreakdown splits totals into income sources and expense categories,
+// largest first, with their share of the side total and change since the previous period.
+func NewCategoryBreakdown(DateStart, DateEnd time.Time, accounts []*Account, totals []*CategoryComparison) *CategoryBreakdown {
+	res := &CategoryBreakdown{
+		DateStart: DateStart,
+		DateEnd:   DateEnd,
+		Income:    &BreakdownSide{Categories: make([]*CategoryShare, 0)},
+		Expense:   &BreakdownSide{Categories: make([]*CategoryShare, 0)},
+	}
+	res.PreviousStart, res.PreviousEnd = PreviousPeriod(DateStart, DateEnd)
+	byID := make(map[int]*Account)
+	for _, a := range accounts {
+		byID[a.ID] = a
+	}
+	for _, c := range totals {
+		a, ok := byID[c.Account]
+		if !ok || (c.Current == 0 && c.Previous == 0) {
+			continue
+		}
+		side := res.Expense
+		if a.Type == IncomeSourceAccount {
+			side = res.Income
+		}
+		share := &CategoryShare{
+			ID:       a.ID,
+			Name:     a.Name,
+			Parent:   a.Parent,
+			Amount:   c.Current,
+			Previous: c.Previous,
+			Change:   c.Current - c.Previous,
+		}
+		if c.Previous != 0 {
+			p := percent(share.Change, c.Previous)
+			share.ChangePercent = &p
+		}
+		side.Total += c.Current
+		side.PreviousTotal += c.Previous
+		side.Categories = append(side.Categories, share)
+	}
+	for _, side := range []*BreakdownSide{res.Income, res.Expense} {
+		for _, share := range side.Categories {
+			if side.Total != 0 {
+				share.Percent = percent(share.Amount, side.Total)
+			}
+		}
+		sort.Slice(side.Categories, func(i, j int) bool {
+			if side.Categories[i].Amount != side.Categories[j].Amount {
+				return side.Categories[i].Amount > side.Categories[j].Amount
+			}
+			return side.Categories[i].ID < side.Categories[j].ID
+		})
+	}
+	return res
+}
+
+// percent returns part of whole in percent rounded to hundredths.
+func percent(part, whole float64) float64 {
+	return math.Round(part/whole*10000) / 100
+}
@@ -0,0 +1,71 @@
+package model
+
+import "sort"
+
+// CategoryNode is an income source or expense category with its nested categories.
+// Total covers the category itself, RollupTotal also includes all descendants.
+type CategoryNode struct {
+	ID          int             `json:"id"`
+	Name        string          `json:"name"`
+	Type        string          `json:"type"`
+	Total       float64         `json:"total"`
+	RollupTotal float64         `json:"rollup_total"`
+	Children    []*CategoryNode `json:"children"`
+}
+
+// BuildCategoryTree arranges category accounts into trees using their parents.
+// Categories whose parent is not among accounts become roots.
+func BuildCategoryTree(accounts []*Account, totals map[int]float64) []*CategoryNode {
+	nodes := make(map[int]*CategoryNode)
+	for _, a := range accounts {
+		if !a.IsCategory() {
+			continue
+		}
+		nodes[a.ID] = &CategoryNode{
+			ID:       a.ID,
+			Name:     a.Name,
+			Type:     a.Type,
+			Total:    totals[a.ID],
+			Children: make([]*CategoryNode, 0),
+		}
+	}
+	roots := make([]*CategoryNode, 0)
+	for _, a := range accounts {
+		n, ok := nodes[a.ID]
+		if !ok {
+			continue
+		}
+		if a.Parent != nil {
+			if parent, ok := nodes[*a.Parent]; ok {
+				parent.Children = append(parent.Children, n)
+				continue
+			}
+		}
+		roots = append(roots, n)
+	}
+	sortCategoryNodes(roots)
+	for _, n := range roots {
+		n.rollup()
+	}
+	return roots
+}
+
+func (n *CategoryNode) rollup() float64 {
+	n.RollupTotal = n.Total
+	for _, c := range n.Children {
+		n.RollupTotal += c.rollup()
+	}
+	return n.RollupTotal
+}
+
+func sortCategoryNodes(nodes []*CategoryNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Type != nodes[j].Type {
+			return nodes[i].Type < nodes[j].Type
+		}
+		return nodes[i].Name < nodes[j].Name
+	})
+	for _, n := range nodes {
+		sortCategoryNodes(n.Children)
+	}
+}
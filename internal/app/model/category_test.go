@@ -0,0 +1,46 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildCategoryTree(t *testing.T) {
+	food, restaurants := 1, 2
+	accounts := []*model.Account{
+		{ID: 1, Name: "Food", Type: model.ExpenseCatogoryAccount},
+		{ID: 2, Name: "Restaurants", Type: model.ExpenseCatogoryAccount, Parent: &food},
+		{ID: 3, Name: "Coffee", Type: model.ExpenseCatogoryAccount, Parent: &restaurants},
+		{ID: 4, Name: "Groceries", Type: model.ExpenseCatogoryAccount, Parent: &food},
+		{ID: 5, Name: "Card", Type: model.CurrentAccount},
+	}
+	totals := map[int]float64{1: 5, 2: 20, 3: 7, 4: 30}
+
+	roots := model.BuildCategoryTree(accounts, totals)
+	assert.Len(t, roots, 1)
+	assert.Equal(t, 62.0, roots[0].RollupTotal)
+	assert.Equal(t, 5.0, roots[0].Total)
+	assert.Equal(t, "Groceries", roots[0].Children[0].Name)
+	assert.Equal(t, 27.0, roots[0].Children[1].RollupTotal)
+}
+
+func TestExpenseCategorySummary_Descendants(t *testing.T) {
+	a := &model.Account{ID: 1, Type: model.ExpenseCatogoryAccount}
+	s, err := model.GetSummaryByAccount(a, 2, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 3}, s.(*model.ExpenseCategorySummary).Descendants)
+	s.ApplyFlows(&model.AccountFlows{Inflow: 3, Outflow: 1})
+	assert.Equal(t, 3.0, s.(*model.ExpenseCategorySummary).Expense)
+}
+
+func TestAccount_ValidateParent(t *testing.T) {
+	parent := 1
+	a := model.TestAccount(t, model.TestUser(t))
+	a.Parent = &parent
+	assert.Error(t, a.Validate())
+
+	a.Type = model.ExpenseCatogoryAccount
+	assert.NoError(t, a.Validate())
+}
@@ -0,0 +1,134 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// InterestCategoryName is the expense category accrued interest is charged to.
+const InterestCategoryName = "Interest"
+
+// maxPayoffMonths limits payoff schedules to 50 years.
+const maxPayoffMonths = 600
+
+var (
+	ErrNotDebtAccount = errors.New("account is not a Debt account")
+	ErrPaymentTooLow  = errors.New("payment is too low to pay off the debt")
+)
+
+type PayoffPayment struct {
+	Month     int       `json:"month"`
+	Date      time.Time `json:"date"`
+	Payment   float64   `json:"payment"`
+	Interest  float64   `json:"interest"`
+	Principal float64   `json:"principal"`
+	Balance   float64   `json:"balance"`
+}
+
+type PayoffSchedule struct {
+	AccountID     int              `json:"account_id"`
+	Owed          float64          `json:"owed"`
+	APR           float64          `json:"apr"`
+	Payment       float64          `json:"payment"`
+	Months        int              `json:"months"`
+	TotalInterest float64          `json:"total_interest"`
+	TotalPaid     float64          `json:"total_paid"`
+	Payments      []*PayoffPayment `json:"payments"`
+}
+
+// Available returns how much can be spent from the account,
+// including the credit limit of Debt accounts.
+func (a *Account) Available() float64 {
+	return a.Balance + a.CreditLimit
+}
+
+// Owed returns the amount borrowed on a Debt account.
+func (a *Account) Owed() float64 {
+	if !a.IsLiability() || a.Balance >= 0 {
+		return 0
+	}
+	return -a.Balance
+}
+
+// MonthlyInterest returns interest for one month on the debt of the account
+// when its balance was the given one.
+func (a *Account) MonthlyInterest(balance float64) float64 {
+	if !a.IsLiability() || balance >= 0 {
+		return 0
+	}
+	return round2(-balance * a.APR / 100 / 12)
+}
+
+// MonthEnd returns the last day of the month of t.
+func MonthEnd(t time.Time) time.Time {
+	return TruncateDate(t, IntervalMonth).AddDate(0, 1, -1)
+}
+
+// NewPayoffSchedule amortizes the debt with a fixed monthly payment,
+// charging a month of interest before each payment. Payments fall on
+// the due day, or on month ends when it is not set, starting after from.
+func NewPayoffSchedule(a *Account, payment float64, from time.Time) (*PayoffSchedule, error) {
+	if !a.IsLiability() {
+		return nil, ErrNotDebtAccount
+	}
+	res := &PayoffSchedule{
+		AccountID: a.ID,
+		Owed:      a.Owed(),
+		APR:       a.APR,
+		Payment:   payment,
+		Payments:  make([]*PayoffPayment, 0),
+	}
+	balance := res.Owed
+	date := nextDueDate(a.DueDay, from)
+	for balance > 0 {
+		if len(res.Payments) == maxPayoffMonths {
+			return nil, ErrPaymentTooLow
+		}
+		interest := round2(balance * a.APR / 100 / 12)
+		if payment <= interest {
+			return nil, ErrPaymentTooLow
+		}
+		p := &PayoffPayment{
+			Month:    len(res.Payments) + 1,
+			Date:     date,
+			Payment:  payment,
+			Interest: interest,
+		}
+		if balance+interest < payment {
+			p.Payment = round2(balance + interest)
+		}
+		p.Principal = round2(p.Payment - interest)
+		balance = round2(balance - p.Principal)
+		p.Balance = balance
+		res.TotalInterest += interest
+		res.TotalPaid += p.Payment
+		res.Payments = append(res.Payments, p)
+		date = nextDueDate(a.DueDay, date)
+	}
+	res.Months = len(res.Payments)
+	res.TotalInterest = round2(res.TotalInterest)
+	res.TotalPaid = round2(res.TotalPaid)
+	return res, nil
+}
+
+// InterestDescription names the transaction charging interest for the month.
+func InterestDescription(monthEnd time.Time) string {
+	return fmt.Sprintf("Interest for %s", monthEnd.Format("2006-01"))
+}
+
+// nextDueDate returns the first due date after t.
+func nextDueDate(dueDay int, t time.Time) time.Time {
+	if dueDay == 0 {
+		end := MonthEnd(t)
+		if end.After(TruncateDate(t, IntervalDay)) {
+			return end
+		}
+		return MonthEnd(end.AddDate(0, 0, 1))
+	}
+	due := TruncateDate(t, IntervalMonth).AddDate(0, 0, dueDay-1)
+	if !due.After(TruncateDate(t, IntervalDay)) {
+		due = due.AddDate(0, 1, 0)
+	}
+	return due
+}
@@ -0,0 +1,38 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccount_ValidateDebtOnly(t *testing.T) {
+	a := model.TestAccount(t, model.TestUser(t))
+	a.CreditLimit = 500
+	assert.Error(t, a.Validate())
+	a.Type = model.DebtAccount
+	assert.NoError(t, a.Validate())
+	a.DueDay = 31
+	assert.Error(t, a.Validate())
+}
+
+func TestNewPayoffSchedule(t *testing.T) {
+	a := &model.Account{ID: 1, Type: model.DebtAccount, Balance: -1000, APR: 12, DueDay: 15}
+	from := time.Date(2023, time.May, 20, 0, 0, 0, 0, time.UTC)
+
+	s, err := model.NewPayoffSchedule(a, 300, from)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, s.Months)
+	assert.Equal(t, time.Date(2023, time.June, 15, 0, 0, 0, 0, time.UTC), s.Payments[0].Date)
+	assert.Equal(t, 10.0, s.Payments[0].Interest)
+	assert.Equal(t, 710.0, s.Payments[0].Balance)
+	assert.Equal(t, 0.0, s.Payments[3].Balance)
+	assert.Equal(t, s.TotalPaid, s.Owed+s.TotalInterest)
+
+	_, err = model.NewPayoffSchedule(a, 10, from)
+	assert.Equal(t, model.ErrPaymentTooLow, err)
+	_, err = model.NewPayoffSchedule(&model.Account{Type: model.CurrentAccount}, 10, from)
+	assert.Equal(t, model.ErrNotDebtAccount, err)
+}
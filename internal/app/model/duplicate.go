@@ -0,0 +1,127 @@
+package model
+
+import (
+	"math"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	DuplicatePending   = "pending"
+	DuplicateMerged    = "merged"
+	DuplicateDismissed = "dismissed"
+)
+
+// DuplicateWindowDays is how many days apart duplicates may be dated.
+const DuplicateWindowDays = 3
+
+// duplicateAmountTolerance is how much duplicate amounts may differ, relative to the larger one.
+const duplicateAmountTolerance = 0.02
+
+// minDuplicateScore is the description similarity from which transactions are taken for duplicates.
+const minDuplicateScore = 0.6
+
+// DuplicateCandidate is a pair of transactions that are likely the same
+// payment recorded twice. Transaction is the one created later.
+type DuplicateCandidate struct {
+	ID          int              `json:"id"`
+	Transaction *TransactionJSON `json:"transaction"`
+	DuplicateOf *TransactionJSON `json:"duplicate_of"`
+	Score       float64          `json:"score"`
+	Status      string           `json:"status"`
+	CreatedAt   time.Time        `json:"created_at"`
+	ResolvedAt  *time.Time       `json:"resolved_at,omitempty"`
+}
+
+// DuplicateAmountRange returns the amounts a duplicate of the amount may have.
+func DuplicateAmountRange(amount float64) (float64, float64) {
+	return amount * (1 - duplicateAmountTolerance), amount / (1 - duplicateAmountTolerance)
+}
+
+// DuplicateScore reports whether b is likely a duplicate of a: both move money
+// between the same accounts, amounts and dates are close and descriptions are
+// similar. The score is the description similarity from 0 to 1. An empty
+// description tells nothing, so it is taken as similar to any other.
+func DuplicateScore(a, b *TransactionJSON) (float64, bool) {
+	if a.ID == b.ID || a.Source != b.Source || a.Destination != b.Destination {
+		return 0, false
+	}
+	if days := daysBetween(a.TransactionDate, b.TransactionDate); days > DuplicateWindowDays || days < -DuplicateWindowDays {
+		return 0, false
+	}
+	if math.Abs(a.Amount-b.Amount) > math.Max(math.Abs(a.Amount), math.Abs(b.Amount))*duplicateAmountTolerance {
+		return 0, false
+	}
+	score := round2(descriptionSimilarity(a.Description, b.Description))
+	return score, score >= minDuplicateScore
+}
+
+// descriptionSimilarity compares descriptions ignoring case and punctuation.
+// A description whose words all occur in the other one, like a short note
+// against a bank statement line, is fully similar.
+func descriptionSimilarity(a, b string) float64 {
+	wordsA, wordsB := descriptionWords(a), descriptionWords(b)
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 1
+	}
+	if containsWords(wordsA, wordsB) || containsWords(wordsB, wordsA) {
+		return 1
+	}
+	x, y := []rune(strings.Join(wordsA, " ")), []rune(strings.Join(wordsB, " "))
+	longest := len(x)
+	if len(y) > longest {
+		longest = len(y)
+	}
+	return 1 - float64(levenshtein(x, y))/float64(longest)
+}
+
+func descriptionWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// containsWords reports whether every word of sub occurs in words.
+func containsWords(words, sub []string) bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	for _, w := range sub {
+		if !set[w] {
+			return false
+		}
+	}
+	return true
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
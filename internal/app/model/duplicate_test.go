@@ -0,0 +1,34 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuplicateScore(t *testing.T) {
+	day := time.Date(2023, time.June, 1, 9, 0, 0, 0, time.UTC)
+	coffee := &model.TransactionJSON{ID: 1, Source: 1, Destination: 2, Amount: 4.5, TransactionDate: day, Description: "Coffee"}
+	testCases := []struct {
+		name  string
+		other model.TransactionJSON
+		ok    bool
+	}{
+		{"bank statement line", model.TransactionJSON{ID: 2, Source: 1, Destination: 2, Amount: 4.5, TransactionDate: day.AddDate(0, 0, 2), Description: "CARD PAYMENT COFFEE #1234"}, true},
+		{"typo", model.TransactionJSON{ID: 2, Source: 1, Destination: 2, Amount: 4.55, TransactionDate: day, Description: "Cofee"}, true},
+		{"no description", model.TransactionJSON{ID: 2, Source: 1, Destination: 2, Amount: 4.5, TransactionDate: day}, true},
+		{"other description", model.TransactionJSON{ID: 2, Source: 1, Destination: 2, Amount: 4.5, TransactionDate: day, Description: "Bus ticket"}, false},
+		{"other accounts", model.TransactionJSON{ID: 2, Source: 1, Destination: 3, Amount: 4.5, TransactionDate: day, Description: "Coffee"}, false},
+		{"other amount", model.TransactionJSON{ID: 2, Source: 1, Destination: 2, Amount: 5, TransactionDate: day, Description: "Coffee"}, false},
+		{"too far apart", model.TransactionJSON{ID: 2, Source: 1, Destination: 2, Amount: 4.5, TransactionDate: day.AddDate(0, 0, 4), Description: "Coffee"}, false},
+		{"same transaction", *coffee, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, ok := model.DuplicateScore(coffee, &tc.other)
+			assert.Equal(t, tc.ok, ok)
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	RecurrenceWeekly   = "weekly"
+	RecurrenceBiweekly = "biweekly"
+	RecurrenceMonthly  = "monthly"
+)
+
+// minRecurrences is how many times a transaction must repeat
+// before it is treated as recurring.
+const minRecurrences = 3
+
+// scheduleTolerance is how many days a known future transaction may be away
+// from a projected occurrence and still be taken for that occurrence.
+const scheduleTolerance = 3
+
+// recurrences are the supported intervals with the number of days
+// a gap between two occurrences may differ from the nominal one.
+var recurrences = []struct {
+	name      string
+	days      int
+	tolerance int
+}{
+	{RecurrenceWeekly, 7, 1},
+	{RecurrenceBiweekly, 14, 2},
+	{RecurrenceMonthly, 30, 3},
+}
+
+// RecurringPattern is a transaction that repeats between the same
+// accounts at a regular interval.
+type RecurringPattern struct {
+	Type        string    `json:"type"`
+	Source      int       `json:"source"`
+	Destination int       `json:"destination"`
+	Description string    `json:"description"`
+	Amount      float64   `json:"amount"`
+	Interval    string    `json:"interval"`
+	Occurrences int       `json:"occurrences"`
+	Last        time.Time `json:"last"`
+}
+
+type ForecastDay struct {
+	Date    time.Time `json:"date"`
+	Balance float64   `json:"balance"`
+}
+
+type AccountForecast struct {
+	ID         int            `json:"id"`
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	Balance    float64        `json:"balance"`
+	Lowest     float64        `json:"lowest"`
+	LowestDate time.Time      `json:"lowest_date"`
+	Days       []*ForecastDay `json:"days"`
+}
+
+type ForecastWarning struct {
+	Account int       `json:"account"`
+	Date    time.Time `json:"date"`
+	Balance float64   `json:"balance"`
+	Message string    `json:"message"`
+}
+
+type Forecast struct {
+	DateStart time.Time           `json:"date_start"`
+	DateEnd   time.Time           `json:"date_end"`
+	Threshold float64             `json:"threshold"`
+	Recurring []*RecurringPattern `json:"recurring"`
+	Accounts  []*AccountForecast  `json:"accounts"`
+	Warnings  []*ForecastWarning  `json:"warnings"`
+}
+
+// Next returns the date of the n-th occurrence after the last one.
+func (p *RecurringPattern) Next(n int) time.Time {
+	switch p.Interval {
+	case RecurrenceWeekly:
+		return p.Last.AddDate(0, 0, 7*n)
+	case RecurrenceBiweekly:
+		return p.Last.AddDate(0, 0, 14*n)
+	}
+	return p.Last.AddDate(0, n, 0)
+}
+
+func (p *RecurringPattern) matches(t *TransactionJSON) bool {
+	return t.Type == p.Type && t.Source == p.Source && t.Destination == p.Destination
+}
+
+// DetectRecurring finds transactions repeating between the same accounts
+// weekly, every two weeks or monthly. Patterns that missed two occurrences
+// by today are considered stopped and left out.
+func DetectRecurring(ts []*TransactionJSON, today time.Time) []*RecurringPattern {
+	type key struct {
+		tType       string
+		source      int
+		destination int
+	}
+	groups := make(map[key][]*TransactionJSON)
+	for _, t := range ts {
+		k := key{t.Type, t.Source, t.Destination}
+		groups[k] = append(groups[k], t)
+	}
+	res := make([]*RecurringPattern, 0)
+	for k, group := range groups {
+		if len(group) < minRecurrences {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].TransactionDate.Before(group[j].TransactionDate)
+		})
+		gaps := make([]int, 0, len(group)-1)
+		amounts := make([]float64, 0, len(group))
+		for i, t := range group {
+			amounts = append(amounts, t.Amount)
+			if i > 0 {
+				gaps = append(gaps, daysBetween(group[i-1].TransactionDate, t.TransactionDate))
+			}
+		}
+		interval := recurrenceOf(gaps)
+		if interval == "" {
+			continue
+		}
+		last := group[len(group)-1]
+		p := &RecurringPattern{
+			Type:        k.tType,
+			Source:      k.source,
+			Destination: k.destination,
+			Description: last.Description,
+			Amount:      median(amounts),
+			Interval:    interval,
+			Occurrences: len(group),
+			Last:        TruncateDate(last.TransactionDate, IntervalDay),
+		}
+		if p.Next(2).Before(today) {
+			continue
+		}
+		res = append(res, p)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].Last.Equal(res[j].Last) {
+			return res[i].Last.Before(res[j].Last)
+		}
+		return res[i].Source < res[j].Source
+	})
+	return res
+}
+
+// NewForecast projects daily balances of standard accounts for the given
+// number of days after today. Balances already include future-dated
+// transactions, so they are backed out first and applied again on their dates
+// together with the occurrences of recurring patterns. scheduled must hold
+// every transaction dated after today.
+func NewForecast(today time.Time, days int, threshold float64, accounts []*Account, recurring []*RecurringPattern, scheduled []*TransactionJSON) *Forecast {
+	res := &Forecast{
+		DateStart: today,
+		DateEnd:   today.AddDate(0, 0, days),
+		Threshold: threshold,
+		Recurring: recurring,
+		Accounts:  make([]*AccountForecast, 0),
+		Warnings:  make([]*ForecastWarning, 0),
+	}
+	upcoming := make(map[time.Time][]*TransactionJSON)
+	for _, t := range scheduled {
+		date := TruncateDate(t.TransactionDate, IntervalDay)
+		upcoming[date] = append(upcoming[date], t)
+	}
+	for _, p := range recurring {
+		for n := 1; !p.Next(n).After(res.DateEnd); n++ {
+			date := p.Next(n)
+			if !date.After(today) || isScheduled(p, date, scheduled) {
+				continue
+			}
+			upcoming[date] = append(upcoming[date], &TransactionJSON{
+				TransactionDate: date,
+				Source:          p.Source,
+				Destination:     p.Destination,
+				Amount:          p.Amount,
+				Type:            p.Type,
+				Description:     p.Description,
+			})
+		}
+	}
+
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID < accounts[j].ID })
+	for _, a := range accounts {
+		if a.IsCategory() {
+			continue
+		}
+		balance := a.Balance
+		for _, t := range scheduled {
+			balance -= t.BalanceEffect(a.ID)
+		}
+		f := &AccountForecast{
+			ID:         a.ID,
+			Name:       a.Name,
+			Type:       a.Type,
+			Balance:    balance,
+			Lowest:     balance,
+			LowestDate: today,
+			Days:       make([]*ForecastDay, 0, days),
+		}
+		var warning *ForecastWarning
+		for date := today.AddDate(0, 0, 1); !date.After(res.DateEnd); date = date.AddDate(0, 0, 1) {
+			for _, t := range upcoming[date] {
+				balance += t.BalanceEffect(a.ID)
+			}
+			f.Days = append(f.Days, &ForecastDay{Date: date, Balance: balance})
+			if balance < f.Lowest {
+				f.Lowest, f.LowestDate = balance, date
+			}
+			if a.IsAsset() && balance < threshold && warning == nil {
+				warning = &ForecastWarning{
+					Account: a.ID,
+					Date:    date,
+					Balance: balance,
+					Message: fmt.Sprintf("%s drops to %.2f on %s", a.Name, balance, date.Format("2006-01-02")),
+				}
+				res.Warnings = append(res.Warnings, warning)
+			}
+		}
+		res.Accounts = append(res.Accounts, f)
+	}
+	return res
+}
+
+// isScheduled reports whether a known future transaction
+// already stands for the occurrence of the pattern on date.
+func isScheduled(p *RecurringPattern, date time.Time, scheduled []*TransactionJSON) bool {
+	for _, t := range scheduled {
+		days := daysBetween(date, t.TransactionDate)
+		if p.matches(t) && days >= -scheduleTolerance && days <= scheduleTolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// recurrenceOf returns the interval all gaps fit in, or an empty string.
+func recurrenceOf(gaps []int) string {
+	for _, r := range recurrences {
+		regular := true
+		for _, gap := range gaps {
+			if gap < r.days-r.tolerance || gap > r.days+r.tolerance {
+				regular = false
+				break
+			}
+		}
+		if regular {
+			return r.name
+		}
+	}
+	return ""
+}
+
+func daysBetween(from, to time.Time) int {
+	return int(TruncateDate(to, IntervalDay).Sub(TruncateDate(from, IntervalDay)).Hours() / 24)
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,64 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectRecurring(t *testing.T) {
+	today := time.Date(2023, time.May, 20, 0, 0, 0, 0, time.UTC)
+	ts := []*model.TransactionJSON{
+		{TransactionDate: time.Date(2023, time.February, 25, 0, 0, 0, 0, time.UTC), Source: 1, Destination: 2, Amount: 1000, Type: model.IncomeTransaction},
+		{TransactionDate: time.Date(2023, time.March, 25, 0, 0, 0, 0, time.UTC), Source: 1, Destination: 2, Amount: 1000, Type: model.IncomeTransaction},
+		{TransactionDate: time.Date(2023, time.April, 25, 0, 0, 0, 0, time.UTC), Source: 1, Destination: 2, Amount: 1100, Type: model.IncomeTransaction},
+		//нерегулярные траты
+		{TransactionDate: time.Date(2023, time.April, 1, 0, 0, 0, 0, time.UTC), Source: 2, Destination: 3, Amount: 10, Type: model.ExpenseTransaction},
+		{TransactionDate: time.Date(2023, time.April, 3, 0, 0, 0, 0, time.UTC), Source: 2, Destination: 3, Amount: 10, Type: model.ExpenseTransaction},
+		{TransactionDate: time.Date(2023, time.April, 20, 0, 0, 0, 0, time.UTC), Source: 2, Destination: 3, Amount: 10, Type: model.ExpenseTransaction},
+	}
+
+	patterns := model.DetectRecurring(ts, today)
+	assert.Len(t, patterns, 1)
+	assert.Equal(t, model.RecurrenceMonthly, patterns[0].Interval)
+	assert.Equal(t, 1000.0, patterns[0].Amount)
+	assert.Equal(t, time.Date(2023, time.May, 25, 0, 0, 0, 0, time.UTC), patterns[0].Next(1))
+
+	//две пропущенные выплаты
+	assert.Empty(t, model.DetectRecurring(ts, today.AddDate(0, 2, 0)))
+}
+
+func TestNewForecast(t *testing.T) {
+	today := time.Date(2023, time.May, 20, 0, 0, 0, 0, time.UTC)
+	accounts := []*model.Account{
+		{ID: 2, Name: "Card", Type: model.CurrentAccount, Balance: 50},
+		{ID: 3, Name: "Food", Type: model.ExpenseCatogoryAccount},
+	}
+	recurring := []*model.RecurringPattern{
+		{Type: model.IncomeTransaction, Source: 1, Destination: 2, Amount: 1000, Interval: model.RecurrenceMonthly, Last: time.Date(2023, time.April, 25, 0, 0, 0, 0, time.UTC)},
+		{Type: model.ExpenseTransaction, Source: 2, Destination: 3, Amount: 40, Interval: model.RecurrenceWeekly, Last: time.Date(2023, time.May, 16, 0, 0, 0, 0, time.UTC)},
+	}
+	scheduled := []*model.TransactionJSON{
+		//уже учтена в балансе
+		{TransactionDate: time.Date(2023, time.May, 22, 0, 0, 0, 0, time.UTC), Source: 2, Destination: 3, Amount: 30, Type: model.ExpenseTransaction},
+	}
+
+	f := model.NewForecast(today, 10, 0, accounts, recurring, scheduled)
+	assert.Len(t, f.Accounts, 1)
+	card := f.Accounts[0]
+	assert.Equal(t, 80.0, card.Balance)
+	assert.Len(t, card.Days, 10)
+	//22 мая: запланированная трата заменяет еженедельную 23 мая
+	assert.Equal(t, 50.0, card.Days[2].Balance)
+	assert.Equal(t, 50.0, card.Days[3].Balance)
+	//25 мая: зарплата
+	assert.Equal(t, 1050.0, card.Days[5].Balance)
+	assert.Equal(t, 1010.0, card.Days[9].Balance)
+	assert.Empty(t, f.Warnings)
+
+	f = model.NewForecast(today, 10, 100, accounts, recurring, scheduled)
+	assert.Len(t, f.Warnings, 1)
+	assert.Equal(t, time.Date(2023, time.May, 21, 0, 0, 0, 0, time.UTC), f.Warnings[0].Date)
+}
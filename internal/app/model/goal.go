@@ -0,0 +1,133 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+// goalHistoryMonths is how many full months of contributions
+// the projected completion date is based on.
+const goalHistoryMonths = 6
+
+var ErrNotSavingAccount = errors.New("goals can only be set on Saving accounts")
+
+// Goal is an amount the user wants to have on a Saving account by the target date.
+// MonthlyContribution is the planned monthly deposit, zero when there is no plan.
+type Goal struct {
+	ID                  int       `json:"id"`
+	Account             int       `json:"account"`
+	Name                string    `json:"name"`
+	TargetAmount        float64   `json:"target_amount"`
+	TargetDate          time.Time `json:"target_date"`
+	MonthlyContribution float64   `json:"monthly_contribution,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+func (g *Goal) Validate() error {
+	return validation.ValidateStruct(
+		g,
+		validation.Field(&g.Account, validation.Required),
+		validation.Field(&g.Name, validation.Required, validation.Length(1, 100)),
+		validation.Field(&g.TargetAmount, validation.Required, validation.Min(0.0)),
+		validation.Field(&g.TargetDate, validation.Required),
+		validation.Field(&g.MonthlyContribution, validation.Min(0.0)),
+	)
+}
+
+type GoalProgress struct {
+	Goal                *Goal      `json:"goal"`
+	Saved               float64    `json:"saved"`
+	Remaining           float64    `json:"remaining"`
+	Percent             float64    `json:"percent"`
+	AverageContribution float64    `json:"average_contribution"`
+	ProjectedDate       *time.Time `json:"projected_date"`
+	PlannedDate         *time.Time `json:"planned_date,omitempty"`
+	MonthsLeft          int        `json:"months_left"`
+	RequiredMonthly     float64    `json:"required_monthly"`
+	OnTrack             bool       `json:"on_track"`
+}
+
+// GoalHistoryStart returns the first day of the contribution history
+// NewGoalProgress averages.
+func GoalHistoryStart(today time.Time) time.Time {
+	return TruncateDate(today, IntervalMonth).AddDate(0, -goalHistoryMonths, 0)
+}
+
+// NewGoalProgress compares the balance of the account with the goal.
+// The completion date is projected from the average monthly net deposit
+// over the last full months, counted from the first one with transactions.
+// ts must cover the account since GoalHistoryStart.
+func NewGoalProgress(g *Goal, a *Account, ts []*TransactionJSON, today time.Time) *GoalProgress {
+	today = TruncateDate(today, IntervalDay)
+	res := &GoalProgress{
+		Goal:  g,
+		Saved: math.Max(a.Balance, 0),
+	}
+	res.Remaining = round2(math.Max(g.TargetAmount-res.Saved, 0))
+	res.Percent = math.Min(percent(res.Saved, g.TargetAmount), 100)
+
+	start := GoalHistoryStart(today)
+	end := TruncateDate(today, IntervalMonth)
+	first := end
+	var contributed float64
+	for _, t := range ts {
+		date := TruncateDate(t.TransactionDate, IntervalDay)
+		if date.Before(start) || !date.Before(end) {
+			continue
+		}
+		if date.Before(first) {
+			first = date
+		}
+		contributed += t.BalanceEffect(a.ID)
+	}
+	if first.Before(end) {
+		months := CountBuckets(first, end.AddDate(0, 0, -1), IntervalMonth)
+		res.AverageContribution = round2(contributed / float64(months))
+	}
+
+	res.MonthsLeft = monthsUntil(today, g.TargetDate)
+	if res.Remaining == 0 {
+		res.ProjectedDate = &today
+		res.PlannedDate = &today
+		res.OnTrack = true
+		return res
+	}
+	res.ProjectedDate = projectGoal(today, res.Remaining, res.AverageContribution)
+	res.PlannedDate = projectGoal(today, res.Remaining, g.MonthlyContribution)
+	res.RequiredMonthly = res.Remaining
+	if res.MonthsLeft > 0 {
+		res.RequiredMonthly = round2(res.Remaining / float64(res.MonthsLeft))
+	}
+	res.OnTrack = res.ProjectedDate != nil && !res.ProjectedDate.After(g.TargetDate)
+	return res
+}
+
+// projectGoal returns when the remaining amount is saved at the monthly rate,
+// or nil if it never is.
+func projectGoal(today time.Time, remaining, monthly float64) *time.Time {
+	if monthly <= 0 {
+		return nil
+	}
+	date := today.AddDate(0, int(math.Ceil(remaining/monthly)), 0)
+	return &date
+}
+
+// monthsUntil returns the number of monthly contributions
+// that can still be made before the deadline.
+func monthsUntil(today, deadline time.Time) int {
+	deadline = TruncateDate(deadline, IntervalDay)
+	if !deadline.After(today) {
+		return 0
+	}
+	months := (deadline.Year()-today.Year())*12 + int(deadline.Month()-today.Month())
+	if deadline.Day() < today.Day() {
+		months--
+	}
+	if months < 1 {
+		return 1
+	}
+	return months
+}
@@ -0,0 +1,55 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewGoalProgress(t *testing.T) {
+	today := time.Date(2023, time.June, 10, 0, 0, 0, 0, time.UTC)
+	a := &model.Account{ID: 1, Type: model.SavingAccount, Balance: 400}
+	g := &model.Goal{
+		Account:             1,
+		TargetAmount:        1000,
+		TargetDate:          time.Date(2023, time.December, 10, 0, 0, 0, 0, time.UTC),
+		MonthlyContribution: 50,
+	}
+	deposit := func(month time.Month, amount float64) *model.TransactionJSON {
+		return &model.TransactionJSON{
+			TransactionDate: time.Date(2023, month, 1, 0, 0, 0, 0, time.UTC),
+			Source:          2,
+			Destination:     1,
+			Amount:          amount,
+			Type:            model.StandardTransaction,
+		}
+	}
+	ts := []*model.TransactionJSON{
+		deposit(time.March, 100),
+		deposit(time.April, 100),
+		deposit(time.May, 100),
+		deposit(time.June, 100),
+	}
+
+	p := model.NewGoalProgress(g, a, ts, today)
+	assert.Equal(t, 40.0, p.Percent)
+	assert.Equal(t, 600.0, p.Remaining)
+	assert.Equal(t, 100.0, p.AverageContribution)
+	assert.Equal(t, time.Date(2023, time.December, 10, 0, 0, 0, 0, time.UTC), *p.ProjectedDate)
+	assert.Equal(t, time.Date(2024, time.June, 10, 0, 0, 0, 0, time.UTC), *p.PlannedDate)
+	assert.Equal(t, 6, p.MonthsLeft)
+	assert.Equal(t, 100.0, p.RequiredMonthly)
+	assert.True(t, p.OnTrack)
+
+	p = model.NewGoalProgress(g, a, nil, today)
+	assert.Nil(t, p.ProjectedDate)
+	assert.False(t, p.OnTrack)
+
+	a.Balance = 1200
+	p = model.NewGoalProgress(g, a, ts, today)
+	assert.Equal(t, 100.0, p.Percent)
+	assert.Equal(t, 0.0, p.RequiredMonthly)
+	assert.True(t, p.OnTrack)
+}
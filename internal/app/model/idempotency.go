@@ -0,0 +1,22 @@
+package model
+
+import (
+	"net/http"
+	"time"
+)
+
+// IdempotencyKey remembers the response to a request sent with an Idempotency-Key header.
+// StatusCode is zero while the original request is still being processed.
+type IdempotencyKey struct {
+	UserID      int
+	Key         string
+	RequestHash string
+	StatusCode  int
+	Header      http.Header
+	Body        []byte
+	CreatedAt   time.Time
+}
+
+func (k *IdempotencyKey) IsCompleted() bool {
+	return k.StatusCode != 0
+}
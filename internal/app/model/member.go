@@ -0,0 +1,38 @@
+package model
+
+import validation "github.com/go-ozzo/ozzo-validation"
+
+const (
+	OwnerRole  = "owner"
+	EditorRole = "editor"
+	ViewerRole = "viewer"
+)
+
+var roleRanks = map[string]int{
+	ViewerRole: 1,
+	EditorRole: 2,
+	OwnerRole:  3,
+}
+
+type AccountMember struct {
+	AccountID int    `json:"account_id"`
+	UserID    int    `json:"user_id"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+}
+
+func (m *AccountMember) Validate() error {
+	return validation.ValidateStruct(
+		m,
+		validation.Field(&m.Role,
+			validation.Required,
+			validation.In(EditorRole, ViewerRole),
+		),
+	)
+}
+
+// RoleAllows reports whether role grants at least the permissions of required.
+// An empty role means the user has no access to the account.
+func RoleAllows(role, required string) bool {
+	return role != "" && roleRanks[role] >= roleRanks[required]
+}
@@ -0,0 +1,29 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleAllows(t *testing.T) {
+	testCases := []struct {
+		role     string
+		required string
+		allowed  bool
+	}{
+		{model.OwnerRole, model.OwnerRole, true},
+		{model.OwnerRole, model.ViewerRole, true},
+		{model.EditorRole, model.EditorRole, true},
+		{model.EditorRole, model.OwnerRole, false},
+		{model.ViewerRole, model.ViewerRole, true},
+		{model.ViewerRole, model.EditorRole, false},
+		{"", model.ViewerRole, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.role+"/"+tc.required, func(t *testing.T) {
+			assert.Equal(t, tc.allowed, model.RoleAllows(tc.role, tc.required))
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"sort"
+	"time"
+)
+
+// BalanceChange is how much transactions dated within a bucket
+// changed the balance of an account.
+type BalanceChange struct {
+	Account int
+	Bucket  time.Time
+	Amount  float64
+}
+
+type NetWorthAccount struct {
+	ID      int     `json:"id"`
+	Name    string  `json:"name"`
+	Type    string  `json:"type"`
+	Balance float64 `json:"balance"`
+}
+
+type NetWorthPoint struct {
+	Date        time.Time          `json:"date"`
+	Assets      float64            `json:"assets"`
+	Liabilities float64            `json:"liabilities"`
+	NetWorth    float64            `json:"net_worth"`
+	Accounts    []*NetWorthAccount `json:"accounts"`
+}
+
+type NetWorth struct {
+	DateStart time.Time        `json:"date_start"`
+	DateEnd   time.Time        `json:"date_end"`
+	Interval  string           `json:"interval"`
+	Points    []*NetWorthPoint `json:"points"`
+}
+
+// NewNetWorth reconstructs balances of asset and debt accounts at the end
+// of every bucket of the period. It starts from the current balance and
+// undoes changes made later, so changes must cover every bucket from the
+// first one of the period onwards, including the future ones.
+func NewNetWorth(DateStart, DateEnd time.Time, interval string, accounts []*Account, changes []*BalanceChange) *NetWorth {
+	res := &NetWorth{
+		DateStart: DateStart,
+		DateEnd:   DateEnd,
+		Interval:  interval,
+		Points:    make([]*NetWorthPoint, 0),
+	}
+	buckets := make([]time.Time, 0)
+	for b := TruncateDate(DateStart, interval); !b.After(DateEnd); b = NextBucket(b, interval) {
+		buckets = append(buckets, b)
+		res.Points = append(res.Points, &NetWorthPoint{
+			Date:     NextBucket(b, interval).AddDate(0, 0, -1),
+			Accounts: make([]*NetWorthAccount, 0),
+		})
+	}
+	if len(buckets) == 0 {
+		return res
+	}
+	byAccount := make(map[int]map[time.Time]float64)
+	for _, c := range changes {
+		if byAccount[c.Account] == nil {
+			byAccount[c.Account] = make(map[time.Time]float64)
+		}
+		byAccount[c.Account][TruncateDate(c.Bucket, interval)] += c.Amount
+	}
+
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID < accounts[j].ID })
+	last := buckets[len(buckets)-1]
+	for _, a := range accounts {
+		if !a.IsAsset() && !a.IsLiability() {
+			continue
+		}
+		balance := a.Balance
+		for b, amount := range byAccount[a.ID] {
+			if b.After(last) {
+				balance -= amount
+			}
+		}
+		for i := len(buckets) - 1; i >= 0; i-- {
+			p := res.Points[i]
+			p.Accounts = append(p.Accounts, &NetWorthAccount{
+				ID:      a.ID,
+				Name:    a.Name,
+				Type:    a.Type,
+				Balance: balance,
+			})
+			if a.IsAsset() {
+				p.Assets += balance
+			} else {
+				//долг хранится с минусом
+				p.Liabilities -= balance
+			}
+			p.NetWorth = p.Assets - p.Liabilities
+			balance -= byAccount[a.ID][buckets[i]]
+		}
+	}
+	return res
+}
@@ -0,0 +1,38 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewNetWorth(t *testing.T) {
+	accounts := []*model.Account{
+		{ID: 1, Name: "Card", Type: model.CurrentAccount, Balance: 700},
+		{ID: 2, Name: "Loan", Type: model.DebtAccount, Balance: -300},
+		{ID: 3, Name: "Food", Type: model.ExpenseCatogoryAccount, Balance: 100},
+	}
+	month := func(m time.Month) time.Time {
+		return time.Date(2023, m, 1, 0, 0, 0, 0, time.UTC)
+	}
+	changes := []*model.BalanceChange{
+		{Account: 1, Bucket: month(time.February), Amount: 500},
+		{Account: 1, Bucket: month(time.March), Amount: -100},
+		{Account: 2, Bucket: month(time.March), Amount: -100},
+		//после конца периода
+		{Account: 1, Bucket: month(time.May), Amount: 200},
+	}
+
+	nw := model.NewNetWorth(month(time.January), time.Date(2023, time.March, 31, 0, 0, 0, 0, time.UTC), model.IntervalMonth, accounts, changes)
+	assert.Len(t, nw.Points, 3)
+	assert.Equal(t, time.Date(2023, time.January, 31, 0, 0, 0, 0, time.UTC), nw.Points[0].Date)
+	assert.Equal(t, 100.0, nw.Points[0].Assets)
+	assert.Equal(t, 200.0, nw.Points[0].Liabilities)
+	assert.Equal(t, -100.0, nw.Points[0].NetWorth)
+	assert.Equal(t, 600.0, nw.Points[1].Assets)
+	assert.Equal(t, 500.0, nw.Points[2].Assets)
+	assert.Equal(t, 300.0, nw.Points[2].Liabilities)
+	assert.Len(t, nw.Points[2].Accounts, 2)
+}
@@ -0,0 +1,158 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	BcryptAlgorithm   = "bcrypt"
+	Argon2idAlgorithm = "argon2id"
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var (
+	errUnknownPasswordHash = errors.New("unknown password hash format")
+
+	passwordPolicy = DefaultPasswordPolicy()
+)
+
+// PasswordPolicy describes how new password hashes are produced.
+// Hashes created under another policy are still accepted by
+// User.ComparePassword and reported by User.NeedsRehash.
+type PasswordPolicy struct {
+	Algorithm     string
+	BcryptCost    int
+	Argon2Time    uint32
+	Argon2Memory  uint32
+	Argon2Threads uint8
+}
+
+func DefaultPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
+		Algorithm:     BcryptAlgorithm,
+		BcryptCost:    bcrypt.DefaultCost,
+		Argon2Time:    1,
+		Argon2Memory:  64 * 1024,
+		Argon2Threads: 4,
+	}
+}
+
+func (p *PasswordPolicy) Validate() error {
+	return validation.ValidateStruct(
+		p,
+		validation.Field(&p.Algorithm,
+			validation.Required,
+			validation.In(BcryptAlgorithm, Argon2idAlgorithm),
+		),
+		validation.Field(&p.BcryptCost,
+			validation.By(requieredIf(p.Algorithm == BcryptAlgorithm)),
+			validation.Min(bcrypt.MinCost),
+			validation.Max(bcrypt.MaxCost),
+		),
+		validation.Field(&p.Argon2Time, validation.By(requieredIf(p.Algorithm == Argon2idAlgorithm))),
+		validation.Field(&p.Argon2Memory, validation.By(requieredIf(p.Algorithm == Argon2idAlgorithm))),
+		validation.Field(&p.Argon2Threads, validation.By(requieredIf(p.Algorithm == Argon2idAlgorithm))),
+	)
+}
+
+// SetPasswordPolicy replaces the policy used for hashing new passwords.
+func SetPasswordPolicy(p *PasswordPolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	passwordPolicy = p
+	return nil
+}
+
+func (p *PasswordPolicy) hash(password string) (string, error) {
+	switch p.Algorithm {
+	case Argon2idAlgorithm:
+		salt := make([]byte, argon2SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, p.Argon2Time, p.Argon2Memory, p.Argon2Threads, argon2KeyLength)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version,
+			p.Argon2Memory,
+			p.Argon2Time,
+			p.Argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key),
+		), nil
+	default:
+		b, err := bcrypt.GenerateFromPassword([]byte(password), p.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+}
+
+// matches reports whether hash was produced with exactly this policy.
+func (p *PasswordPolicy) matches(hash string) bool {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, _, _, err := decodeArgon2id(hash)
+		if err != nil {
+			return false
+		}
+		return p.Algorithm == Argon2idAlgorithm &&
+			params.Argon2Time == p.Argon2Time &&
+			params.Argon2Memory == p.Argon2Memory &&
+			params.Argon2Threads == p.Argon2Threads
+	default:
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return false
+		}
+		return p.Algorithm == BcryptAlgorithm && cost == p.BcryptCost
+	}
+}
+
+func comparePasswordHash(hash, password string) bool {
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	}
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false
+	}
+	other := argon2.IDKey([]byte(password), salt, params.Argon2Time, params.Argon2Memory, params.Argon2Threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
+
+func decodeArgon2id(hash string) (*PasswordPolicy, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return nil, nil, nil, errUnknownPasswordHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, errUnknownPasswordHash
+	}
+	p := &PasswordPolicy{Algorithm: Argon2idAlgorithm}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Argon2Memory, &p.Argon2Time, &p.Argon2Threads); err != nil {
+		return nil, nil, nil, errUnknownPasswordHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, errUnknownPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return nil, nil, nil, errUnknownPasswordHash
+	}
+	return p, salt, key, nil
+}
@@ -0,0 +1,153 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	IntervalDay     = "day"
+	IntervalWeek    = "week"
+	IntervalMonth   = "month"
+	IntervalQuarter = "quarter"
+	IntervalYear    = "year"
+)
+
+const (
+	GroupByAccount  = "account"
+	GroupByCategory = "category"
+)
+
+var (
+	ErrUnknownInterval = errors.New("unknown interval")
+	ErrUnknownGroupBy  = errors.New("unknown grouping")
+)
+
+// TimeSeriesRow is the income and expense of one group in one bucket
+// as returned by the store. Group is zero when the series is not grouped.
+type TimeSeriesRow struct {
+	Bucket  time.Time
+	Group   int
+	Income  float64
+	Expense float64
+}
+
+type TimeSeriesGroup struct {
+	ID      int     `json:"id"`
+	Income  float64 `json:"income"`
+	Expense float64 `json:"expense"`
+	Net     float64 `json:"net"`
+}
+
+type TimeSeriesBucket struct {
+	Start   time.Time          `json:"start"`
+	Income  float64            `json:"income"`
+	Expense float64            `json:"expense"`
+	Net     float64            `json:"net"`
+	Groups  []*TimeSeriesGroup `json:"groups,omitempty"`
+}
+
+type TimeSeries struct {
+	DateStart time.Time           `json:"date_start"`
+	DateEnd   time.Time           `json:"date_end"`
+	Interval  string              `json:"interval"`
+	GroupBy   string              `json:"group_by,omitempty"`
+	Buckets   []*TimeSeriesBucket `json:"buckets"`
+}
+
+func ValidateInterval(interval string) error {
+	switch interval {
+	case IntervalDay, IntervalWeek, IntervalMonth, IntervalQuarter, IntervalYear:
+		return nil
+	}
+	return ErrUnknownInterval
+}
+
+func ValidateGroupBy(groupBy string) error {
+	switch groupBy {
+	case "", GroupByAccount, GroupByCategory:
+		return nil
+	}
+	return ErrUnknownGroupBy
+}
+
+// TruncateDate returns the start of the bucket t falls into.
+// It matches date_trunc in Postgres, so weeks start on Monday.
+// Buckets are calendar dates, so the result is always midnight UTC.
+func TruncateDate(t time.Time, interval string) time.Time {
+	y, m, d := t.Date()
+	switch interval {
+	case IntervalWeek:
+		return time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, time.UTC)
+	case IntervalMonth:
+		return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	case IntervalQuarter:
+		return time.Date(y, (m-1)/3*3+1, 1, 0, 0, 0, 0, time.UTC)
+	case IntervalYear:
+		return time.Date(y, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// NextBucket returns the start of the bucket following the one starting at t.
+func NextBucket(t time.Time, interval string) time.Time {
+	switch interval {
+	case IntervalWeek:
+		return t.AddDate(0, 0, 7)
+	case IntervalMonth:
+		return t.AddDate(0, 1, 0)
+	case IntervalQuarter:
+		return t.AddDate(0, 3, 0)
+	case IntervalYear:
+		return t.AddDate(1, 0, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// CountBuckets returns how many buckets the period spans.
+func CountBuckets(DateStart, DateEnd time.Time, interval string) int {
+	n := 0
+	for b := TruncateDate(DateStart, interval); !b.After(DateEnd); b = NextBucket(b, interval) {
+		n++
+	}
+	return n
+}
+
+// NewTimeSeries lays rows out over every bucket of the period,
+// so buckets without transactions are present with zero totals.
+func NewTimeSeries(DateStart, DateEnd time.Time, interval, groupBy string, rows []*TimeSeriesRow) *TimeSeries {
+	res := &TimeSeries{
+		DateStart: DateStart,
+		DateEnd:   DateEnd,
+		Interval:  interval,
+		GroupBy:   groupBy,
+		Buckets:   make([]*TimeSeriesBucket, 0),
+	}
+	buckets := make(map[time.Time]*TimeSeriesBucket)
+	for b := TruncateDate(DateStart, interval); !b.After(DateEnd); b = NextBucket(b, interval) {
+		bucket := &TimeSeriesBucket{Start: b}
+		if groupBy != "" {
+			bucket.Groups = make([]*TimeSeriesGroup, 0)
+		}
+		buckets[b] = bucket
+		res.Buckets = append(res.Buckets, bucket)
+	}
+	for _, row := range rows {
+		bucket, ok := buckets[TruncateDate(row.Bucket, interval)]
+		if !ok {
+			continue
+		}
+		bucket.Income += row.Income
+		bucket.Expense += row.Expense
+		bucket.Net = bucket.Income - bucket.Expense
+		if groupBy != "" {
+			bucket.Groups = append(bucket.Groups, &TimeSeriesGroup{
+				ID:      row.Group,
+				Income:  row.Income,
+				Expense: row.Expense,
+				Net:     row.Income - row.Expense,
+			})
+		}
+	}
+	return res
+}
@@ -0,0 +1,47 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncateDate(t *testing.T) {
+	//среда
+	d := time.Date(2023, time.May, 17, 15, 30, 0, 0, time.UTC)
+	testCases := map[string]time.Time{
+		model.IntervalDay:     time.Date(2023, time.May, 17, 0, 0, 0, 0, time.UTC),
+		model.IntervalWeek:    time.Date(2023, time.May, 15, 0, 0, 0, 0, time.UTC),
+		model.IntervalMonth:   time.Date(2023, time.May, 1, 0, 0, 0, 0, time.UTC),
+		model.IntervalQuarter: time.Date(2023, time.April, 1, 0, 0, 0, 0, time.UTC),
+		model.IntervalYear:    time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for interval, want := range testCases {
+		t.Run(interval, func(t *testing.T) {
+			assert.Equal(t, want, model.TruncateDate(d, interval))
+		})
+	}
+	sunday := time.Date(2023, time.May, 21, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, testCases[model.IntervalWeek], model.TruncateDate(sunday, model.IntervalWeek))
+}
+
+func TestNewTimeSeries(t *testing.T) {
+	from := time.Date(2023, time.January, 15, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, time.April, 10, 0, 0, 0, 0, time.UTC)
+	rows := []*model.TimeSeriesRow{
+		{Bucket: time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC), Group: 1, Income: 100},
+		{Bucket: time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC), Group: 2, Expense: 30},
+		{Bucket: time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC), Group: 2, Expense: 50},
+	}
+
+	ts := model.NewTimeSeries(from, to, model.IntervalMonth, model.GroupByAccount, rows)
+	assert.Len(t, ts.Buckets, 4)
+	assert.Equal(t, 70.0, ts.Buckets[0].Net)
+	assert.Len(t, ts.Buckets[0].Groups, 2)
+	assert.Equal(t, 0.0, ts.Buckets[1].Net)
+	assert.Empty(t, ts.Buckets[1].Groups)
+	assert.Equal(t, -50.0, ts.Buckets[2].Net)
+	assert.Equal(t, 4, model.CountBuckets(from, to, model.IntervalMonth))
+}
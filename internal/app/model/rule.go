@@ -0,0 +1,175 @@
+package model
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+var (
+	ErrRuleWithoutCondition = errors.New("rule must have at least one condition")
+	ErrRuleWithoutAction    = errors.New("rule must set a destination, type or tags")
+	ErrInvalidRegexp        = errors.New("invalid regular expression")
+	ErrInvalidAmountRange   = errors.New("amount_max is less than amount_min")
+)
+
+// Rule categorizes transactions. A transaction matches when it satisfies
+// every condition that is set: the description contains the substring
+// (ignoring case) and matches the regular expression, the amount is within
+// the range and the source is the given account. A matching rule sets
+// the destination and the type and adds its tags.
+type Rule struct {
+	ID                  int       `json:"id"`
+	User                int       `json:"user"`
+	Name                string    `json:"name"`
+	Priority            int       `json:"priority"`
+	DescriptionContains string    `json:"description_contains,omitempty"`
+	DescriptionRegexp   string    `json:"description_regexp,omitempty"`
+	AmountMin           *float64  `json:"amount_min,omitempty"`
+	AmountMax           *float64  `json:"amount_max,omitempty"`
+	Source              *int      `json:"source,omitempty"`
+	Destination         *int      `json:"destination,omitempty"`
+	Type                string    `json:"type,omitempty"`
+	Tags                []string  `json:"tags"`
+	CreatedAt           time.Time `json:"created_at"`
+
+	re *regexp.Regexp
+}
+
+// RuleChange is how a rule changes a stored transaction.
+type RuleChange struct {
+	Transaction int              `json:"transaction"`
+	Rule        int              `json:"rule"`
+	Before      *TransactionJSON `json:"before"`
+	After       *TransactionJSON `json:"after"`
+}
+
+func (r *Rule) Validate() error {
+	if err := validation.ValidateStruct(
+		r,
+		validation.Field(&r.Name, validation.Required, validation.Length(1, 100)),
+		validation.Field(&r.DescriptionRegexp, validation.By(validateRegexp)),
+		validation.Field(&r.AmountMax, validation.By(validateAmountRange(r))),
+		validation.Field(&r.Type,
+			validation.In(
+				StandardTransaction,
+				IncomeTransaction,
+				ExpenseTransaction,
+			),
+		),
+	); err != nil {
+		return err
+	}
+	if r.DescriptionContains == "" && r.DescriptionRegexp == "" && r.AmountMin == nil && r.AmountMax == nil && r.Source == nil {
+		return ErrRuleWithoutCondition
+	}
+	if r.Destination == nil && r.Type == "" && len(NormalizeTags(r.Tags)) == 0 {
+		return ErrRuleWithoutAction
+	}
+	return nil
+}
+
+func (r *Rule) Matches(t *TransactionJSON) bool {
+	if r.DescriptionContains != "" && !strings.Contains(strings.ToLower(t.Description), strings.ToLower(r.DescriptionContains)) {
+		return false
+	}
+	if r.DescriptionRegexp != "" {
+		if r.re == nil {
+			re, err := regexp.Compile(r.DescriptionRegexp)
+			if err != nil {
+				return false
+			}
+			r.re = re
+		}
+		if !r.re.MatchString(t.Description) {
+			return false
+		}
+	}
+	if r.AmountMin != nil && t.Amount < *r.AmountMin {
+		return false
+	}
+	if r.AmountMax != nil && t.Amount > *r.AmountMax {
+		return false
+	}
+	return r.Source == nil || *r.Source == t.Source
+}
+
+// Apply sets the fields of t the rule defines and reports whether t changed.
+// Unless overwrite is set, only the destination and the type left empty are set.
+func (r *Rule) Apply(t *TransactionJSON, overwrite bool) bool {
+	changed := false
+	if r.Destination != nil && t.Destination != *r.Destination && (overwrite || t.Destination == 0) {
+		t.Destination = *r.Destination
+		changed = true
+	}
+	if r.Type != "" && t.Type != r.Type && (overwrite || t.Type == "") {
+		t.Type = r.Type
+		changed = true
+	}
+	//копируем, чтобы не менять срез исходного перевода
+	tags := NormalizeTags(append(append([]string(nil), t.Tags...), r.Tags...))
+	if len(tags) != len(NormalizeTags(t.Tags)) {
+		changed = true
+	}
+	t.Tags = tags
+	return changed
+}
+
+// ApplyRules applies the first rule matching t and returns it,
+// or nil if none matches. Rules must be ordered by priority.
+func ApplyRules(rules []*Rule, t *TransactionJSON, overwrite bool) *Rule {
+	for _, r := range rules {
+		if r.Matches(t) {
+			r.Apply(t, overwrite)
+			return r
+		}
+	}
+	return nil
+}
+
+// RerunRules applies the rules to stored transactions
+// and returns the ones that would change.
+func RerunRules(rules []*Rule, ts []*TransactionJSON) []*RuleChange {
+	res := make([]*RuleChange, 0)
+	for _, t := range ts {
+		after := *t
+		for _, r := range rules {
+			if !r.Matches(t) {
+				continue
+			}
+			if r.Apply(&after, true) {
+				res = append(res, &RuleChange{
+					Transaction: t.ID,
+					Rule:        r.ID,
+					Before:      t,
+					After:       &after,
+				})
+			}
+			break
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Transaction < res[j].Transaction })
+	return res
+}
+
+func validateRegexp(value interface{}) error {
+	if s, _ := value.(string); s != "" {
+		if _, err := regexp.Compile(s); err != nil {
+			return ErrInvalidRegexp
+		}
+	}
+	return nil
+}
+
+func validateAmountRange(r *Rule) validation.RuleFunc {
+	return func(value interface{}) error {
+		if r.AmountMin != nil && r.AmountMax != nil && *r.AmountMax < *r.AmountMin {
+			return ErrInvalidAmountRange
+		}
+		return nil
+	}
+}
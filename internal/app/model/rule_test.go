@@ -0,0 +1,69 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRule_Validate(t *testing.T) {
+	food := 2
+	min, max := 10.0, 5.0
+	testCases := []struct {
+		name string
+		rule *model.Rule
+		err  error
+	}{
+		{"valid", &model.Rule{Name: "Food", DescriptionContains: "market", Destination: &food}, nil},
+		{"no condition", &model.Rule{Name: "Food", Destination: &food}, model.ErrRuleWithoutCondition},
+		{"no action", &model.Rule{Name: "Food", DescriptionContains: "market"}, model.ErrRuleWithoutAction},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.err, tc.rule.Validate())
+		})
+	}
+
+	assert.Error(t, (&model.Rule{Name: "Food", DescriptionRegexp: "(", Destination: &food}).Validate())
+	assert.Error(t, (&model.Rule{Name: "Food", AmountMin: &min, AmountMax: &max, Destination: &food}).Validate())
+}
+
+func TestApplyRules(t *testing.T) {
+	card, food, cafe := 1, 2, 3
+	max := 20.0
+	rules := []*model.Rule{
+		{ID: 1, DescriptionRegexp: `(?i)^coffee`, AmountMax: &max, Destination: &cafe, Type: model.ExpenseTransaction, Tags: []string{"coffee"}},
+		{ID: 2, DescriptionContains: "MARKET", Source: &card, Destination: &food, Type: model.ExpenseTransaction},
+	}
+
+	tr := &model.TransactionJSON{Source: card, Amount: 50, Description: "Super market", Tags: []string{"weekly"}}
+	assert.Equal(t, rules[1], model.ApplyRules(rules, tr, false))
+	assert.Equal(t, food, tr.Destination)
+	assert.Equal(t, model.ExpenseTransaction, tr.Type)
+	assert.Equal(t, []string{"weekly"}, tr.Tags)
+
+	tr = &model.TransactionJSON{Source: card, Destination: food, Amount: 4, Description: "Coffee to go"}
+	assert.Equal(t, rules[0], model.ApplyRules(rules, tr, false))
+	assert.Equal(t, food, tr.Destination)
+	assert.Equal(t, []string{"coffee"}, tr.Tags)
+
+	tr = &model.TransactionJSON{Source: card, Amount: 40, Description: "Coffee machine"}
+	assert.Nil(t, model.ApplyRules(rules, tr, false))
+}
+
+func TestRerunRules(t *testing.T) {
+	card, food, cafe := 1, 2, 3
+	rules := []*model.Rule{{ID: 1, DescriptionContains: "coffee", Destination: &cafe}}
+	ts := []*model.TransactionJSON{
+		{ID: 2, Source: card, Destination: food, Amount: 4, Description: "Coffee", Type: model.ExpenseTransaction},
+		{ID: 1, Source: card, Destination: cafe, Amount: 3, Description: "Coffee", Type: model.ExpenseTransaction},
+		{ID: 3, Source: card, Destination: food, Amount: 30, Description: "Groceries", Type: model.ExpenseTransaction},
+	}
+
+	changes := model.RerunRules(rules, ts)
+	assert.Len(t, changes, 1)
+	assert.Equal(t, 2, changes[0].Transaction)
+	assert.Equal(t, food, changes[0].Before.Destination)
+	assert.Equal(t, cafe, changes[0].After.Destination)
+}
@@ -12,21 +12,29 @@ type Summary struct {
 	Expense   float64   `json:"expense"`
 }
 
+// AccountFlows is how much a group of accounts received as destination
+// and sent as source of transactions.
+type AccountFlows struct {
+	Inflow  float64
+	Outflow float64
+}
+
 type AccountSummary interface {
 	SetPeriod(time.Time, time.Time) error
-	CalculateSummary([]*TransactionJSON)
+	ApplyFlows(*AccountFlows)
 }
 
 type IncomeAccountSummary struct {
-	AccountID int       `json:"account_id"`
-	DateStart time.Time `json:"date_start"`
-	DateEnd   time.Time `json:"date_end"`
-	Income    float64   `json:"income"`
+	AccountID   int       `json:"account_id"`
+	Descendants []int     `json:"descendants,omitempty"`
+	DateStart   time.Time `json:"date_start"`
+	DateEnd     time.Time `json:"date_end"`
+	Income      float64   `json:"income"`
 }
 
 func validatePeriod(DateStart, DateEnd time.Time) error {
 	if DateStart.After(DateEnd) || DateStart.Equal(DateEnd) {
-		return errors.New("wrong or empty period")
+		return ErrInvalidPeriod
 	}
 	return nil
 }
@@ -39,21 +47,17 @@ func (s *IncomeAccountSummary) SetPeriod(DateStart, DateEnd time.Time) error {
 	return nil
 }
 
-func (s *IncomeAccountSummary) CalculateSummary(ts []*TransactionJSON) {
-	var income float64
-	for _, t := range ts {
-		if t.Source == s.AccountID {
-			income += t.Amount
-		}
-	}
-	s.Income = income
+// ApplyFlows takes the income from flows of the source and its descendants.
+func (s *IncomeAccountSummary) ApplyFlows(f *AccountFlows) {
+	s.Income = f.Outflow
 }
 
 type ExpenseCategorySummary struct {
-	AccountID int       `json:"account_id"`
-	DateStart time.Time `json:"date_start"`
-	DateEnd   time.Time `json:"date_end"`
-	Expense   float64   `json:"expense"`
+	AccountID   int       `json:"account_id"`
+	Descendants []int     `json:"descendants,omitempty"`
+	DateStart   time.Time `json:"date_start"`
+	DateEnd     time.Time `json:"date_end"`
+	Expense     float64   `json:"expense"`
 }
 
 func (s *ExpenseCategorySummary) SetPeriod(DateStart, DateEnd time.Time) error {
@@ -64,14 +68,9 @@ func (s *ExpenseCategorySummary) SetPeriod(DateStart, DateEnd time.Time) error {
 	return nil
 }
 
-func (s *ExpenseCategorySummary) CalculateSummary(ts []*TransactionJSON) {
-	var expense float64
-	for _, t := range ts {
-		if t.Destination == s.AccountID {
-			expense += t.Amount
-		}
-	}
-	s.Expense = expense
+// ApplyFlows takes the expense from flows of the category and its descendants.
+func (s *ExpenseCategorySummary) ApplyFlows(f *AccountFlows) {
+	s.Expense = f.Inflow
 }
 
 type StandardAccountSummary struct {
@@ -90,26 +89,19 @@ func (s *StandardAccountSummary) SetPeriod(DateStart, DateEnd time.Time) error {
 	return nil
 }
 
-func (s *StandardAccountSummary) CalculateSummary(ts []*TransactionJSON) {
-	var income, expense float64
-	for _, t := range ts {
-		if t.Destination == s.AccountID {
-			income += t.Amount
-		}
-		if t.Source == s.AccountID {
-			expense += t.Amount
-		}
-	}
-	s.Income, s.Expense = income, expense
+func (s *StandardAccountSummary) ApplyFlows(f *AccountFlows) {
+	s.Income, s.Expense = f.Inflow, f.Outflow
 }
 
-func GetSummaryByAccount(a *Account) (AccountSummary, error) {
+// GetSummaryByAccount returns an empty summary matching the account type.
+// Category summaries also include transactions of the given descendant categories.
+func GetSummaryByAccount(a *Account, descendants ...int) (AccountSummary, error) {
 	if a.Type == CurrentAccount || a.Type == DebtAccount || a.Type == SavingAccount {
 		return &StandardAccountSummary{AccountID: a.ID}, nil
 	} else if a.Type == ExpenseCatogoryAccount {
-		return &ExpenseCategorySummary{AccountID: a.ID}, nil
+		return &ExpenseCategorySummary{AccountID: a.ID, Descendants: descendants}, nil
 	} else if a.Type == IncomeSourceAccount {
-		return &IncomeAccountSummary{AccountID: a.ID}, nil
+		return &IncomeAccountSummary{AccountID: a.ID, Descendants: descendants}, nil
 	} else {
 		return nil, errors.New("no AccountSummary for this account type")
 	}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strings"
 	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation"
@@ -13,25 +14,31 @@ const (
 )
 
 type TransactionDB struct {
-	ID              int       `json:"id"`
-	CreationDate    time.Time `json:"-"`
-	TransactionDate time.Time `json:"transaction_date"`
-	Source          *Account  `json:"source"`
-	Destination     *Account  `json:"destination"`
-	Amount          float64   `json:"amount"`
-	Type            string    `json:"type"`
-	Description     string    `json:"description"`
+	ID              int        `json:"id"`
+	CreationDate    time.Time  `json:"-"`
+	TransactionDate time.Time  `json:"transaction_date"`
+	Source          *Account   `json:"source"`
+	Destination     *Account   `json:"destination"`
+	Amount          float64    `json:"amount"`
+	Type            string     `json:"type"`
+	Description     string     `json:"description"`
+	Tags            []string   `json:"tags"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+	Version         int        `json:"version"`
 }
 
 type TransactionJSON struct {
-	ID              int       `json:"id"`
-	CreationDate    time.Time `json:"-"`
-	TransactionDate time.Time `json:"transaction_date"`
-	Source          int       `json:"source"`
-	Destination     int       `json:"destination"`
-	Amount          float64   `json:"amount"`
-	Type            string    `json:"type"`
-	Description     string    `json:"description"`
+	ID              int        `json:"id"`
+	CreationDate    time.Time  `json:"-"`
+	TransactionDate time.Time  `json:"transaction_date"`
+	Source          int        `json:"source"`
+	Destination     int        `json:"destination"`
+	Amount          float64    `json:"amount"`
+	Type            string     `json:"type"`
+	Description     string     `json:"description"`
+	Tags            []string   `json:"tags"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+	Version         int        `json:"version"`
 }
 
 func (t *TransactionDB) Validate() error {
@@ -67,6 +74,38 @@ func (t *TransactionDB) ToJSON() *TransactionJSON {
 		Amount:          t.Amount,
 		Type:            t.Type,
 		Description:     t.Description,
+		Tags:            t.Tags,
+		DeletedAt:       t.DeletedAt,
+		Version:         t.Version,
+	}
+	return res
+}
+
+// BalanceEffect returns how the transaction changes the balance of the account.
+// Only standard accounts are affected, categories keep no balance.
+func (t *TransactionJSON) BalanceEffect(accountID int) float64 {
+	var res float64
+	if t.Source == accountID && (t.Type == StandardTransaction || t.Type == ExpenseTransaction) {
+		res -= t.Amount
+	}
+	if t.Destination == accountID && (t.Type == IncomeTransaction || t.Type == StandardTransaction) {
+		res += t.Amount
+	}
+	return res
+}
+
+// NormalizeTags trims tags and drops empty and repeated ones, keeping the order.
+// The result is never nil.
+func NormalizeTags(tags []string) []string {
+	res := make([]string, 0, len(tags))
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		res = append(res, tag)
 	}
 	return res
 }
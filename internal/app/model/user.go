@@ -3,7 +3,6 @@ package model
 import (
 	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/go-ozzo/ozzo-validation/is"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
@@ -37,14 +36,15 @@ func (u *User) Sanitize() {
 }
 
 func (u *User) ComparePassword(password string) bool {
-	return bcrypt.CompareHashAndPassword([]byte(u.EncryptedPassword), []byte(password)) == nil
+	return comparePasswordHash(u.EncryptedPassword, password)
 }
 
-func encryptString(s string) (string, error) {
-	b, err := bcrypt.GenerateFromPassword([]byte(s), bcrypt.MinCost)
+// NeedsRehash reports whether the stored hash was produced under
+// a different algorithm or cost than the current password policy.
+func (u *User) NeedsRehash() bool {
+	return !passwordPolicy.matches(u.EncryptedPassword)
+}
 
-	if err != nil {
-		return "", err
-	}
-	return string(b), nil
+func encryptString(s string) (string, error) {
+	return passwordPolicy.hash(s)
 }
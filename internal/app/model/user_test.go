@@ -83,3 +83,64 @@ func TestUser_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestUser_ComparePassword(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy *model.PasswordPolicy
+	}{
+		{
+			name:   "bcrypt",
+			policy: model.DefaultPasswordPolicy(),
+		},
+		{
+			name: "argon2id",
+			policy: &model.PasswordPolicy{
+				Algorithm:     model.Argon2idAlgorithm,
+				Argon2Time:    1,
+				Argon2Memory:  1024,
+				Argon2Threads: 1,
+			},
+		},
+	}
+	defer model.SetPasswordPolicy(model.DefaultPasswordPolicy())
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.NoError(t, model.SetPasswordPolicy(tc.policy))
+			u := model.TestUser(t)
+			assert.NoError(t, u.BeforeCreate())
+			assert.True(t, u.ComparePassword(u.Password))
+			assert.False(t, u.ComparePassword(u.Password+"123"))
+			assert.False(t, u.NeedsRehash())
+		})
+	}
+}
+
+func TestUser_NeedsRehash(t *testing.T) {
+	defer model.SetPasswordPolicy(model.DefaultPasswordPolicy())
+	u := model.TestUser(t)
+	assert.NoError(t, u.BeforeCreate())
+
+	p := model.DefaultPasswordPolicy()
+	p.BcryptCost++
+	assert.NoError(t, model.SetPasswordPolicy(p))
+	assert.True(t, u.NeedsRehash())
+	assert.True(t, u.ComparePassword(u.Password))
+
+	p.Algorithm = model.Argon2idAlgorithm
+	assert.NoError(t, model.SetPasswordPolicy(p))
+	assert.True(t, u.NeedsRehash())
+	assert.True(t, u.ComparePassword(u.Password))
+}
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	p := model.DefaultPasswordPolicy()
+	assert.NoError(t, p.Validate())
+
+	p.Algorithm = "md5"
+	assert.Error(t, p.Validate())
+
+	p = model.DefaultPasswordPolicy()
+	p.BcryptCost = 100
+	assert.Error(t, p.Validate())
+}
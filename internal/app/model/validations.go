@@ -6,6 +6,13 @@ import (
 	validation "github.com/go-ozzo/ozzo-validation"
 )
 
+var (
+	ErrTransactionTypeMismatch = errors.New("transaction and account type mismatch")
+	ErrParentNotCategory       = errors.New("only income and expense categories can have a parent")
+	ErrInvalidPeriod           = errors.New("wrong or empty period")
+	ErrDebtOnly                = errors.New("only Debt accounts can have this attribute")
+)
+
 func requieredIf(cond bool) validation.RuleFunc {
 	return func(value interface{}) error {
 		if cond {
@@ -18,7 +25,7 @@ func requieredIf(cond bool) validation.RuleFunc {
 
 func validateTransactionType(transactionType, sourceAccountType, destinationAccountType string) validation.RuleFunc {
 	return func(value interface{}) error {
-		validationError := errors.New("transaction and account type mismatch")
+		validationError := ErrTransactionTypeMismatch
 		isStandardAccount := func(val string) bool {
 			var standardAccountTypes []string = []string{CurrentAccount, DebtAccount, SavingAccount}
 			for _, v := range standardAccountTypes {
@@ -45,3 +52,24 @@ func validateTransactionType(transactionType, sourceAccountType, destinationAcco
 		return nil
 	}
 }
+
+func validateAccountParent(a *Account) validation.RuleFunc {
+	return func(value interface{}) error {
+		if a.Parent == nil {
+			return nil
+		}
+		if !a.IsCategory() {
+			return ErrParentNotCategory
+		}
+		return nil
+	}
+}
+
+func validateDebtOnly(a *Account) validation.RuleFunc {
+	return func(value interface{}) error {
+		if a.IsLiability() || validation.IsEmpty(value) {
+			return nil
+		}
+		return ErrDebtOnly
+	}
+}
@@ -0,0 +1,132 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/events"
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookEvents are the events a webhook can subscribe to.
+var WebhookEvents = []string{
+	events.TransactionCreated,
+	events.TransactionUpdated,
+	events.TransactionDeleted,
+	events.TransactionRestored,
+}
+
+var (
+	ErrUnknownWebhookEvent   = errors.New("unknown webhook event")
+	ErrWebhookScheme         = errors.New("webhook URL must use http or https")
+	ErrWebhookPrivateAddress = errors.New("webhook URL must not point to a private, loopback or link-local address")
+)
+
+// Webhook is an endpoint of the user notified about transaction changes.
+// Secret signs the payloads and is only shown when the webhook is created.
+type Webhook struct {
+	ID        int       `json:"id"`
+	User      int       `json:"user"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (w *Webhook) Validate() error {
+	return validation.ValidateStruct(
+		w,
+		validation.Field(&w.URL, validation.Required, is.URL, validation.By(validateWebhookURL)),
+		validation.Field(&w.Secret, validation.Required),
+		validation.Field(&w.Events, validation.Required, validation.By(validateWebhookEvents)),
+	)
+}
+
+func (w *Webhook) Sanitize() {
+	w.Secret = ""
+}
+
+func (w *Webhook) Subscribed(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func validateWebhookEvents(value interface{}) error {
+	for _, e := range value.([]string) {
+		known := false
+		for _, k := range WebhookEvents {
+			known = known || e == k
+		}
+		if !known {
+			return ErrUnknownWebhookEvent
+		}
+	}
+	return nil
+}
+
+// validateWebhookURL rejects URLs the server must not call. Host names are
+// checked again when deliveries connect, as they can resolve to any address.
+func validateWebhookURL(value interface{}) error {
+	u, err := url.Parse(value.(string))
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrWebhookScheme
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrWebhookPrivateAddress
+	}
+	if ip := net.ParseIP(host); ip != nil && !IsPublicIP(ip) {
+		return ErrWebhookPrivateAddress
+	}
+	return nil
+}
+
+// IsPublicIP reports whether webhooks may be delivered to ip.
+func IsPublicIP(ip net.IP) bool {
+	return !ip.IsPrivate() &&
+		!ip.IsLoopback() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsUnspecified() &&
+		!ip.IsMulticast()
+}
+
+// WebhookDelivery is one event queued for a webhook, together with
+// the outcome of the last attempt to send it.
+type WebhookDelivery struct {
+	ID             int             `json:"id"`
+	WebhookID      int             `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// WebhookPayload is the body sent to webhooks.
+type WebhookPayload struct {
+	Event string      `json:"event"`
+	Data  interface{} `json:"data"`
+}
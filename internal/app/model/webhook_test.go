@@ -0,0 +1,72 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/events"
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhook_Validate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		url     string
+		isValid bool
+	}{
+		{
+			name:    "https",
+			url:     "https://example.org/hook",
+			isValid: true,
+		},
+		{
+			name:    "public ip",
+			url:     "http://93.184.216.34/hook",
+			isValid: true,
+		},
+		{
+			name:    "ftp",
+			url:     "ftp://example.org/hook",
+			isValid: false,
+		},
+		{
+			name:    "localhost",
+			url:     "http://localhost:8080/hook",
+			isValid: false,
+		},
+		{
+			name:    "loopback",
+			url:     "http://127.0.0.1/hook",
+			isValid: false,
+		},
+		{
+			name:    "private",
+			url:     "http://10.0.0.5/hook",
+			isValid: false,
+		},
+		{
+			name:    "link-local",
+			url:     "http://169.254.169.254/latest/meta-data",
+			isValid: false,
+		},
+		{
+			name:    "ipv6 loopback",
+			url:     "http://[::1]/hook",
+			isValid: false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := &model.Webhook{
+				URL:    tc.url,
+				Secret: "secret",
+				Events: []string{events.TransactionCreated},
+			}
+			if tc.isValid {
+				assert.NoError(t, w.Validate())
+			} else {
+				assert.Error(t, w.Validate())
+			}
+		})
+	}
+}
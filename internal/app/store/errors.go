@@ -3,7 +3,17 @@ package store
 import "errors"
 
 var (
-	ErrRecordNotFound    = errors.New("record not found")
-	ErrUserAlreadyExists = errors.New("user with email already exists")
-	ErrInsufficientFunds = errors.New("not enough funds on source account")
+	ErrRecordNotFound      = errors.New("record not found")
+	ErrUserAlreadyExists   = errors.New("user with email already exists")
+	ErrInsufficientFunds   = errors.New("not enough funds on source account")
+	ErrAccountOwner        = errors.New("user is the owner of the account")
+	ErrInvalidParent       = errors.New("parent must be a category of the same type and owner")
+	ErrCategoryCycle       = errors.New("category parent would create a cycle")
+	ErrAccountArchived     = errors.New("account is archived")
+	ErrAccountNotArchived  = errors.New("account is not archived")
+	ErrAccountHasHistory   = errors.New("account has transactions, archive it instead")
+	ErrVersionConflict     = errors.New("record was modified by another request")
+	ErrIdempotencyKeyUsed  = errors.New("idempotency key is already used")
+	ErrCreditLimitExceeded = errors.New("transaction exceeds the credit limit of the debt account")
+	ErrDuplicateResolved   = errors.New("duplicate is already merged or dismissed")
 )
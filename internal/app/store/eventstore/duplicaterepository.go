@@ -0,0 +1,25 @@
+package eventstore
+
+import (
+	"github.com/Aza-9798/costs-rest-api/internal/app/events"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+)
+
+type DuplicateRepository struct {
+	store.DuplicateRepo
+	store *Store
+}
+
+func (r *DuplicateRepository) Merge(id, drop int) error {
+	if err := r.DuplicateRepo.Merge(id, drop); err != nil {
+		return err
+	}
+	if t, err := r.store.Store.Transaction().FindDeleted(drop); err == nil {
+		r.store.publish(&change{
+			eventType:  events.TransactionDeleted,
+			data:       t,
+			accountIDs: []int{t.Source, t.Destination},
+		})
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package eventstore
+
+import (
+	"github.com/Aza-9798/costs-rest-api/internal/app/events"
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+)
+
+// Store wraps another store and publishes an event after every successful
+// change of transactions, along with the new balances of affected accounts.
+// Everything else is passed to the wrapped store unchanged.
+type Store struct {
+	store.Store
+	broker events.Broker
+}
+
+func New(s store.Store, broker events.Broker) *Store {
+	return &Store{
+		Store:  s,
+		broker: broker,
+	}
+}
+
+func (s *Store) WithActor(actor int, requestID string) store.Store {
+	return New(s.Store.WithActor(actor, requestID), s.broker)
+}
+
+func (s *Store) Transaction() store.TransactionRepo {
+	return &TransactionRepository{
+		TransactionRepo: s.Store.Transaction(),
+		store:           s,
+	}
+}
+
+func (s *Store) Duplicate() store.DuplicateRepo {
+	return &DuplicateRepository{
+		DuplicateRepo: s.Store.Duplicate(),
+		store:         s,
+	}
+}
+
+// change is an event about a transaction and the accounts it touched.
+type change struct {
+	eventType  string
+	data       interface{}
+	accountIDs []int
+}
+
+// publish sends every change to everyone who can see one of its accounts
+// and then the current balance of each account. Users and balances are
+// looked up once per batch, not once per change.
+func (s *Store) publish(changes ...*change) {
+	if len(changes) == 0 {
+		return
+	}
+	ids := make([]int, 0)
+	for _, c := range changes {
+		ids = append(ids, c.accountIDs...)
+	}
+	users, err := s.Store.Account().GetUsers(ids)
+	if err != nil {
+		return
+	}
+	accounts := make(map[int]*model.Account)
+	for _, c := range changes {
+		s.broker.Publish(&events.Event{
+			Type:  c.eventType,
+			Data:  c.data,
+			Users: usersOf(users, c.accountIDs),
+		})
+		seen := make(map[int]bool)
+		for _, id := range c.accountIDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			a, ok := accounts[id]
+			if !ok {
+				if a, err = s.Store.Account().Find(id); err != nil {
+					continue
+				}
+				accounts[id] = a
+			}
+			s.broker.Publish(&events.Event{
+				Type: events.AccountBalance,
+				Data: &events.Balance{
+					AccountID: a.ID,
+					Balance:   a.Balance,
+					Version:   a.Version,
+				},
+				Users: users[id],
+			})
+		}
+	}
+}
+
+// usersOf returns the users of the accounts without duplicates.
+func usersOf(users map[int][]int, accountIDs []int) []int {
+	seen := make(map[int]bool)
+	res := make([]int, 0)
+	for _, id := range accountIDs {
+		for _, u := range users[id] {
+			if !seen[u] {
+				seen[u] = true
+				res = append(res, u)
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,111 @@
+package eventstore
+
+import (
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/events"
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+)
+
+type TransactionRepository struct {
+	store.TransactionRepo
+	store *Store
+}
+
+func (r *TransactionRepository) Create(t *model.TransactionDB) error {
+	if err := r.TransactionRepo.Create(t); err != nil {
+		return err
+	}
+	r.store.publish(created(t))
+	return nil
+}
+
+func (r *TransactionRepository) CreateBatch(ts []*model.TransactionDB, atomic bool) ([]error, error) {
+	errs, err := r.TransactionRepo.CreateBatch(ts, atomic)
+	if err != nil {
+		return errs, err
+	}
+	for _, itemErr := range errs {
+		if itemErr != nil && atomic {
+			return errs, nil
+		}
+	}
+	changes := make([]*change, 0, len(ts))
+	for i, t := range ts {
+		if errs[i] == nil {
+			changes = append(changes, created(t))
+		}
+	}
+	r.store.publish(changes...)
+	return errs, nil
+}
+
+func (r *TransactionRepository) Save(t *model.TransactionDB) error {
+	before, err := r.TransactionRepo.Find(t.ID)
+	if err != nil {
+		return err
+	}
+	if err := r.TransactionRepo.Save(t); err != nil {
+		return err
+	}
+	//старые счета тоже получают новый баланс
+	r.store.publish(&change{
+		eventType: events.TransactionUpdated,
+		data:      t.ToJSON(),
+		accountIDs: []int{
+			before.Source,
+			before.Destination,
+			t.Source.ID,
+			t.Destination.ID,
+		},
+	})
+	return nil
+}
+
+func (r *TransactionRepository) Delete(t *model.TransactionJSON) error {
+	if err := r.TransactionRepo.Delete(t); err != nil {
+		return err
+	}
+	r.store.publish(&change{
+		eventType:  events.TransactionDeleted,
+		data:       t,
+		accountIDs: []int{t.Source, t.Destination},
+	})
+	return nil
+}
+
+func (r *TransactionRepository) Restore(id int) error {
+	if err := r.TransactionRepo.Restore(id); err != nil {
+		return err
+	}
+	if t, err := r.TransactionRepo.Find(id); err == nil {
+		r.store.publish(&change{
+			eventType:  events.TransactionRestored,
+			data:       t,
+			accountIDs: []int{t.Source, t.Destination},
+		})
+	}
+	return nil
+}
+
+func (r *TransactionRepository) AccrueInterest(accountID int, through time.Time) ([]*model.TransactionDB, error) {
+	ts, err := r.TransactionRepo.AccrueInterest(accountID, through)
+	if err != nil {
+		return ts, err
+	}
+	changes := make([]*change, 0, len(ts))
+	for _, t := range ts {
+		changes = append(changes, created(t))
+	}
+	r.store.publish(changes...)
+	return ts, nil
+}
+
+func created(t *model.TransactionDB) *change {
+	return &change{
+		eventType:  events.TransactionCreated,
+		data:       t.ToJSON(),
+		accountIDs: []int{t.Source.ID, t.Destination.ID},
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 type UserRepo interface {
 	Create(user *model.User) error
+	Save(*model.User) error
 	Find(int) (*model.User, error)
 	FindByEmail(string) (*model.User, error)
 }
@@ -16,18 +17,109 @@ type AccountRepo interface {
 	Create(account *model.Account) error
 	Delete(int) error
 	Save(*model.Account) error
+	// Archive returns ErrAccountArchived and Unarchive ErrAccountNotArchived
+	// when the account is already in the requested state.
+	Archive(int) error
+	Unarchive(int) error
 	Find(int) (*model.Account, error)
-	GetAllByUser(int) ([]*model.Account, error)
-	IsAccountBelongsUser(int, int) (bool, error)
+	GetAllByUser(userID int, withArchived bool) ([]*model.Account, error)
+	GetDescendants(int) ([]int, error)
+	GetInterestDue(through time.Time) ([]int, error)
+	GetRole(int, int) (string, error)
+	AddMember(*model.AccountMember) error
+	RemoveMember(int, int) error
+	GetMembers(int) ([]*model.AccountMember, error)
+	// GetUsers maps each of the accounts to its owner and members.
+	GetUsers(accountIDs []int) (map[int][]int, error)
 }
 
 type TransactionRepo interface {
 	Create(transaction *model.TransactionDB) error
+	CreateBatch(transactions []*model.TransactionDB, atomic bool) ([]error, error)
 	Delete(transaction *model.TransactionJSON) error
+	Restore(int) error
+	PurgeTrash(time.Time) (int, error)
 	Save(*model.TransactionDB) error
 	Find(int) (*model.TransactionJSON, error)
+	FindDeleted(int) (*model.TransactionJSON, error)
 	GetAllByAccount(int) ([]*model.TransactionJSON, error)
 	GetAllByAccountAndPeriod(int, time.Time, time.Time) ([]*model.TransactionJSON, error)
 	GetAllByUser(int) ([]*model.TransactionJSON, error)
+	GetAllByUserAndPeriod(int, time.Time, time.Time) ([]*model.TransactionJSON, error)
+	GetTrash(int) ([]*model.TransactionJSON, error)
 	GetSummary(int, time.Time, time.Time) (*model.Summary, error)
+	GetCategoryTotals(int, time.Time, time.Time) (map[int]float64, error)
+	GetAccountFlows(accountIDs []int, DateStart, DateEnd time.Time) (*model.AccountFlows, error)
+	// GetTimeSeries returns the user's income and expense per interval bucket,
+	// split by account or category when groupBy is set.
+	GetTimeSeries(userID int, DateStart, DateEnd time.Time, interval, groupBy string) ([]*model.TimeSeriesRow, error)
+	// GetBalanceChanges returns how transactions dated since the given day
+	// changed the balance of each of the user's accounts, per interval bucket.
+	GetBalanceChanges(userID int, since time.Time, interval string) ([]*model.BalanceChange, error)
+	// GetCategoryComparison returns category totals for the period
+	// and for the previous one, which starts at prevStart and ends right before DateStart.
+	GetCategoryComparison(userID int, DateStart, DateEnd, prevStart time.Time) ([]*model.CategoryComparison, error)
+	AccrueInterest(accountID int, through time.Time) ([]*model.TransactionDB, error)
+}
+
+type AuditRepo interface {
+	Create(*model.AuditEntry) error
+	GetByEntity(entity string, entityID int) ([]*model.AuditEntry, error)
+}
+
+type IdempotencyKeyRepo interface {
+	Create(*model.IdempotencyKey) error
+	Save(*model.IdempotencyKey) error
+	Delete(userID int, key string) error
+	Find(userID int, key string) (*model.IdempotencyKey, error)
+	Purge(before time.Time) (int, error)
+}
+
+type WebhookRepo interface {
+	Create(*model.Webhook) error
+	Delete(id int) error
+	Find(id int) (*model.Webhook, error)
+	GetAllByUser(userID int) ([]*model.Webhook, error)
+	GetDeliveries(webhookID int) ([]*model.WebhookDelivery, error)
+	// ClaimDeliveries returns up to limit pending deliveries due at now
+	// and postpones them until lease, so other workers skip them meanwhile.
+	ClaimDeliveries(now, lease time.Time, limit int) ([]*model.WebhookDelivery, error)
+	SaveDelivery(*model.WebhookDelivery) error
+}
+
+type AnomalySettingsRepo interface {
+	// Find returns the default settings if the user has not saved any.
+	Find(userID int) (*model.AnomalySettings, error)
+	Save(*model.AnomalySettings) error
+}
+
+type GoalRepo interface {
+	Create(*model.Goal) error
+	Save(*model.Goal) error
+	Delete(id int) error
+	Find(id int) (*model.Goal, error)
+	// GetAllByUser returns goals on every account the user has access to.
+	GetAllByUser(userID int) ([]*model.Goal, error)
+}
+
+type RuleRepo interface {
+	Create(*model.Rule) error
+	Save(*model.Rule) error
+	Delete(id int) error
+	Find(id int) (*model.Rule, error)
+	// GetAllByUser returns the user's rules in the order they are applied.
+	GetAllByUser(userID int) ([]*model.Rule, error)
+}
+
+// DuplicateRepo reviews the likely duplicates flagged when transactions are created.
+type DuplicateRepo interface {
+	// GetPending returns unresolved pairs of the user's transactions
+	// where neither transaction is deleted.
+	GetPending(userID int) ([]*model.DuplicateCandidate, error)
+	Find(id int) (*model.DuplicateCandidate, error)
+	// Resolve marks a pending pair as merged or dismissed.
+	Resolve(id int, status string) error
+	// Merge marks a pending pair as merged and moves its transaction drop
+	// to the trash, both or neither.
+	Merge(id, drop int) error
 }
@@ -2,11 +2,48 @@ package sqlstore
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/Aza-9798/costs-rest-api/internal/app/model"
 	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+	"github.com/lib/pq"
 )
 
+// userAccountsQuery selects ids of accounts owned by or shared with the user in $1.
+const userAccountsQuery = "select id from accounts where user_id = $1" +
+	" union select account_id from account_members where user_id = $1"
+
+const accountColumns = "id, creation_date, user_id, name, account_type, balance, description, parent_id, archived_at, version," +
+	" credit_limit, apr, minimum_payment, due_day, interest_accrued_through"
+
+type scanner interface {
+	Scan(...interface{}) error
+}
+
+func scanAccount(row scanner) (*model.Account, error) {
+	a := &model.Account{}
+	if err := row.Scan(
+		&a.ID,
+		&a.CreationDate,
+		&a.User,
+		&a.Name,
+		&a.Type,
+		&a.Balance,
+		&a.Description,
+		&a.Parent,
+		&a.ArchivedAt,
+		&a.Version,
+		&a.CreditLimit,
+		&a.APR,
+		&a.MinimumPayment,
+		&a.DueDay,
+		&a.InterestAccruedThrough,
+	); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
 type AccountRepository struct {
 	store *Store
 }
@@ -15,50 +52,167 @@ func (r *AccountRepository) Create(a *model.Account) error {
 	if err := a.Validate(); err != nil {
 		return err
 	}
+	if err := r.checkParent(a); err != nil {
+		return err
+	}
+
+	tx, err := r.store.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	return r.store.db.QueryRow("insert into accounts(name, user_id, account_type, description, balance) values($1, $2, $3, $4, $5) returning id, creation_date",
+	if err := tx.QueryRow("insert into accounts(name, user_id, account_type, description, balance, parent_id,"+
+		" credit_limit, apr, minimum_payment, due_day)"+
+		" values($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)"+
+		" returning id, creation_date, version, interest_accrued_through",
 		a.Name,
 		a.User,
 		a.Type,
 		a.Description,
 		a.Balance,
-	).Scan(&a.ID, &a.CreationDate)
+		a.Parent,
+		a.CreditLimit,
+		a.APR,
+		a.MinimumPayment,
+		a.DueDay,
+	).Scan(&a.ID, &a.CreationDate, &a.Version, &a.InterestAccruedThrough); err != nil {
+		return err
+	}
+	if err := r.store.record(tx, model.AuditEntityAccount, a.ID, model.AuditCreate, nil, a); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (r *AccountRepository) Save(a *model.Account) error {
 	if err := a.Validate(); err != nil {
 		return err
 	}
-	if _, err := r.Find(a.ID); err != nil {
-		return store.ErrRecordNotFound
+	if err := r.checkParent(a); err != nil {
+		return err
 	}
-	if _, err := r.store.db.Exec(
+	tx, err := r.store.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkCycle(tx, a); err != nil {
+		return err
+	}
+	before, err := lockAccount(tx, a.ID)
+	if err != nil {
+		return err
+	}
+	//обновляем только если с момента чтения счет никто не менял
+	//баланс меняется только переводами
+	if err := tx.QueryRow(
 		"update accounts"+
-			" set name = $1, description = $2, balance = $3"+
-			" where id = $4",
+			" set name = $1, description = $2, parent_id = $3,"+
+			" credit_limit = $4, apr = $5, minimum_payment = $6, due_day = $7, version = version + 1"+
+			" where id = $8 and version = $9"+
+			" returning balance, version, interest_accrued_through",
 		a.Name,
 		a.Description,
-		a.Balance,
+		a.Parent,
+		a.CreditLimit,
+		a.APR,
+		a.MinimumPayment,
+		a.DueDay,
 		a.ID,
+		a.Version,
+	).Scan(&a.Balance, &a.Version, &a.InterestAccruedThrough); err != nil {
+		if err == sql.ErrNoRows {
+			return store.ErrVersionConflict
+		}
+		return err
+	}
+	after, err := lockAccount(tx, a.ID)
+	if err != nil {
+		return err
+	}
+	if err := r.store.record(tx, model.AuditEntityAccount, a.ID, model.AuditUpdate, before, after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkParent makes sure the parent is a category of the same type and owner.
+func (r *AccountRepository) checkParent(a *model.Account) error {
+	if a.Parent == nil {
+		return nil
+	}
+	parent, err := r.Find(*a.Parent)
+	if err != nil {
+		if err == store.ErrRecordNotFound {
+			return store.ErrInvalidParent
+		}
+		return err
+	}
+	if parent.Type != a.Type || parent.User != a.User {
+		return store.ErrInvalidParent
+	}
+	return nil
+}
+
+// checkCycle rejects a parent that is the account itself or one of its descendants.
+// The owner's categories of the same type are locked in id order until the end
+// of tx first, so that two moves in opposite directions are checked one after
+// another and can't both pass.
+func checkCycle(tx *sql.Tx, a *model.Account) error {
+	if a.Parent == nil {
+		return nil
+	}
+	if *a.Parent == a.ID {
+		return store.ErrCategoryCycle
+	}
+	if _, err := tx.Exec(
+		"select id from accounts where user_id = $1 and account_type = $2 order by id for update",
+		a.User,
+		a.Type,
 	); err != nil {
 		return err
 	}
+	var cycle bool
+	if err := tx.QueryRow(
+		"with recursive tree(id) as ("+
+			" select id from accounts where parent_id = $1"+
+			" union"+
+			" select c.id from accounts c join tree on c.parent_id = tree.id"+
+			") select exists(select 1 from tree where id = $2)",
+		a.ID,
+		*a.Parent,
+	).Scan(&cycle); err != nil {
+		return err
+	}
+	if cycle {
+		return store.ErrCategoryCycle
+	}
 	return nil
 }
 
+// lockAccount reads the account within tx and locks it until the end of tx.
+func lockAccount(tx *sql.Tx, id int) (*model.Account, error) {
+	a, err := scanAccount(tx.QueryRow(
+		"select "+accountColumns+" from accounts where id = $1 for update",
+		id,
+	))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, store.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return a, nil
+}
+
 func (r *AccountRepository) Find(id int) (*model.Account, error) {
-	a := &model.Account{}
-	if err := r.store.db.QueryRow("select * from accounts where id = $1",
+	a, err := scanAccount(r.store.db.QueryRow(
+		"select "+accountColumns+" from accounts where id = $1",
 		id,
-	).Scan(
-		&a.ID,
-		&a.CreationDate,
-		&a.Type,
-		&a.Balance,
-		&a.User,
-		&a.Name,
-		&a.Description,
-	); err != nil {
+	))
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, store.ErrRecordNotFound
 		}
@@ -67,40 +221,100 @@ func (r *AccountRepository) Find(id int) (*model.Account, error) {
 	return a, nil
 }
 
+// Delete removes an account for good. Accounts with transaction history
+// can only be archived, so that past summaries stay intact.
 func (r *AccountRepository) Delete(id int) error {
-	res, err := r.store.db.Exec("delete from accounts where id = $1", id)
+	tx, err := r.store.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockAccount(tx, id)
+	if err != nil {
+		return err
+	}
+	var hasHistory bool
+	if err := tx.QueryRow(
+		"select exists(select 1 from transactions where source = $1 or destination = $1)",
+		id,
+	).Scan(&hasHistory); err != nil {
+		return err
+	}
+	if hasHistory {
+		return store.ErrAccountHasHistory
+	}
+	if _, err := tx.Exec("delete from accounts where id = $1", id); err != nil {
+		return err
+	}
+	if err := r.store.record(tx, model.AuditEntityAccount, id, model.AuditDelete, before, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *AccountRepository) Archive(id int) error {
+	now := time.Now()
+	return r.setArchivedAt(id, &now, model.AuditArchive)
+}
+
+func (r *AccountRepository) Unarchive(id int) error {
+	return r.setArchivedAt(id, nil, model.AuditUnarchive)
+}
+
+func (r *AccountRepository) setArchivedAt(id int, archivedAt *time.Time, operation string) error {
+	tx, err := r.store.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockAccount(tx, id)
 	if err != nil {
 		return err
 	}
-	if count, err := res.RowsAffected(); err != nil {
+	if err := checkArchiveState(before, archivedAt != nil); err != nil {
 		return err
-	} else if count == 0 {
-		return store.ErrRecordNotFound
+	}
+	if _, err := tx.Exec("update accounts set archived_at = $1, version = version + 1 where id = $2", archivedAt, id); err != nil {
+		return err
+	}
+	after, err := lockAccount(tx, id)
+	if err != nil {
+		return err
+	}
+	if err := r.store.record(tx, model.AuditEntityAccount, id, operation, before, after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkArchiveState makes sure the account isn't already archived, or unarchived.
+func checkArchiveState(a *model.Account, archive bool) error {
+	if archive && a.IsArchived() {
+		return store.ErrAccountArchived
+	}
+	if !archive && !a.IsArchived() {
+		return store.ErrAccountNotArchived
 	}
 	return nil
 }
 
-func (r *AccountRepository) GetAllByUser(userID int) ([]*model.Account, error) {
+// GetAllByUser returns accounts owned by or shared with the user.
+// Archived accounts are skipped unless withArchived is set.
+func (r *AccountRepository) GetAllByUser(userID int, withArchived bool) ([]*model.Account, error) {
 	rows, err := r.store.db.Query(
-		"select id, creation_date, user_id, name, account_type, balance, description "+
-			"from accounts "+
-			"where user_id = $1", userID)
+		"select "+accountColumns+
+			" from accounts "+
+			"where id in ("+userAccountsQuery+") "+
+			"and ($2 or archived_at is null)", userID, withArchived)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	res := make([]*model.Account, 0)
 	for rows.Next() {
-		a := &model.Account{}
-		err := rows.Scan(
-			&a.ID,
-			&a.CreationDate,
-			&a.User,
-			&a.Name,
-			&a.Type,
-			&a.Balance,
-			&a.Description,
-		)
+		a, err := scanAccount(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -112,14 +326,207 @@ func (r *AccountRepository) GetAllByUser(userID int) ([]*model.Account, error) {
 	return res, nil
 }
 
-func (r *AccountRepository) IsAccountBelongsUser(accountID, userID int) (bool, error) {
-	var existFlag int
+// GetInterestDue returns ids of Debt accounts with an interest rate
+// that were not charged interest through the given day yet.
+func (r *AccountRepository) GetInterestDue(through time.Time) ([]int, error) {
+	rows, err := r.store.db.Query(
+		"select id from accounts"+
+			" where account_type = $1 and apr > 0 and archived_at is null"+
+			" and interest_accrued_through < $2",
+		model.DebtAccount,
+		through,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		res = append(res, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetDescendants returns ids of all accounts nested under the account at any depth.
+func (r *AccountRepository) GetDescendants(id int) ([]int, error) {
+	rows, err := r.store.db.Query(
+		"with recursive tree(id) as ("+
+			" select id from accounts where parent_id = $1"+
+			" union all"+
+			" select a.id from accounts a join tree on a.parent_id = tree.id"+
+			") select id from tree",
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]int, 0)
+	for rows.Next() {
+		var childID int
+		if err := rows.Scan(&childID); err != nil {
+			return nil, err
+		}
+		res = append(res, childID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetRole returns the role userID has on the account: owner for the account
+// creator, the member role for users it was shared with, or an empty string.
+func (r *AccountRepository) GetRole(accountID, userID int) (string, error) {
+	var role string
 	if err := r.store.db.QueryRow(
-		"select case when exists(select * from accounts where id = $1 and user_id = $2) then 1 else 0 end",
+		"select case when a.user_id = $2 then $3::varchar else coalesce(m.role, '') end"+
+			" from accounts a"+
+			" left join account_members m on m.account_id = a.id and m.user_id = $2"+
+			" where a.id = $1",
 		accountID,
 		userID,
-	).Scan(&existFlag); err != nil {
-		return false, err
+		model.OwnerRole,
+	).Scan(&role); err != nil {
+		if err == sql.ErrNoRows {
+			return "", store.ErrRecordNotFound
+		}
+		return "", err
+	}
+	return role, nil
+}
+
+func (r *AccountRepository) AddMember(m *model.AccountMember) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	a, err := r.Find(m.AccountID)
+	if err != nil {
+		return err
+	}
+	if a.User == m.UserID {
+		return store.ErrAccountOwner
 	}
-	return existFlag == 1, nil
+	tx, err := r.store.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		"insert into account_members(account_id, user_id, role) values($1, $2, $3)"+
+			" on conflict (account_id, user_id) do update set role = excluded.role",
+		m.AccountID,
+		m.UserID,
+		m.Role,
+	); err != nil {
+		return err
+	}
+	if err := r.store.record(tx, model.AuditEntityAccount, m.AccountID, model.AuditAddMember, nil, m); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *AccountRepository) RemoveMember(accountID, userID int) error {
+	tx, err := r.store.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before := &model.AccountMember{}
+	if err := tx.QueryRow(
+		"select m.account_id, m.user_id, u.email, m.role"+
+			" from account_members m"+
+			" join users u on u.id = m.user_id"+
+			" where m.account_id = $1 and m.user_id = $2"+
+			" for update of m",
+		accountID,
+		userID,
+	).Scan(
+		&before.AccountID,
+		&before.UserID,
+		&before.Email,
+		&before.Role,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return store.ErrRecordNotFound
+		}
+		return err
+	}
+	if _, err := tx.Exec(
+		"delete from account_members where account_id = $1 and user_id = $2",
+		accountID,
+		userID,
+	); err != nil {
+		return err
+	}
+	if err := r.store.record(tx, model.AuditEntityAccount, accountID, model.AuditRemoveMember, before, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *AccountRepository) GetMembers(accountID int) ([]*model.AccountMember, error) {
+	rows, err := r.store.db.Query(
+		"select m.account_id, m.user_id, u.email, m.role"+
+			" from account_members m"+
+			" join users u on u.id = m.user_id"+
+			" where m.account_id = $1",
+		accountID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]*model.AccountMember, 0)
+	for rows.Next() {
+		m := &model.AccountMember{}
+		if err := rows.Scan(
+			&m.AccountID,
+			&m.UserID,
+			&m.Email,
+			&m.Role,
+		); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *AccountRepository) GetUsers(accountIDs []int) (map[int][]int, error) {
+	rows, err := r.store.db.Query(
+		"select id, user_id from accounts where id = any($1)"+
+			" union"+
+			" select account_id, user_id from account_members where account_id = any($1)",
+		pq.Array(accountIDs),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make(map[int][]int)
+	for rows.Next() {
+		var accountID, userID int
+		if err := rows.Scan(&accountID, &userID); err != nil {
+			return nil, err
+		}
+		res[accountID] = append(res[accountID], userID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
 }
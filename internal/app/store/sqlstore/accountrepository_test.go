@@ -1,9 +1,11 @@
 package sqlstore_test
 
 import (
+	"sync"
 	"testing"
 
 	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
 	"github.com/Aza-9798/costs-rest-api/internal/app/store/sqlstore"
 	"github.com/stretchr/testify/assert"
 )
@@ -38,3 +40,73 @@ func TestAccountRepository_Find(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, a1)
 }
+
+func TestAccountRepository_GetUsers(t *testing.T) {
+	db, teardown := sqlstore.TestDB(t, databaseURL)
+	defer teardown("users", "accounts", "account_members")
+	store := sqlstore.New(db)
+
+	owner := model.TestUser(t)
+	assert.NoError(t, store.User().Create(owner))
+	member := model.TestUser(t)
+	member.Email = "member@example.org"
+	assert.NoError(t, store.User().Create(member))
+
+	shared := model.TestAccount(t, owner)
+	assert.NoError(t, store.Account().Create(shared))
+	own := model.TestAccount(t, owner)
+	assert.NoError(t, store.Account().Create(own))
+	assert.NoError(t, store.Account().AddMember(&model.AccountMember{
+		AccountID: shared.ID,
+		UserID:    member.ID,
+		Role:      model.ViewerRole,
+	}))
+
+	users, err := store.Account().GetUsers([]int{shared.ID, own.ID})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []int{owner.ID, member.ID}, users[shared.ID])
+	assert.Equal(t, []int{owner.ID}, users[own.ID])
+}
+
+func TestAccountRepository_SaveParentCycle(t *testing.T) {
+	db, teardown := sqlstore.TestDB(t, databaseURL)
+	defer teardown("users", "accounts")
+	s := sqlstore.New(db)
+
+	u := model.TestUser(t)
+	assert.NoError(t, s.User().Create(u))
+	category := func() *model.Account {
+		a := model.TestAccount(t, u)
+		a.Type = model.ExpenseCatogoryAccount
+		a.Balance = 0
+		assert.NoError(t, s.Account().Create(a))
+		return a
+	}
+	food, cafe := category(), category()
+
+	//встречные переносы: проходит только один из них
+	move := func(child, parent *model.Account) error {
+		a, err := s.Account().Find(child.ID)
+		assert.NoError(t, err)
+		a.Parent = &parent.ID
+		return s.Account().Save(a)
+	}
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		errs[0] = move(cafe, food)
+	}()
+	go func() {
+		defer wg.Done()
+		errs[1] = move(food, cafe)
+	}()
+	wg.Wait()
+	assert.ElementsMatch(t, []error{nil, store.ErrCategoryCycle}, errs)
+
+	//после этого в дереве по-прежнему нет цикла
+	descendants, err := s.Account().GetDescendants(food.ID)
+	assert.NoError(t, err)
+	assert.NotContains(t, descendants, food.ID)
+}
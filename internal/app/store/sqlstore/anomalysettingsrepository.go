@@ -0,0 +1,55 @@
+package sqlstore
+
+import (
+	"database/sql"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+)
+
+type AnomalySettingsRepository struct {
+	store *Store
+}
+
+func (r *AnomalySettingsRepository) Find(userID int) (*model.AnomalySettings, error) {
+	s := &model.AnomalySettings{User: userID}
+	err := r.store.db.QueryRow(
+		"select lookback_periods, category_threshold, min_change_percent, transaction_threshold"+
+			" from anomaly_settings"+
+			" where user_id = $1",
+		userID,
+	).Scan(
+		&s.LookbackPeriods,
+		&s.CategoryThreshold,
+		&s.MinChangePercent,
+		&s.TransactionThreshold,
+	)
+	if err == sql.ErrNoRows {
+		return model.DefaultAnomalySettings(userID), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (r *AnomalySettingsRepository) Save(s *model.AnomalySettings) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	_, err := r.store.db.Exec(
+		"insert into anomaly_settings"+
+			" (user_id, lookback_periods, category_threshold, min_change_percent, transaction_threshold)"+
+			" values ($1, $2, $3, $4, $5)"+
+			" on conflict (user_id) do update set"+
+			" lookback_periods = excluded.lookback_periods,"+
+			" category_threshold = excluded.category_threshold,"+
+			" min_change_percent = excluded.min_change_percent,"+
+			" transaction_threshold = excluded.transaction_threshold",
+		s.User,
+		s.LookbackPeriods,
+		s.CategoryThreshold,
+		s.MinChangePercent,
+		s.TransactionThreshold,
+	)
+	return err
+}
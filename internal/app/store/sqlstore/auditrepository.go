@@ -0,0 +1,104 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+)
+
+// auditActor is who makes the changes through a store returned by WithActor.
+type auditActor struct {
+	id        int
+	requestID string
+}
+
+type AuditRepository struct {
+	store *Store
+}
+
+func (r *AuditRepository) Create(e *model.AuditEntry) error {
+	return insertAuditEntry(r.store.db, e)
+}
+
+func insertAuditEntry(q queryRower, e *model.AuditEntry) error {
+	return q.QueryRow(
+		"insert into audit_log(actor_id, request_id, entity, entity_id, operation, before, after)"+
+			" values($1, $2, $3, $4, $5, $6, $7) returning id, created_at",
+		actorValue(e.Actor),
+		e.RequestID,
+		e.Entity,
+		e.EntityID,
+		e.Operation,
+		jsonValue(e.Before),
+		jsonValue(e.After),
+	).Scan(&e.ID, &e.CreatedAt)
+}
+
+func (r *AuditRepository) GetByEntity(entity string, entityID int) ([]*model.AuditEntry, error) {
+	rows, err := r.store.db.Query(
+		"select id, created_at, actor_id, request_id, entity, entity_id, operation, before, after"+
+			" from audit_log"+
+			" where entity = $1 and entity_id = $2"+
+			" order by id",
+		entity,
+		entityID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]*model.AuditEntry, 0)
+	for rows.Next() {
+		e := &model.AuditEntry{}
+		var (
+			actor         sql.NullInt64
+			before, after []byte
+		)
+		if err := rows.Scan(
+			&e.ID,
+			&e.CreatedAt,
+			&actor,
+			&e.RequestID,
+			&e.Entity,
+			&e.EntityID,
+			&e.Operation,
+			&before,
+			&after,
+		); err != nil {
+			return nil, err
+		}
+		e.Actor = int(actor.Int64)
+		e.Before, e.After = before, after
+		res = append(res, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// record adds an audit entry within tx, so it is committed or rolled back
+// together with the change. Stores without an actor keep no log.
+func (s *Store) record(tx *sql.Tx, entity string, entityID int, operation string, before, after interface{}) error {
+	if s.actor == nil {
+		return nil
+	}
+	return insertAuditEntry(tx, model.NewAuditEntry(s.actor.id, s.actor.requestID, entity, entityID, operation, before, after))
+}
+
+// actorValue stores changes of model.SystemActor with no user.
+func actorValue(actor int) interface{} {
+	if actor == model.SystemActor {
+		return nil
+	}
+	return actor
+}
+
+// jsonValue passes an empty snapshot as NULL instead of an invalid jsonb literal.
+func jsonValue(m json.RawMessage) interface{} {
+	if len(m) == 0 {
+		return nil
+	}
+	return string(m)
+}
@@ -0,0 +1,41 @@
+package sqlstore
+
+// RebuildDailyTotals recomputes account_daily_totals from transactions.
+// The totals are kept up to date by TransactionRepository, so this is only
+// needed to recover after the transactions table was changed directly.
+// Writers are blocked until the rebuild is committed.
+func (s *Store) RebuildDailyTotals() (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("lock table transactions in share mode"); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("delete from account_daily_totals"); err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(
+		"insert into account_daily_totals" +
+			" (account_id, day, transaction_type, inflow, outflow, transactions)" +
+			" select account_id, day, transaction_type, sum(inflow), sum(outflow), count(*)" +
+			" from (" +
+			" select destination account_id, transaction_date day, type transaction_type, amount inflow, 0 outflow" +
+			" from transactions where deleted_at is null" +
+			" union all" +
+			" select source, transaction_date, type, 0, amount" +
+			" from transactions where deleted_at is null" +
+			" ) flows" +
+			" group by account_id, day, transaction_type",
+	)
+	if err != nil {
+		return 0, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(count), tx.Commit()
+}
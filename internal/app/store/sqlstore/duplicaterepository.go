@@ -0,0 +1,198 @@
+package sqlstore
+
+import (
+	"database/sql"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+)
+
+const duplicateColumns = "d.id, d.transaction_id, d.duplicate_of_id, d.score, d.status, d.created_at, d.resolved_at"
+
+type DuplicateRepository struct {
+	store *Store
+}
+
+// flagDuplicates records the earlier transactions t is likely a duplicate of.
+func flagDuplicates(tx *sql.Tx, t *model.TransactionJSON) error {
+	min, max := model.DuplicateAmountRange(t.Amount)
+	rows, err := tx.Query(
+		"select "+transactionColumns+" from transactions"+
+			" where source = $1 and destination = $2 and id <> $3 and deleted_at is null"+
+			" and transaction_date::date between $4::date - $5::int and $4::date + $5::int"+
+			" and amount between $6 and $7",
+		t.Source,
+		t.Destination,
+		t.ID,
+		t.TransactionDate,
+		model.DuplicateWindowDays,
+		min,
+		max,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	scores := make(map[int]float64)
+	for rows.Next() {
+		other, err := scanTransaction(rows)
+		if err != nil {
+			return err
+		}
+		if score, ok := model.DuplicateScore(other, t); ok {
+			scores[other.ID] = score
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for id, score := range scores {
+		if _, err := tx.Exec(
+			"insert into duplicate_candidates(transaction_id, duplicate_of_id, score) values($1, $2, $3)"+
+				" on conflict do nothing",
+			t.ID,
+			id,
+			score,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *DuplicateRepository) GetPending(userID int) ([]*model.DuplicateCandidate, error) {
+	rows, err := r.store.db.Query(
+		"select "+duplicateColumns+
+			" from duplicate_candidates d"+
+			" join transactions t on t.id = d.transaction_id"+
+			" join transactions o on o.id = d.duplicate_of_id"+
+			" where d.status = $2 and t.deleted_at is null and o.deleted_at is null"+
+			" and (t.source in ("+userAccountsQuery+") or t.destination in ("+userAccountsQuery+"))"+
+			" order by d.id",
+		userID,
+		model.DuplicatePending,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]*model.DuplicateCandidate, 0)
+	pairs := make([][2]int, 0)
+	for rows.Next() {
+		d, pair, err := scanDuplicate(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, d)
+		pairs = append(pairs, pair)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	for i, d := range res {
+		if err := r.loadTransactions(d, pairs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// Find returns the pair with both transactions, including deleted ones.
+func (r *DuplicateRepository) Find(id int) (*model.DuplicateCandidate, error) {
+	d, pair, err := scanDuplicate(r.store.db.QueryRow(
+		"select "+duplicateColumns+" from duplicate_candidates d where d.id = $1",
+		id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, store.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := r.loadTransactions(d, pair); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (r *DuplicateRepository) Resolve(id int, status string) error {
+	return resolveDuplicate(r.store.db, id, status)
+}
+
+func (r *DuplicateRepository) Merge(id, drop int) error {
+	tx, err := r.store.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	//пара закрывается первой: параллельное слияние или отказ получат ErrDuplicateResolved
+	if err := resolveDuplicate(tx, id, model.DuplicateMerged); err != nil {
+		return err
+	}
+	var pair [2]int
+	if err := tx.QueryRow(
+		"select transaction_id, duplicate_of_id from duplicate_candidates where id = $1",
+		id,
+	).Scan(&pair[0], &pair[1]); err != nil {
+		return err
+	}
+	if drop != pair[0] && drop != pair[1] {
+		return store.ErrRecordNotFound
+	}
+	transactions := &TransactionRepository{store: r.store}
+	if err := transactions.deleteTx(tx, drop); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// resolveDuplicate changes the status of a pending pair.
+func resolveDuplicate(q execer, id int, status string) error {
+	res, err := q.Exec(
+		"update duplicate_candidates set status = $2, resolved_at = now() where id = $1 and status = $3",
+		id,
+		status,
+		model.DuplicatePending,
+	)
+	if err != nil {
+		return err
+	}
+	if count, err := res.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return store.ErrDuplicateResolved
+	}
+	return nil
+}
+
+func scanDuplicate(row scanner) (*model.DuplicateCandidate, [2]int, error) {
+	d := &model.DuplicateCandidate{}
+	var pair [2]int
+	if err := row.Scan(
+		&d.ID,
+		&pair[0],
+		&pair[1],
+		&d.Score,
+		&d.Status,
+		&d.CreatedAt,
+		&d.ResolvedAt,
+	); err != nil {
+		return nil, pair, err
+	}
+	return d, pair, nil
+}
+
+func (r *DuplicateRepository) loadTransactions(d *model.DuplicateCandidate, pair [2]int) error {
+	transactions := &TransactionRepository{store: r.store}
+	query := "select " + transactionColumns + " from transactions where id = $1"
+	var err error
+	if d.Transaction, err = transactions.findOne(r.store.db, query, pair[0]); err != nil {
+		return err
+	}
+	d.DuplicateOf, err = transactions.findOne(r.store.db, query, pair[1])
+	return err
+}
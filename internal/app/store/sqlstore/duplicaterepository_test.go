@@ -0,0 +1,77 @@
+package sqlstore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store/sqlstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuplicateRepository_Merge(t *testing.T) {
+	db, teardown := sqlstore.TestDB(t, databaseURL)
+	defer teardown("users", "accounts", "transactions", "account_daily_totals", "duplicate_candidates")
+	s := sqlstore.New(db)
+	u, card, _, food := testReportAccounts(t, s)
+
+	date := time.Date(2023, time.June, 14, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, s.Transaction().Create(&model.TransactionDB{
+			TransactionDate: date,
+			Source:          card,
+			Destination:     food,
+			Amount:          5,
+			Type:            model.ExpenseTransaction,
+			Description:     "Coffee",
+		}))
+	}
+	pending, err := s.Duplicate().GetPending(u.ID)
+	assert.NoError(t, err)
+	if !assert.Len(t, pending, 1) {
+		return
+	}
+	d := pending[0]
+	expense := func() float64 {
+		t.Helper()
+		res, err := s.Transaction().GetSummary(u.ID, date, date)
+		assert.NoError(t, err)
+		return res.Expense
+	}
+	balance := func() float64 {
+		t.Helper()
+		a, err := s.Account().Find(card.ID)
+		assert.NoError(t, err)
+		return a.Balance
+	}
+
+	//перевод не из пары: пара остается открытой, ничего не удалено
+	assert.Equal(t, store.ErrRecordNotFound, s.Duplicate().Merge(d.ID, d.Transaction.ID+d.DuplicateOf.ID))
+	pending, err = s.Duplicate().GetPending(u.ID)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, 990.0, balance())
+
+	assert.NoError(t, s.Duplicate().Merge(d.ID, d.Transaction.ID))
+	_, err = s.Transaction().FindDeleted(d.Transaction.ID)
+	assert.NoError(t, err)
+	_, err = s.Transaction().Find(d.DuplicateOf.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 995.0, balance())
+	assert.Equal(t, 5.0, expense())
+
+	//повторное слияние ничего не откатывает второй раз
+	assert.Equal(t, store.ErrDuplicateResolved, s.Duplicate().Merge(d.ID, d.DuplicateOf.ID))
+	_, err = s.Transaction().Find(d.DuplicateOf.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 995.0, balance())
+	assert.Equal(t, 5.0, expense())
+	_, err = s.RebuildDailyTotals()
+	assert.NoError(t, err)
+	assert.Equal(t, 5.0, expense())
+
+	d, err = s.Duplicate().Find(d.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.DuplicateMerged, d.Status)
+}
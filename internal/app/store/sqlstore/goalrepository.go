@@ -0,0 +1,115 @@
+package sqlstore
+
+import (
+	"database/sql"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+)
+
+const goalColumns = "id, account_id, name, target_amount, target_date, monthly_contribution, created_at"
+
+type GoalRepository struct {
+	store *Store
+}
+
+func scanGoal(row scanner) (*model.Goal, error) {
+	g := &model.Goal{}
+	if err := row.Scan(
+		&g.ID,
+		&g.Account,
+		&g.Name,
+		&g.TargetAmount,
+		&g.TargetDate,
+		&g.MonthlyContribution,
+		&g.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (r *GoalRepository) Create(g *model.Goal) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	return r.store.db.QueryRow(
+		"insert into goals(account_id, name, target_amount, target_date, monthly_contribution)"+
+			" values($1, $2, $3, $4, $5) returning id, created_at",
+		g.Account,
+		g.Name,
+		g.TargetAmount,
+		g.TargetDate,
+		g.MonthlyContribution,
+	).Scan(&g.ID, &g.CreatedAt)
+}
+
+func (r *GoalRepository) Save(g *model.Goal) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	res, err := r.store.db.Exec(
+		"update goals set name = $2, target_amount = $3, target_date = $4, monthly_contribution = $5"+
+			" where id = $1",
+		g.ID,
+		g.Name,
+		g.TargetAmount,
+		g.TargetDate,
+		g.MonthlyContribution,
+	)
+	if err != nil {
+		return err
+	}
+	if count, err := res.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return store.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *GoalRepository) Delete(id int) error {
+	res, err := r.store.db.Exec("delete from goals where id = $1", id)
+	if err != nil {
+		return err
+	}
+	if count, err := res.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return store.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *GoalRepository) Find(id int) (*model.Goal, error) {
+	g, err := scanGoal(r.store.db.QueryRow("select "+goalColumns+" from goals where id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, store.ErrRecordNotFound
+	}
+	return g, err
+}
+
+func (r *GoalRepository) GetAllByUser(userID int) ([]*model.Goal, error) {
+	rows, err := r.store.db.Query(
+		"select "+goalColumns+" from goals"+
+			" where account_id in ("+userAccountsQuery+")"+
+			" order by target_date, id",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]*model.Goal, 0)
+	for rows.Next() {
+		g, err := scanGoal(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,108 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+)
+
+type IdempotencyKeyRepository struct {
+	store *Store
+}
+
+// Create reserves the key before the request is processed.
+// It fails with ErrIdempotencyKeyUsed if the user already sent a request with this key.
+func (r *IdempotencyKeyRepository) Create(k *model.IdempotencyKey) error {
+	err := r.store.db.QueryRow(
+		"insert into idempotency_keys(user_id, key, request_hash)"+
+			" values($1, $2, $3)"+
+			" on conflict do nothing"+
+			" returning created_at",
+		k.UserID,
+		k.Key,
+		k.RequestHash,
+	).Scan(&k.CreatedAt)
+	if err == sql.ErrNoRows {
+		return store.ErrIdempotencyKeyUsed
+	}
+	return err
+}
+
+// Save stores the response of the request made with the key.
+func (r *IdempotencyKeyRepository) Save(k *model.IdempotencyKey) error {
+	header, err := json.Marshal(k.Header)
+	if err != nil {
+		return err
+	}
+	res, err := r.store.db.Exec(
+		"update idempotency_keys set status_code = $1, header = $2, body = $3"+
+			" where user_id = $4 and key = $5",
+		k.StatusCode,
+		string(header),
+		k.Body,
+		k.UserID,
+		k.Key,
+	)
+	if err != nil {
+		return err
+	}
+	if count, err := res.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return store.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *IdempotencyKeyRepository) Delete(userID int, key string) error {
+	_, err := r.store.db.Exec(
+		"delete from idempotency_keys where user_id = $1 and key = $2",
+		userID,
+		key,
+	)
+	return err
+}
+
+func (r *IdempotencyKeyRepository) Find(userID int, key string) (*model.IdempotencyKey, error) {
+	k := &model.IdempotencyKey{}
+	var header []byte
+	if err := r.store.db.QueryRow(
+		"select user_id, key, request_hash, status_code, header, body, created_at"+
+			" from idempotency_keys"+
+			" where user_id = $1 and key = $2",
+		userID,
+		key,
+	).Scan(
+		&k.UserID,
+		&k.Key,
+		&k.RequestHash,
+		&k.StatusCode,
+		&header,
+		&k.Body,
+		&k.CreatedAt,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, store.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	if len(header) > 0 {
+		if err := json.Unmarshal(header, &k.Header); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// Purge removes keys created before the given time.
+func (r *IdempotencyKeyRepository) Purge(before time.Time) (int, error) {
+	res, err := r.store.db.Exec("delete from idempotency_keys where created_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	count, err := res.RowsAffected()
+	return int(count), err
+}
@@ -0,0 +1,137 @@
+package sqlstore
+
+import (
+	"database/sql"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+	"github.com/lib/pq"
+)
+
+const ruleColumns = "id, user_id, name, priority, description_contains, description_regexp," +
+	" amount_min, amount_max, source_id, destination_id, transaction_type, tags, created_at"
+
+type RuleRepository struct {
+	store *Store
+}
+
+func scanRule(row scanner) (*model.Rule, error) {
+	r := &model.Rule{}
+	if err := row.Scan(
+		&r.ID,
+		&r.User,
+		&r.Name,
+		&r.Priority,
+		&r.DescriptionContains,
+		&r.DescriptionRegexp,
+		&r.AmountMin,
+		&r.AmountMax,
+		&r.Source,
+		&r.Destination,
+		&r.Type,
+		pq.Array(&r.Tags),
+		&r.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RuleRepository) Create(rule *model.Rule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	rule.Tags = model.NormalizeTags(rule.Tags)
+	return r.store.db.QueryRow(
+		"insert into rules(user_id, name, priority, description_contains, description_regexp,"+
+			" amount_min, amount_max, source_id, destination_id, transaction_type, tags)"+
+			" values($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) returning id, created_at",
+		rule.User,
+		rule.Name,
+		rule.Priority,
+		rule.DescriptionContains,
+		rule.DescriptionRegexp,
+		rule.AmountMin,
+		rule.AmountMax,
+		rule.Source,
+		rule.Destination,
+		rule.Type,
+		pq.Array(rule.Tags),
+	).Scan(&rule.ID, &rule.CreatedAt)
+}
+
+func (r *RuleRepository) Save(rule *model.Rule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	rule.Tags = model.NormalizeTags(rule.Tags)
+	res, err := r.store.db.Exec(
+		"update rules set name = $2, priority = $3, description_contains = $4, description_regexp = $5,"+
+			" amount_min = $6, amount_max = $7, source_id = $8, destination_id = $9, transaction_type = $10, tags = $11"+
+			" where id = $1",
+		rule.ID,
+		rule.Name,
+		rule.Priority,
+		rule.DescriptionContains,
+		rule.DescriptionRegexp,
+		rule.AmountMin,
+		rule.AmountMax,
+		rule.Source,
+		rule.Destination,
+		rule.Type,
+		pq.Array(rule.Tags),
+	)
+	if err != nil {
+		return err
+	}
+	if count, err := res.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return store.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *RuleRepository) Delete(id int) error {
+	res, err := r.store.db.Exec("delete from rules where id = $1", id)
+	if err != nil {
+		return err
+	}
+	if count, err := res.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return store.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *RuleRepository) Find(id int) (*model.Rule, error) {
+	rule, err := scanRule(r.store.db.QueryRow("select "+ruleColumns+" from rules where id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, store.ErrRecordNotFound
+	}
+	return rule, err
+}
+
+func (r *RuleRepository) GetAllByUser(userID int) ([]*model.Rule, error) {
+	rows, err := r.store.db.Query(
+		"select "+ruleColumns+" from rules where user_id = $1 order by priority, id",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]*model.Rule, 0)
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -8,10 +8,18 @@ import (
 )
 
 type Store struct {
-	db                    *sql.DB
-	userRepository        *UserRepository
-	accountRepository     *AccountRepository
-	transactionRepository *TransactionRepository
+	db                        *sql.DB
+	actor                     *auditActor
+	userRepository            *UserRepository
+	accountRepository         *AccountRepository
+	transactionRepository     *TransactionRepository
+	auditRepository           *AuditRepository
+	idempotencyRepository     *IdempotencyKeyRepository
+	webhookRepository         *WebhookRepository
+	anomalySettingsRepository *AnomalySettingsRepository
+	goalRepository            *GoalRepository
+	ruleRepository            *RuleRepository
+	duplicateRepository       *DuplicateRepository
 }
 
 func New(db *sql.DB) *Store {
@@ -20,6 +28,18 @@ func New(db *sql.DB) *Store {
 	}
 }
 
+// WithActor returns a store over the same database whose account and
+// transaction changes are written to the audit log on behalf of actor.
+func (s *Store) WithActor(actor int, requestID string) store.Store {
+	return &Store{
+		db: s.db,
+		actor: &auditActor{
+			id:        actor,
+			requestID: requestID,
+		},
+	}
+}
+
 func (s *Store) User() store.UserRepo {
 	if s.userRepository == nil {
 		s.userRepository = &UserRepository{
@@ -46,3 +66,66 @@ func (s *Store) Transaction() store.TransactionRepo {
 	}
 	return s.transactionRepository
 }
+
+func (s *Store) Audit() store.AuditRepo {
+	if s.auditRepository == nil {
+		s.auditRepository = &AuditRepository{
+			store: s,
+		}
+	}
+	return s.auditRepository
+}
+
+func (s *Store) IdempotencyKey() store.IdempotencyKeyRepo {
+	if s.idempotencyRepository == nil {
+		s.idempotencyRepository = &IdempotencyKeyRepository{
+			store: s,
+		}
+	}
+	return s.idempotencyRepository
+}
+
+func (s *Store) Webhook() store.WebhookRepo {
+	if s.webhookRepository == nil {
+		s.webhookRepository = &WebhookRepository{
+			store: s,
+		}
+	}
+	return s.webhookRepository
+}
+
+func (s *Store) AnomalySettings() store.AnomalySettingsRepo {
+	if s.anomalySettingsRepository == nil {
+		s.anomalySettingsRepository = &AnomalySettingsRepository{
+			store: s,
+		}
+	}
+	return s.anomalySettingsRepository
+}
+
+func (s *Store) Goal() store.GoalRepo {
+	if s.goalRepository == nil {
+		s.goalRepository = &GoalRepository{
+			store: s,
+		}
+	}
+	return s.goalRepository
+}
+
+func (s *Store) Rule() store.RuleRepo {
+	if s.ruleRepository == nil {
+		s.ruleRepository = &RuleRepository{
+			store: s,
+		}
+	}
+	return s.ruleRepository
+}
+
+func (s *Store) Duplicate() store.DuplicateRepo {
+	if s.duplicateRepository == nil {
+		s.duplicateRepository = &DuplicateRepository{
+			store: s,
+		}
+	}
+	return s.duplicateRepository
+}
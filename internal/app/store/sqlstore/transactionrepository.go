@@ -4,222 +4,494 @@ import (
 	"database/sql"
 	"time"
 
+	"github.com/Aza-9798/costs-rest-api/internal/app/events"
 	"github.com/Aza-9798/costs-rest-api/internal/app/model"
 	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+	"github.com/lib/pq"
 )
 
+const transactionColumns = "id, creation_date, transaction_date, source, destination, amount, type, description, tags, deleted_at, version"
+
+// queryRower is implemented by both *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}
+
 type TransactionRepository struct {
 	store *Store
 }
 
 func (r *TransactionRepository) Create(t *model.TransactionDB) error {
-	if err := t.Validate(); err != nil {
-		return err
-	}
 	//Create DB transaction for this operation
 	tx, err := r.store.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	if t.Type == model.StandardTransaction || t.Type == model.ExpenseTransaction {
-		//Check account balance
-		var balance float64
-		if err := r.store.db.QueryRow(
-			"select balance from accounts where id = $1",
-			t.Source.ID,
-		).Scan(&balance); err != nil {
-			return err
+	if err := r.createTx(tx, t); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateBatch creates all transactions in one DB transaction. It returns an error
+// for every item that failed, nil for the ones created. In atomic mode nothing
+// is created if any item fails, otherwise the failed items are skipped.
+func (r *TransactionRepository) CreateBatch(ts []*model.TransactionDB, atomic bool) ([]error, error) {
+	tx, err := r.store.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	errs := make([]error, len(ts))
+	failed := false
+	for i, t := range ts {
+		if !atomic {
+			if _, err := tx.Exec("savepoint batch_item"); err != nil {
+				return nil, err
+			}
 		}
-		if balance < t.Amount {
-			return store.ErrInsufficientFunds
+		if errs[i] = r.createTx(tx, t); errs[i] == nil {
+			if !atomic {
+				if _, err := tx.Exec("release savepoint batch_item"); err != nil {
+					return nil, err
+				}
+			}
+			continue
 		}
-
-		if _, err := tx.Exec("update accounts "+
-			"set balance = balance - $1 "+
-			"where id = $2",
-			t.Amount,
-			t.Source.ID); err != nil {
-			tx.Rollback()
-			return err
+		failed = true
+		if atomic {
+			//после ошибки транзакция в postgres уже не пригодна
+			break
+		}
+		if _, err := tx.Exec("rollback to savepoint batch_item"); err != nil {
+			return nil, err
 		}
 	}
+	if atomic && failed {
+		return errs, nil
+	}
+	return errs, tx.Commit()
+}
 
-	if t.Type == model.IncomeTransaction || t.Type == model.StandardTransaction {
-		if _, err := tx.Exec("update accounts "+
-			"set balance = balance + $1 "+
-			"where id = $2",
-			t.Amount,
-			t.Destination.ID); err != nil {
-			tx.Rollback()
-			return err
-		}
+func (r *TransactionRepository) createTx(tx *sql.Tx, t *model.TransactionDB) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	if t.Source.IsArchived() || t.Destination.IsArchived() {
+		return store.ErrAccountArchived
 	}
+	if err := checkFunds(tx, t.ToJSON()); err != nil {
+		return err
+	}
+	if err := r.insertTx(tx, t); err != nil {
+		return err
+	}
+	return flagDuplicates(tx, t.ToJSON())
+}
 
-	if err := tx.QueryRow("insert into transactions(transaction_date, source, destination, amount, description, type) "+
-		"values($1, $2, $3, $4, $5, $6)"+
-		"returning id, creation_date",
+// insertTx stores a validated transaction and applies it to the balances.
+func (r *TransactionRepository) insertTx(tx *sql.Tx, t *model.TransactionDB) error {
+	if err := applyBalance(tx, t.ToJSON(), 1); err != nil {
+		return err
+	}
+	if err := applyDailyTotals(tx, t.ToJSON(), 1); err != nil {
+		return err
+	}
+
+	t.Tags = model.NormalizeTags(t.Tags)
+	if err := tx.QueryRow("insert into transactions(transaction_date, source, destination, amount, description, type, tags) "+
+		"values($1, $2, $3, $4, $5, $6, $7)"+
+		"returning id, creation_date, version",
 		t.TransactionDate,
 		t.Source.ID,
 		t.Destination.ID,
 		t.Amount,
 		t.Description,
 		t.Type,
+		pq.Array(t.Tags),
 	).Scan(
 		&t.ID,
 		&t.CreationDate,
+		&t.Version,
 	); err != nil {
-		tx.Rollback()
 		return err
 	}
-
-	if err := tx.Commit(); err != nil {
-		tx.Rollback()
+	if err := r.store.record(tx, model.AuditEntityTransaction, t.ID, model.AuditCreate, nil, t.ToJSON()); err != nil {
 		return err
 	}
-	return nil
+	return enqueueWebhooks(tx, events.TransactionCreated, t.ToJSON(), t.Source.ID, t.Destination.ID)
 }
 
+// Delete moves the transaction to the trash and reverts its balance effects.
+// It can be brought back with Restore until the trash is purged.
 func (r *TransactionRepository) Delete(t *model.TransactionJSON) error {
 	tx, err := r.store.db.Begin()
 	if err != nil {
 		return err
 	}
-	res, err := tx.Exec("delete from transactions where id = $1", t.ID)
+	defer tx.Rollback()
+
+	if err := r.deleteTx(tx, t.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteTx moves the transaction to the trash within tx. The stored row is
+// locked and used for reverting balances, so a stale copy can't skew them.
+func (r *TransactionRepository) deleteTx(tx *sql.Tx, id int) error {
+	t, err := r.lock(tx, id, false)
 	if err != nil {
 		return err
 	}
-	if count, err := res.RowsAffected(); err != nil {
+	after := *t
+	if err := tx.QueryRow(
+		"update transactions set deleted_at = now(), version = version + 1 where id = $1"+
+			" returning deleted_at, version",
+		id,
+	).Scan(&after.DeletedAt, &after.Version); err != nil {
 		return err
-	} else if count == 0 {
-		return store.ErrRecordNotFound
 	}
-	if _, err := tx.Exec(
-		"update accounts set balance = balance + $1 where id = $2",
-		t.Amount,
-		t.Source,
-	); err != nil {
-		tx.Rollback()
+	if err := applyBalance(tx, t, -1); err != nil {
 		return err
 	}
+	if err := applyDailyTotals(tx, t, -1); err != nil {
+		return err
+	}
+	if err := r.store.record(tx, model.AuditEntityTransaction, id, model.AuditDelete, t, &after); err != nil {
+		return err
+	}
+	return enqueueWebhooks(tx, events.TransactionDeleted, t, t.Source, t.Destination)
+}
 
-	if _, err := tx.Exec(
-		"update accounts set balance = balance - $1 where id = $2",
-		t.Amount,
-		t.Destination,
-	); err != nil {
-		tx.Rollback()
+// Restore takes the transaction out of the trash and applies its balance effects again.
+func (r *TransactionRepository) Restore(id int) error {
+	tx, err := r.store.db.Begin()
+	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	if err := tx.Commit(); err != nil {
-		tx.Rollback()
+	t, err := r.lock(tx, id, true)
+	if err != nil {
+		return err
+	}
+	before := *t
+	if err := checkArchived(tx, t); err != nil {
+		return err
+	}
+	if err := checkFunds(tx, t); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("update transactions set deleted_at = null, version = version + 1 where id = $1", id); err != nil {
+		return err
+	}
+	if err := applyBalance(tx, t, 1); err != nil {
+		return err
+	}
+	if err := applyDailyTotals(tx, t, 1); err != nil {
+		return err
+	}
+	t.DeletedAt = nil
+	t.Version++
+	if err := r.store.record(tx, model.AuditEntityTransaction, id, model.AuditRestore, &before, t); err != nil {
+		return err
+	}
+	if err := enqueueWebhooks(tx, events.TransactionRestored, t, t.Source, t.Destination); err != nil {
 		return err
 	}
 
-	return nil
+	return tx.Commit()
+}
+
+// PurgeTrash permanently removes transactions deleted before the given time.
+// Their balance effects were already reverted by Delete.
+func (r *TransactionRepository) PurgeTrash(before time.Time) (int, error) {
+	res, err := r.store.db.Exec(
+		"delete from transactions where deleted_at is not null and deleted_at < $1",
+		before,
+	)
+	if err != nil {
+		return 0, err
+	}
+	count, err := res.RowsAffected()
+	return int(count), err
 }
 
+// Save updates the transaction if t.Version still matches the stored one
+// and moves balances from the old values to the new ones.
 func (r *TransactionRepository) Save(t *model.TransactionDB) error {
-	tInDB, err := r.Find(t.ID)
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	tx, err := r.store.db.Begin()
 	if err != nil {
 		return err
 	}
-	diff := t.Amount - tInDB.Amount
-	if t.Type == model.ExpenseTransaction || t.Type == model.StandardTransaction {
-		//изменить сумму у источника
-		if t.Source.ID != tInDB.Source {
-			t.Source.Balance -= t.Amount
-			oldAcc, err := r.store.Account().Find(tInDB.Source)
-			if err != nil {
-				return err
-			}
-			oldAcc.Balance += tInDB.Amount
-			r.store.Account().Save(oldAcc)
-		} else if diff != 0 {
-			t.Source.Balance -= diff
-		}
-		r.store.Account().Save(t.Source)
+	defer tx.Rollback()
+
+	tInDB, err := r.lock(tx, t.ID, false)
+	if err != nil {
+		return err
 	}
-	if t.Type == model.IncomeTransaction || t.Type == model.StandardTransaction {
-		//изменить сумму у получателя
-		if t.Destination.ID != tInDB.Destination {
-			t.Destination.Balance += t.Amount
-			oldAcc, err := r.store.Account().Find(tInDB.Destination)
-			if err != nil {
-				return err
-			}
-			oldAcc.Balance -= tInDB.Amount
-			r.store.Account().Save(oldAcc)
-		} else if diff != 0 {
-			t.Destination.Balance += diff
-		}
-		r.store.Account().Save(t.Destination)
+	if tInDB.Version != t.Version {
+		return store.ErrVersionConflict
+	}
+	//нельзя переносить операции ни со счетов в архиве, ни на них
+	if err := checkArchived(tx, tInDB); err != nil {
+		return err
+	}
+	if err := checkArchived(tx, t.ToJSON()); err != nil {
+		return err
 	}
-	if _, err := r.store.db.Exec(
+
+	//откатить старую сумму и применить новую
+	if err := applyBalance(tx, tInDB, -1); err != nil {
+		return err
+	}
+	if err := applyDailyTotals(tx, tInDB, -1); err != nil {
+		return err
+	}
+	if err := checkFunds(tx, t.ToJSON()); err != nil {
+		return err
+	}
+	if err := applyBalance(tx, t.ToJSON(), 1); err != nil {
+		return err
+	}
+	if err := applyDailyTotals(tx, t.ToJSON(), 1); err != nil {
+		return err
+	}
+	t.Tags = model.NormalizeTags(t.Tags)
+	if err := tx.QueryRow(
 		"update transactions"+
-			" set transaction_date = $1, source = $2, destination = $3, amount = $4, description = $5"+
-			" where id = $6",
+			" set transaction_date = $1::date, source = $2, destination = $3, amount = $4, description = $5, type = $6,"+
+			" tags = $7, version = version + 1"+
+			" where id = $8"+
+			" returning creation_date, version",
 		t.TransactionDate,
 		t.Source.ID,
 		t.Destination.ID,
 		t.Amount,
 		t.Description,
+		t.Type,
+		pq.Array(t.Tags),
 		t.ID,
+	).Scan(&t.CreationDate, &t.Version); err != nil {
+		return err
+	}
+	if err := r.store.record(tx, model.AuditEntityTransaction, t.ID, model.AuditUpdate, tInDB, t.ToJSON()); err != nil {
+		return err
+	}
+	if err := enqueueWebhooks(tx, events.TransactionUpdated, t.ToJSON(),
+		tInDB.Source,
+		tInDB.Destination,
+		t.Source.ID,
+		t.Destination.ID,
 	); err != nil {
 		return err
 	}
-	return nil
+	return tx.Commit()
 }
 
 func (r *TransactionRepository) Find(id int) (*model.TransactionJSON, error) {
-	t := &model.TransactionJSON{}
-	if err := r.store.db.QueryRow("select id, creation_date, transaction_date, source, destination, amount, description"+
-		" from transactions "+
-		" where id = $1",
+	return r.findOne(r.store.db, "select "+transactionColumns+
+		" from transactions"+
+		" where id = $1 and deleted_at is null",
 		id,
-	).Scan(&t.ID,
+	)
+}
+
+func (r *TransactionRepository) FindDeleted(id int) (*model.TransactionJSON, error) {
+	return r.findOne(r.store.db, "select "+transactionColumns+
+		" from transactions"+
+		" where id = $1 and deleted_at is not null",
+		id,
+	)
+}
+
+// lock reads the transaction within tx and locks it until the end of tx.
+// deleted selects whether it is looked up in the trash or among active ones.
+func (r *TransactionRepository) lock(tx *sql.Tx, id int, deleted bool) (*model.TransactionJSON, error) {
+	return r.findOne(tx, "select "+transactionColumns+
+		" from transactions"+
+		" where id = $1 and (deleted_at is not null) = $2"+
+		" for update",
+		id,
+		deleted,
+	)
+}
+
+func (r *TransactionRepository) findOne(q queryRower, query string, args ...interface{}) (*model.TransactionJSON, error) {
+	t, err := scanTransaction(q.QueryRow(query, args...))
+	if err == sql.ErrNoRows {
+		return nil, store.ErrRecordNotFound
+	}
+	return t, err
+}
+
+func (r *TransactionRepository) GetAllByAccount(accountID int) ([]*model.TransactionJSON, error) {
+	return r.findAll(
+		"select "+transactionColumns+
+			" from transactions"+
+			" where (source = $1 or destination = $1) and deleted_at is null",
+		accountID,
+	)
+}
+
+func (r *TransactionRepository) GetAllByAccountAndPeriod(accountID int, DateStart, DateEnd time.Time) ([]*model.TransactionJSON, error) {
+	return r.findAll(
+		"select "+transactionColumns+
+			" from transactions"+
+			" where (source = $1 or destination = $1)"+
+			" and transaction_date >= $2 and transaction_date <= $3"+
+			" and deleted_at is null",
+		accountID,
+		DateStart,
+		DateEnd,
+	)
+}
+
+func (r *TransactionRepository) GetAllByUser(userID int) ([]*model.TransactionJSON, error) {
+	return r.findAll(
+		"select "+transactionColumns+
+			" from transactions "+
+			" where (source in ("+userAccountsQuery+")"+
+			" or destination in ("+userAccountsQuery+"))"+
+			" and deleted_at is null",
+		userID,
+	)
+}
+
+func (r *TransactionRepository) GetAllByUserAndPeriod(userID int, DateStart, DateEnd time.Time) ([]*model.TransactionJSON, error) {
+	return r.findAll(
+		"select "+transactionColumns+
+			" from transactions "+
+			" where (source in ("+userAccountsQuery+")"+
+			" or destination in ("+userAccountsQuery+"))"+
+			" and transaction_date >= $2 and transaction_date <= $3"+
+			" and deleted_at is null",
+		userID,
+		DateStart,
+		DateEnd,
+	)
+}
+
+// GetTrash returns deleted transactions of the user's accounts, most recent first.
+func (r *TransactionRepository) GetTrash(userID int) ([]*model.TransactionJSON, error) {
+	return r.findAll(
+		"select "+transactionColumns+
+			" from transactions "+
+			" where (source in ("+userAccountsQuery+")"+
+			" or destination in ("+userAccountsQuery+"))"+
+			" and deleted_at is not null"+
+			" order by deleted_at desc",
+		userID,
+	)
+}
+
+func (r *TransactionRepository) findAll(query string, args ...interface{}) ([]*model.TransactionJSON, error) {
+	rows, err := r.store.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]*model.TransactionJSON, 0)
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func scanTransaction(row scanner) (*model.TransactionJSON, error) {
+	t := &model.TransactionJSON{}
+	if err := row.Scan(
+		&t.ID,
 		&t.CreationDate,
 		&t.TransactionDate,
 		&t.Source,
 		&t.Destination,
 		&t.Amount,
+		&t.Type,
 		&t.Description,
+		pq.Array(&t.Tags),
+		&t.DeletedAt,
+		&t.Version,
 	); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, store.ErrRecordNotFound
-		}
 		return nil, err
 	}
 	return t, nil
 }
 
-func (r *TransactionRepository) GetAllByAccount(accountID int) ([]*model.TransactionJSON, error) {
+// GetSummary reads account_daily_totals instead of scanning transactions.
+func (r *TransactionRepository) GetSummary(userID int, DateStart, DateEnd time.Time) (*model.Summary, error) {
+	res := &model.Summary{
+		DateStart: DateStart,
+		DateEnd:   DateEnd,
+	}
+	if err := r.store.db.QueryRow(
+		"select coalesce(sum(inflow) filter (where transaction_type = $2), 0),"+
+			" coalesce(sum(outflow) filter (where transaction_type = $3), 0)"+
+			" from account_daily_totals"+
+			" where account_id in ("+userAccountsQuery+")"+
+			" and day >= $4 and day <= $5",
+		userID,
+		model.IncomeTransaction,
+		model.ExpenseTransaction,
+		DateStart,
+		DateEnd,
+	).Scan(&res.Income, &res.Expense); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetCategoryTotals returns the amount that went through each income source
+// and expense category available to the user in the period, keyed by account id.
+func (r *TransactionRepository) GetCategoryTotals(userID int, DateStart, DateEnd time.Time) (map[int]float64, error) {
 	rows, err := r.store.db.Query(
-		"select id, creation_date, transaction_date, source, destination, amount, type, description"+
-			" from transactions"+
-			" where source = $1 or destination = $1", accountID)
+		"select d.account_id, sum(case when a.account_type = $2 then d.outflow else d.inflow end)"+
+			" from account_daily_totals d"+
+			" join accounts a on a.id = d.account_id"+
+			" where a.account_type in ($2, $3)"+
+			" and d.account_id in ("+userAccountsQuery+")"+
+			" and d.day >= $4 and d.day <= $5"+
+			" group by d.account_id"+
+			" having sum(d.transactions) > 0",
+		userID,
+		model.IncomeSourceAccount,
+		model.ExpenseCatogoryAccount,
+		DateStart,
+		DateEnd,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	res := make([]*model.TransactionJSON, 0)
+	res := make(map[int]float64)
 	for rows.Next() {
-		t := &model.TransactionJSON{}
-		err := rows.Scan(
-			&t.ID,
-			&t.CreationDate,
-			&t.TransactionDate,
-			&t.Source,
-			&t.Destination,
-			&t.Amount,
-			&t.Type,
-			&t.Description,
+		var (
+			id    int
+			total float64
 		)
-		if err != nil {
+		if err := rows.Scan(&id, &total); err != nil {
 			return nil, err
 		}
-		res = append(res, t)
+		res[id] = total
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
@@ -227,37 +499,72 @@ func (r *TransactionRepository) GetAllByAccount(accountID int) ([]*model.Transac
 	return res, nil
 }
 
-func (r *TransactionRepository) GetAllByAccountAndPeriod(accountID int, DateStart, DateEnd time.Time) ([]*model.TransactionJSON, error) {
+// GetAccountFlows returns how much the accounts received and sent in the period.
+func (r *TransactionRepository) GetAccountFlows(accountIDs []int, DateStart, DateEnd time.Time) (*model.AccountFlows, error) {
+	res := &model.AccountFlows{}
+	if err := r.store.db.QueryRow(
+		"select coalesce(sum(inflow), 0), coalesce(sum(outflow), 0)"+
+			" from account_daily_totals"+
+			" where account_id = any($1)"+
+			" and day >= $2 and day <= $3",
+		pq.Array(accountIDs),
+		DateStart,
+		DateEnd,
+	).Scan(&res.Inflow, &res.Outflow); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetTimeSeries buckets account_daily_totals with date_trunc, so weeks start on Monday.
+// Income is counted on the receiving account and expense on the paying one,
+// the same way as in GetSummary. Grouped by category, the totals of the
+// categories themselves are used, as in GetCategoryTotals.
+func (r *TransactionRepository) GetTimeSeries(userID int, DateStart, DateEnd time.Time, interval, groupBy string) ([]*model.TimeSeriesRow, error) {
+	accounts := "a.account_type not in ($7, $8)"
+	income, expense := "d.inflow", "d.outflow"
+	group := "0"
+	switch groupBy {
+	case model.GroupByAccount:
+		group = "d.account_id"
+	case model.GroupByCategory:
+		accounts = "a.account_type in ($7, $8)"
+		income, expense = "d.outflow", "d.inflow"
+		group = "d.account_id"
+	}
 	rows, err := r.store.db.Query(
-		"select id, creation_date, transaction_date, source, destination, amount, type, description"+
-			" from transactions"+
-			" where source = $1 or destination = $1"+
-			" and transaction_date >= $2 and transaction_date <= $3",
-		accountID,
+		"select date_trunc($2::text, d.day::timestamp)::date bucket, "+group+" grp,"+
+			" coalesce(sum("+income+") filter (where d.transaction_type = $3), 0),"+
+			" coalesce(sum("+expense+") filter (where d.transaction_type = $4), 0)"+
+			" from account_daily_totals d"+
+			" join accounts a on a.id = d.account_id"+
+			" where "+accounts+
+			" and d.transaction_type in ($3, $4)"+
+			" and d.account_id in ("+userAccountsQuery+")"+
+			" and d.day >= $5 and d.day <= $6"+
+			" group by bucket, grp"+
+			" having sum(d.transactions) > 0"+
+			" order by bucket, grp",
+		userID,
+		interval,
+		model.IncomeTransaction,
+		model.ExpenseTransaction,
 		DateStart,
 		DateEnd,
+		model.IncomeSourceAccount,
+		model.ExpenseCatogoryAccount,
 	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	res := make([]*model.TransactionJSON, 0)
+	res := make([]*model.TimeSeriesRow, 0)
 	for rows.Next() {
-		t := &model.TransactionJSON{}
-		err := rows.Scan(
-			&t.ID,
-			&t.CreationDate,
-			&t.TransactionDate,
-			&t.Source,
-			&t.Destination,
-			&t.Amount,
-			&t.Type,
-			&t.Description,
-		)
-		if err != nil {
+		row := &model.TimeSeriesRow{}
+		if err := rows.Scan(&row.Bucket, &row.Group, &row.Income, &row.Expense); err != nil {
 			return nil, err
 		}
-		res = append(res, t)
+		res = append(res, row)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
@@ -265,33 +572,38 @@ func (r *TransactionRepository) GetAllByAccountAndPeriod(accountID int, DateStar
 	return res, nil
 }
 
-func (r *TransactionRepository) GetAllByUser(userID int) ([]*model.TransactionJSON, error) {
+// GetBalanceChanges mirrors applyBalance: the destination of standard and income
+// transactions gains the amount, the source of standard and expense ones loses it.
+// Standard accounts take no other part in transactions, so for them this is
+// the inflow less the outflow in account_daily_totals.
+func (r *TransactionRepository) GetBalanceChanges(userID int, since time.Time, interval string) ([]*model.BalanceChange, error) {
 	rows, err := r.store.db.Query(
-		"select id, creation_date, transaction_date, source, destination, amount, type, description"+
-			" from transactions "+
-			" where source in (select id from accounts where user_id = $1)"+
-			" or destination in (select id from accounts where user_id = $1)",
-		userID)
+		"select d.account_id, date_trunc($2::text, d.day::timestamp)::date bucket,"+
+			" sum(d.inflow - d.outflow)"+
+			" from account_daily_totals d"+
+			" join accounts a on a.id = d.account_id"+
+			" where a.account_type not in ($3, $4)"+
+			" and d.account_id in ("+userAccountsQuery+")"+
+			" and d.day >= $5"+
+			" group by d.account_id, bucket"+
+			" having sum(d.transactions) > 0",
+		userID,
+		interval,
+		model.IncomeSourceAccount,
+		model.ExpenseCatogoryAccount,
+		since,
+	)
 	if err != nil {
 		return nil, err
 	}
-	res := make([]*model.TransactionJSON, 0)
+	defer rows.Close()
+	res := make([]*model.BalanceChange, 0)
 	for rows.Next() {
-		t := &model.TransactionJSON{}
-		err := rows.Scan(
-			&t.ID,
-			&t.CreationDate,
-			&t.TransactionDate,
-			&t.Source,
-			&t.Destination,
-			&t.Amount,
-			&t.Type,
-			&t.Description,
-		)
-		if err != nil {
+		c := &model.BalanceChange{}
+		if err := rows.Scan(&c.Account, &c.Bucket, &c.Amount); err != nil {
 			return nil, err
 		}
-		res = append(res, t)
+		res = append(res, c)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
@@ -299,34 +611,236 @@ func (r *TransactionRepository) GetAllByUser(userID int) ([]*model.TransactionJS
 	return res, nil
 }
 
-func (r *TransactionRepository) GetSummary(userID int, DateStart, DateEnd time.Time) (*model.Summary, error) {
-	res := &model.Summary{
-		DateStart: DateStart,
-		DateEnd:   DateEnd,
-	}
-	if err := r.store.db.QueryRow("select coalesce(sum(amount), 0) income"+
-		" from transactions t, accounts a"+
-		" where t.destination = a.id and a.user_id = $1"+
-		" and type=$2"+
-		" and t.transaction_date >= $3 and t.transaction_date <= $4",
+// GetCategoryComparison is GetCategoryTotals for two adjacent periods at once.
+func (r *TransactionRepository) GetCategoryComparison(userID int, DateStart, DateEnd, prevStart time.Time) ([]*model.CategoryComparison, error) {
+	rows, err := r.store.db.Query(
+		"select d.account_id,"+
+			" coalesce(sum(case when a.account_type = $2 then d.outflow else d.inflow end) filter (where d.day >= $4), 0),"+
+			" coalesce(sum(case when a.account_type = $2 then d.outflow else d.inflow end) filter (where d.day < $4), 0)"+
+			" from account_daily_totals d"+
+			" join accounts a on a.id = d.account_id"+
+			" where a.account_type in ($2, $3)"+
+			" and d.account_id in ("+userAccountsQuery+")"+
+			" and d.day >= $6 and d.day <= $5"+
+			" group by d.account_id"+
+			" having sum(d.transactions) > 0",
 		userID,
-		model.IncomeTransaction,
+		model.IncomeSourceAccount,
+		model.ExpenseCatogoryAccount,
 		DateStart,
 		DateEnd,
-	).Scan(&res.Income); err != nil {
+		prevStart,
+	)
+	if err != nil {
 		return nil, err
 	}
-	if err := r.store.db.QueryRow("select coalesce(sum(amount), 0) expense"+
-		" from transactions t, accounts a"+
-		" where t.source = a.id and a.user_id = $1"+
-		" and type=$2"+
-		" and t.transaction_date >= $3 and t.transaction_date <= $4",
-		userID,
-		model.ExpenseTransaction,
-		DateStart,
-		DateEnd,
-	).Scan(&res.Expense); err != nil {
+	defer rows.Close()
+	res := make([]*model.CategoryComparison, 0)
+	for rows.Next() {
+		c := &model.CategoryComparison{}
+		if err := rows.Scan(&c.Account, &c.Current, &c.Previous); err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 	return res, nil
 }
+
+// AccrueInterest charges a Debt account interest for every month that ended
+// after it was last charged and not later than through. Each month becomes
+// an expense to the owner's Interest category, created when missing.
+// A month is charged on the balance at its end, which includes the interest
+// of the months before. Interest is charged even if it takes the debt over
+// the credit limit.
+func (r *TransactionRepository) AccrueInterest(accountID int, through time.Time) ([]*model.TransactionDB, error) {
+	tx, err := r.store.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	a, err := scanAccount(tx.QueryRow(
+		"select "+accountColumns+" from accounts where id = $1 for update",
+		accountID,
+	))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, store.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	res := make([]*model.TransactionDB, 0)
+	var category *model.Account
+	month := model.MonthEnd(a.InterestAccruedThrough.AddDate(0, 0, 1))
+	for ; !month.After(through); month = model.MonthEnd(month.AddDate(0, 0, 1)) {
+		//баланс на конец месяца: без операций после него, но с процентами прошлых месяцев
+		var later float64
+		if err := tx.QueryRow(
+			"select coalesce(sum(inflow - outflow), 0) from account_daily_totals"+
+				" where account_id = $1 and day > $2",
+			a.ID,
+			month,
+		).Scan(&later); err != nil {
+			return nil, err
+		}
+		interest := a.MonthlyInterest(a.Balance - later)
+		if interest <= 0 {
+			continue
+		}
+		if category == nil {
+			if category, err = interestCategory(tx, a.User); err != nil {
+				return nil, err
+			}
+		}
+		t := &model.TransactionDB{
+			TransactionDate: month.AddDate(0, 0, 1),
+			Source:          a,
+			Destination:     category,
+			Amount:          interest,
+			Type:            model.ExpenseTransaction,
+			Description:     model.InterestDescription(month),
+		}
+		if err := t.Validate(); err != nil {
+			return nil, err
+		}
+		if err := r.insertTx(tx, t); err != nil {
+			return nil, err
+		}
+		a.Balance -= interest
+		res = append(res, t)
+	}
+	if _, err := tx.Exec(
+		"update accounts set interest_accrued_through = $1 where id = $2",
+		through,
+		accountID,
+	); err != nil {
+		return nil, err
+	}
+	return res, tx.Commit()
+}
+
+// interestCategory returns the user's Interest expense category, creating it if needed.
+func interestCategory(tx *sql.Tx, userID int) (*model.Account, error) {
+	a, err := scanAccount(tx.QueryRow(
+		"select "+accountColumns+" from accounts"+
+			" where user_id = $1 and account_type = $2 and name = $3 and archived_at is null"+
+			" order by id limit 1",
+		userID,
+		model.ExpenseCatogoryAccount,
+		model.InterestCategoryName,
+	))
+	if err == nil {
+		return a, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+	a = &model.Account{
+		User: userID,
+		Name: model.InterestCategoryName,
+		Type: model.ExpenseCatogoryAccount,
+	}
+	if err := tx.QueryRow(
+		"insert into accounts(name, user_id, account_type, description, balance)"+
+			" values($1, $2, $3, '', 0)"+
+			" returning id, creation_date, version",
+		a.Name,
+		a.User,
+		a.Type,
+	).Scan(&a.ID, &a.CreationDate, &a.Version); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// checkArchived makes sure neither side of the transaction is archived.
+func checkArchived(tx *sql.Tx, t *model.TransactionJSON) error {
+	var archived bool
+	if err := tx.QueryRow(
+		"select exists(select 1 from accounts where id in ($1, $2) and archived_at is not null)",
+		t.Source,
+		t.Destination,
+	).Scan(&archived); err != nil {
+		return err
+	}
+	if archived {
+		return store.ErrAccountArchived
+	}
+	return nil
+}
+
+// checkFunds makes sure the source account can cover the transaction,
+// counting the credit limit of Debt accounts.
+// The row is locked until the end of tx so concurrent transfers can't overdraw it.
+func checkFunds(tx *sql.Tx, t *model.TransactionJSON) error {
+	if t.Type != model.StandardTransaction && t.Type != model.ExpenseTransaction {
+		return nil
+	}
+	var (
+		accountType          string
+		balance, creditLimit float64
+	)
+	if err := tx.QueryRow(
+		"select account_type, balance, credit_limit from accounts where id = $1 for update",
+		t.Source,
+	).Scan(&accountType, &balance, &creditLimit); err != nil {
+		return err
+	}
+	if balance+creditLimit >= t.Amount {
+		return nil
+	}
+	if accountType == model.DebtAccount {
+		return store.ErrCreditLimitExceeded
+	}
+	return store.ErrInsufficientFunds
+}
+
+// applyDailyTotals adds the transaction to account_daily_totals of both accounts,
+// or takes it out with a negative sign. The day is cast to date the same way
+// transaction_date is stored, so it doesn't depend on the session time zone.
+func applyDailyTotals(tx *sql.Tx, t *model.TransactionJSON, sign float64) error {
+	_, err := tx.Exec(
+		"insert into account_daily_totals"+
+			" (account_id, day, transaction_type, inflow, outflow, transactions)"+
+			" values ($1, $3::date, $4, $5, 0, $6), ($2, $3::date, $4, 0, $5, $6)"+
+			" on conflict (account_id, day, transaction_type) do update set"+
+			" inflow = account_daily_totals.inflow + excluded.inflow,"+
+			" outflow = account_daily_totals.outflow + excluded.outflow,"+
+			" transactions = account_daily_totals.transactions + excluded.transactions",
+		t.Destination,
+		t.Source,
+		t.TransactionDate,
+		t.Type,
+		sign*t.Amount,
+		int(sign),
+	)
+	return err
+}
+
+// applyBalance moves the amount between accounts the way the transaction type requires.
+// sign is 1 to apply the transaction and -1 to revert it.
+// The account version is left alone: it guards edits of the account, not its ledger.
+func applyBalance(tx *sql.Tx, t *model.TransactionJSON, sign float64) error {
+	if t.Type == model.StandardTransaction || t.Type == model.ExpenseTransaction {
+		if _, err := tx.Exec("update accounts "+
+			"set balance = balance - $1 "+
+			"where id = $2",
+			sign*t.Amount,
+			t.Source); err != nil {
+			return err
+		}
+	}
+	if t.Type == model.IncomeTransaction || t.Type == model.StandardTransaction {
+		if _, err := tx.Exec("update accounts "+
+			"set balance = balance + $1 "+
+			"where id = $2",
+			sign*t.Amount,
+			t.Destination); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,306 @@
+package sqlstore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store/sqlstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionRepository_LegacyDebtAccount(t *testing.T) {
+	db, teardown := sqlstore.TestDB(t, databaseURL)
+	defer teardown("users", "accounts", "transactions", "account_daily_totals")
+	s := sqlstore.New(db)
+
+	u := model.TestUser(t)
+	assert.NoError(t, s.User().Create(u))
+	food := model.TestAccount(t, u)
+	food.Type = model.ExpenseCatogoryAccount
+	food.Balance = 0
+	assert.NoError(t, s.Account().Create(food))
+
+	//счет в долг из старой схемы: остальные колонки заполняет миграция значениями по умолчанию
+	var id int
+	assert.NoError(t, db.QueryRow(
+		"insert into accounts(name, user_id, account_type, description, balance)"+
+			" values('Card', $1, $2, '', 100) returning id",
+		u.ID,
+		model.DebtAccount,
+	).Scan(&id))
+	lastMonth := model.TruncateDate(time.Now(), model.IntervalMonth).AddDate(0, 0, -1)
+	_, err := db.Exec(
+		"update accounts set interest_accrued_through = $1 where id = $2",
+		model.TruncateDate(lastMonth, model.IntervalMonth).AddDate(0, 0, -1),
+		id,
+	)
+	assert.NoError(t, err)
+
+	spend := func(amount float64, date time.Time) error {
+		card, err := s.Account().Find(id)
+		assert.NoError(t, err)
+		return s.Transaction().Create(&model.TransactionDB{
+			TransactionDate: date,
+			Source:          card,
+			Destination:     food,
+			Amount:          amount,
+			Type:            model.ExpenseTransaction,
+		})
+	}
+	//старый баланс остается доступным для трат
+	assert.NoError(t, spend(30, lastMonth))
+	assert.EqualError(t, spend(100, lastMonth), store.ErrCreditLimitExceeded.Error())
+
+	card, err := s.Account().Find(id)
+	assert.NoError(t, err)
+	assert.Equal(t, 70.0, card.Balance)
+	card.CreditLimit = 500
+	card.APR = 12
+	assert.NoError(t, s.Account().Save(card))
+	assert.NoError(t, spend(170, lastMonth))
+	assert.NoError(t, spend(50, time.Now()))
+
+	//на конец прошлого месяца долг был 100, трата этого месяца не учитывается
+	ts, err := s.Transaction().AccrueInterest(id, lastMonth)
+	assert.NoError(t, err)
+	if assert.Len(t, ts, 1) {
+		assert.Equal(t, 1.0, ts[0].Amount)
+	}
+	card, err = s.Account().Find(id)
+	assert.NoError(t, err)
+	assert.Equal(t, -151.0, card.Balance)
+}
+
+func TestTransactionRepository_DailyTotals(t *testing.T) {
+	db, teardown := sqlstore.TestDB(t, databaseURL)
+	defer teardown("users", "accounts", "transactions", "account_daily_totals")
+	//дни не должны зависеть от часового пояса сессии
+	db.SetMaxOpenConns(1)
+	_, err := db.Exec("set time zone 'Pacific/Kiritimati'")
+	assert.NoError(t, err)
+	s := sqlstore.New(db)
+
+	u := model.TestUser(t)
+	assert.NoError(t, s.User().Create(u))
+	card := model.TestAccount(t, u)
+	assert.NoError(t, s.Account().Create(card))
+	food := model.TestAccount(t, u)
+	food.Type = model.ExpenseCatogoryAccount
+	food.Balance = 0
+	assert.NoError(t, s.Account().Create(food))
+	salary := model.TestAccount(t, u)
+	salary.Type = model.IncomeSourceAccount
+	salary.Balance = 0
+	assert.NoError(t, s.Account().Create(salary))
+
+	first := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+	second := time.Date(2023, 5, 2, 0, 0, 0, 0, time.UTC)
+	//вечер по времени клиента остается тем же днем
+	evening := time.Date(2023, 5, 1, 23, 30, 0, 0, time.FixedZone("", -5*60*60))
+	summary := func(from, to time.Time) *model.Summary {
+		res, err := s.Transaction().GetSummary(u.ID, from, to)
+		assert.NoError(t, err)
+		return res
+	}
+	//пересчет с нуля должен давать то же, что и поддерживаемые итоги
+	assertRebuilt := func(income, expense float64) {
+		t.Helper()
+		for _, rebuilt := range []bool{false, true} {
+			if rebuilt {
+				_, err := s.RebuildDailyTotals()
+				assert.NoError(t, err)
+			}
+			res := summary(first, second)
+			assert.Equal(t, income, res.Income)
+			assert.Equal(t, expense, res.Expense)
+		}
+	}
+
+	assert.NoError(t, s.Transaction().Create(&model.TransactionDB{
+		TransactionDate: first,
+		Source:          salary,
+		Destination:     card,
+		Amount:          50,
+		Type:            model.IncomeTransaction,
+	}))
+	lunch := &model.TransactionDB{
+		TransactionDate: evening,
+		Source:          card,
+		Destination:     food,
+		Amount:          30,
+		Type:            model.ExpenseTransaction,
+	}
+	assert.NoError(t, s.Transaction().Create(lunch))
+	assert.Equal(t, 30.0, summary(first, first).Expense)
+	assertRebuilt(50, 30)
+
+	//правка переносит сумму из старого дня в новый
+	lunch.Amount = 40
+	lunch.TransactionDate = second
+	assert.NoError(t, s.Transaction().Save(lunch))
+	assert.Equal(t, 0.0, summary(first, first).Expense)
+	assert.Equal(t, 40.0, summary(second, second).Expense)
+	assertRebuilt(50, 40)
+
+	tJSON, err := s.Transaction().Find(lunch.ID)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Transaction().Delete(tJSON))
+	assertRebuilt(50, 0)
+
+	assert.NoError(t, s.Transaction().Restore(lunch.ID))
+	assertRebuilt(50, 40)
+	flows, err := s.Transaction().GetAccountFlows([]int{card.ID}, first, second)
+	assert.NoError(t, err)
+	assert.Equal(t, 50.0, flows.Inflow)
+	assert.Equal(t, 40.0, flows.Outflow)
+}
+
+// testReportAccounts creates a user with a card, an income source and an expense category.
+func testReportAccounts(t *testing.T, s *sqlstore.Store) (u *model.User, card, salary, food *model.Account) {
+	t.Helper()
+	u = model.TestUser(t)
+	assert.NoError(t, s.User().Create(u))
+	card = model.TestAccount(t, u)
+	card.Balance = 1000
+	assert.NoError(t, s.Account().Create(card))
+	salary = model.TestAccount(t, u)
+	salary.Type = model.IncomeSourceAccount
+	salary.Balance = 0
+	assert.NoError(t, s.Account().Create(salary))
+	food = model.TestAccount(t, u)
+	food.Type = model.ExpenseCatogoryAccount
+	food.Balance = 0
+	assert.NoError(t, s.Account().Create(food))
+	return u, card, salary, food
+}
+
+func TestTransactionRepository_GetTimeSeries(t *testing.T) {
+	db, teardown := sqlstore.TestDB(t, databaseURL)
+	defer teardown("users", "accounts", "transactions", "account_daily_totals")
+	s := sqlstore.New(db)
+	u, card, salary, food := testReportAccounts(t, s)
+	cash := model.TestAccount(t, u)
+	assert.NoError(t, s.Account().Create(cash))
+
+	create := func(date time.Time, src, dst *model.Account, amount float64, typ string) {
+		t.Helper()
+		assert.NoError(t, s.Transaction().Create(&model.TransactionDB{
+			TransactionDate: date,
+			Source:          src,
+			Destination:     dst,
+			Amount:          amount,
+			Type:            typ,
+		}))
+	}
+	//воскресенье и понедельник попадают в разные недели
+	create(time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC), salary, card, 500, model.IncomeTransaction)
+	create(time.Date(2023, time.January, 2, 0, 0, 0, 0, time.UTC), card, food, 40, model.ExpenseTransaction)
+	create(time.Date(2023, time.January, 2, 0, 0, 0, 0, time.UTC), cash, food, 10, model.ExpenseTransaction)
+	//переводы между своими счетами в отчет не попадают
+	create(time.Date(2023, time.January, 3, 0, 0, 0, 0, time.UTC), card, cash, 25, model.StandardTransaction)
+	create(time.Date(2023, time.March, 3, 0, 0, 0, 0, time.UTC), card, food, 60, model.ExpenseTransaction)
+
+	day := func(t time.Time) string {
+		return t.Format("2006-01-02")
+	}
+	from := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, time.March, 31, 0, 0, 0, 0, time.UTC)
+
+	//пустой февраль пропускается
+	rows, err := s.Transaction().GetTimeSeries(u.ID, from, to, model.IntervalMonth, "")
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 2) {
+		assert.Equal(t, "2023-01-01", day(rows[0].Bucket))
+		assert.Equal(t, 500.0, rows[0].Income)
+		assert.Equal(t, 50.0, rows[0].Expense)
+		assert.Equal(t, "2023-03-01", day(rows[1].Bucket))
+		assert.Equal(t, 0.0, rows[1].Income)
+		assert.Equal(t, 60.0, rows[1].Expense)
+	}
+
+	rows, err = s.Transaction().GetTimeSeries(u.ID, from, time.Date(2023, time.January, 31, 0, 0, 0, 0, time.UTC), model.IntervalWeek, "")
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 2) {
+		assert.Equal(t, "2022-12-26", day(rows[0].Bucket))
+		assert.Equal(t, 500.0, rows[0].Income)
+		assert.Equal(t, 0.0, rows[0].Expense)
+		assert.Equal(t, "2023-01-02", day(rows[1].Bucket))
+		assert.Equal(t, 50.0, rows[1].Expense)
+	}
+
+	rows, err = s.Transaction().GetTimeSeries(u.ID, from, to, model.IntervalMonth, model.GroupByAccount)
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 3) {
+		assert.Equal(t, card.ID, rows[0].Group)
+		assert.Equal(t, 500.0, rows[0].Income)
+		assert.Equal(t, 40.0, rows[0].Expense)
+		assert.Equal(t, cash.ID, rows[1].Group)
+		assert.Equal(t, 10.0, rows[1].Expense)
+		assert.Equal(t, card.ID, rows[2].Group)
+		assert.Equal(t, "2023-03-01", day(rows[2].Bucket))
+	}
+
+	rows, err = s.Transaction().GetTimeSeries(u.ID, from, to, model.IntervalMonth, model.GroupByCategory)
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 3) {
+		assert.Equal(t, salary.ID, rows[0].Group)
+		assert.Equal(t, 500.0, rows[0].Income)
+		assert.Equal(t, food.ID, rows[1].Group)
+		assert.Equal(t, 50.0, rows[1].Expense)
+		assert.Equal(t, food.ID, rows[2].Group)
+		assert.Equal(t, 60.0, rows[2].Expense)
+	}
+
+	rows, err = s.Transaction().GetTimeSeries(u.ID+1, from, to, model.IntervalMonth, "")
+	assert.NoError(t, err)
+	assert.Empty(t, rows)
+}
+
+func TestTransactionRepository_GetCategoryComparison(t *testing.T) {
+	db, teardown := sqlstore.TestDB(t, databaseURL)
+	defer teardown("users", "accounts", "transactions", "account_daily_totals")
+	s := sqlstore.New(db)
+	u, card, salary, food := testReportAccounts(t, s)
+	fun := model.TestAccount(t, u)
+	fun.Type = model.ExpenseCatogoryAccount
+	fun.Balance = 0
+	assert.NoError(t, s.Account().Create(fun))
+
+	for _, tr := range []*model.TransactionDB{
+		//до прошлого периода, не учитывается
+		{TransactionDate: time.Date(2023, time.January, 31, 0, 0, 0, 0, time.UTC), Source: card, Destination: food, Amount: 70, Type: model.ExpenseTransaction},
+		{TransactionDate: time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC), Source: salary, Destination: card, Amount: 500, Type: model.IncomeTransaction},
+		{TransactionDate: time.Date(2023, time.February, 28, 0, 0, 0, 0, time.UTC), Source: card, Destination: food, Amount: 20, Type: model.ExpenseTransaction},
+		{TransactionDate: time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC), Source: card, Destination: food, Amount: 30, Type: model.ExpenseTransaction},
+		{TransactionDate: time.Date(2023, time.March, 31, 0, 0, 0, 0, time.UTC), Source: card, Destination: fun, Amount: 15, Type: model.ExpenseTransaction},
+		//после текущего периода, не учитывается
+		{TransactionDate: time.Date(2023, time.April, 1, 0, 0, 0, 0, time.UTC), Source: card, Destination: fun, Amount: 5, Type: model.ExpenseTransaction},
+	} {
+		assert.NoError(t, s.Transaction().Create(tr))
+	}
+
+	res, err := s.Transaction().GetCategoryComparison(
+		u.ID,
+		time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, time.March, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC),
+	)
+	assert.NoError(t, err)
+	//категории только одного из периодов получают ноль в другом
+	assert.ElementsMatch(t, []*model.CategoryComparison{
+		{Account: salary.ID, Current: 0, Previous: 500},
+		{Account: food.ID, Current: 30, Previous: 20},
+		{Account: fun.ID, Current: 15, Previous: 0},
+	}, res)
+
+	res, err = s.Transaction().GetCategoryComparison(
+		u.ID+1,
+		time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, time.March, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC),
+	)
+	assert.NoError(t, err)
+	assert.Empty(t, res)
+}
@@ -27,6 +27,27 @@ func (ur *UserRepository) Create(u *model.User) error {
 	).Scan(&u.ID)
 }
 
+func (ur *UserRepository) Save(u *model.User) error {
+	if err := u.Validate(); err != nil {
+		return err
+	}
+	res, err := ur.store.db.Exec(
+		"update users set email = $1, encrypted_password = $2 where id = $3",
+		u.Email,
+		u.EncryptedPassword,
+		u.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if count, err := res.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return store.ErrRecordNotFound
+	}
+	return nil
+}
+
 func (ur *UserRepository) Find(id int) (*model.User, error) {
 	u := &model.User{}
 	if err := ur.store.db.QueryRow(
@@ -0,0 +1,192 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+	"github.com/lib/pq"
+)
+
+const deliveryColumns = "id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at"
+
+type WebhookRepository struct {
+	store *Store
+}
+
+func (r *WebhookRepository) Create(w *model.Webhook) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	return r.store.db.QueryRow(
+		"insert into webhooks(user_id, url, secret, events) values($1, $2, $3, $4) returning id, created_at",
+		w.User,
+		w.URL,
+		w.Secret,
+		pq.Array(w.Events),
+	).Scan(&w.ID, &w.CreatedAt)
+}
+
+func (r *WebhookRepository) Delete(id int) error {
+	res, err := r.store.db.Exec("delete from webhooks where id = $1", id)
+	if err != nil {
+		return err
+	}
+	if count, err := res.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return store.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *WebhookRepository) Find(id int) (*model.Webhook, error) {
+	w := &model.Webhook{}
+	if err := r.store.db.QueryRow(
+		"select id, user_id, url, secret, events, created_at from webhooks where id = $1",
+		id,
+	).Scan(
+		&w.ID,
+		&w.User,
+		&w.URL,
+		&w.Secret,
+		pq.Array(&w.Events),
+		&w.CreatedAt,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, store.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return w, nil
+}
+
+func (r *WebhookRepository) GetAllByUser(userID int) ([]*model.Webhook, error) {
+	rows, err := r.store.db.Query(
+		"select id, user_id, url, secret, events, created_at from webhooks where user_id = $1 order by id",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]*model.Webhook, 0)
+	for rows.Next() {
+		w := &model.Webhook{}
+		if err := rows.Scan(
+			&w.ID,
+			&w.User,
+			&w.URL,
+			&w.Secret,
+			pq.Array(&w.Events),
+			&w.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		res = append(res, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *WebhookRepository) GetDeliveries(webhookID int) ([]*model.WebhookDelivery, error) {
+	return r.findDeliveries(
+		"select "+deliveryColumns+
+			" from webhook_deliveries"+
+			" where webhook_id = $1"+
+			" order by id desc",
+		webhookID,
+	)
+}
+
+func (r *WebhookRepository) ClaimDeliveries(now, lease time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	return r.findDeliveries(
+		"update webhook_deliveries set next_attempt_at = $2"+
+			" where id in ("+
+			"select id from webhook_deliveries"+
+			" where status = 'pending' and next_attempt_at <= $1"+
+			" order by next_attempt_at"+
+			" limit $3"+
+			" for update skip locked)"+
+			" returning "+deliveryColumns,
+		now,
+		lease,
+		limit,
+	)
+}
+
+func (r *WebhookRepository) SaveDelivery(d *model.WebhookDelivery) error {
+	_, err := r.store.db.Exec(
+		"update webhook_deliveries"+
+			" set status = $1, attempts = $2, next_attempt_at = $3, last_status_code = $4, last_error = $5, delivered_at = $6"+
+			" where id = $7",
+		d.Status,
+		d.Attempts,
+		d.NextAttemptAt,
+		d.LastStatusCode,
+		d.LastError,
+		d.DeliveredAt,
+		d.ID,
+	)
+	return err
+}
+
+func (r *WebhookRepository) findDeliveries(query string, args ...interface{}) ([]*model.WebhookDelivery, error) {
+	rows, err := r.store.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]*model.WebhookDelivery, 0)
+	for rows.Next() {
+		d := &model.WebhookDelivery{}
+		var payload []byte
+		if err := rows.Scan(
+			&d.ID,
+			&d.WebhookID,
+			&d.Event,
+			&payload,
+			&d.Status,
+			&d.Attempts,
+			&d.NextAttemptAt,
+			&d.LastStatusCode,
+			&d.LastError,
+			&d.CreatedAt,
+			&d.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		d.Payload = payload
+		res = append(res, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// enqueueWebhooks writes the event to the outbox of every subscribed webhook
+// of the users who can see one of the accounts. It runs in the same DB
+// transaction as the change, so the event is queued only if the change commits.
+func enqueueWebhooks(tx *sql.Tx, event string, data interface{}, accountIDs ...int) error {
+	payload, err := json.Marshal(&model.WebhookPayload{Event: event, Data: data})
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"insert into webhook_deliveries(webhook_id, event, payload)"+
+			" select id, $1, $2 from webhooks"+
+			" where $1 = any(events)"+
+			" and user_id in ("+
+			"select user_id from accounts where id = any($3)"+
+			" union select user_id from account_members where account_id = any($3))",
+		event,
+		string(payload),
+		pq.Array(accountIDs),
+	)
+	return err
+}
@@ -4,4 +4,14 @@ type Store interface {
 	User() UserRepo
 	Account() AccountRepo
 	Transaction() TransactionRepo
+	Audit() AuditRepo
+	IdempotencyKey() IdempotencyKeyRepo
+	Webhook() WebhookRepo
+	AnomalySettings() AnomalySettingsRepo
+	Goal() GoalRepo
+	Rule() RuleRepo
+	Duplicate() DuplicateRepo
+	// WithActor returns a store over the same data that also writes changes
+	// of accounts and transactions to the audit log, in the same DB transaction.
+	WithActor(actor int, requestID string) Store
 }
@@ -1,6 +1,8 @@
 package teststore
 
 import (
+	"time"
+
 	"github.com/Aza-9798/costs-rest-api/internal/app/model"
 	"github.com/Aza-9798/costs-rest-api/internal/app/store"
 )
@@ -8,15 +10,26 @@ import (
 type AccountRepository struct {
 	store    *Store
 	accounts map[int]*model.Account
+	members  map[int]map[int]string
+	// lastID is shared with the copies made by WithActor,
+	// so that ids of deleted accounts are not reused.
+	lastID *int
 }
 
 func (r *AccountRepository) Create(a *model.Account) error {
 	if err := a.Validate(); err != nil {
 		return err
 	}
+	if err := r.checkParent(a); err != nil {
+		return err
+	}
 
-	a.ID = len(r.accounts)
+	*r.lastID++
+	a.ID = *r.lastID
+	a.Version = 1
+	a.InterestAccruedThrough = model.TruncateDate(time.Now(), model.IntervalMonth).AddDate(0, 0, -1)
 	r.accounts[a.ID] = a
+	r.store.record(model.AuditEntityAccount, a.ID, model.AuditCreate, nil, a)
 	return nil
 }
 
@@ -24,10 +37,51 @@ func (r *AccountRepository) Save(a *model.Account) error {
 	if err := a.Validate(); err != nil {
 		return err
 	}
-	if _, ok := r.accounts[a.ID]; !ok {
+	current, ok := r.accounts[a.ID]
+	if !ok {
 		return store.ErrRecordNotFound
 	}
+	if current.Version != a.Version {
+		return store.ErrVersionConflict
+	}
+	if err := r.checkParent(a); err != nil {
+		return err
+	}
+	if err := r.checkCycle(a); err != nil {
+		return err
+	}
+	a.Balance = current.Balance
+	a.InterestAccruedThrough = current.InterestAccruedThrough
+	a.Version++
 	r.accounts[a.ID] = a
+	r.store.record(model.AuditEntityAccount, a.ID, model.AuditUpdate, current, a)
+	return nil
+}
+
+func (r *AccountRepository) checkParent(a *model.Account) error {
+	if a.Parent == nil {
+		return nil
+	}
+	parent, ok := r.accounts[*a.Parent]
+	if !ok || parent.Type != a.Type || parent.User != a.User {
+		return store.ErrInvalidParent
+	}
+	return nil
+}
+
+func (r *AccountRepository) checkCycle(a *model.Account) error {
+	if a.Parent == nil {
+		return nil
+	}
+	if *a.Parent == a.ID {
+		return store.ErrCategoryCycle
+	}
+	descendants, _ := r.GetDescendants(a.ID)
+	for _, id := range descendants {
+		if id == *a.Parent {
+			return store.ErrCategoryCycle
+		}
+	}
 	return nil
 }
 
@@ -40,27 +94,176 @@ func (r *AccountRepository) Find(id int) (*model.Account, error) {
 }
 
 func (r *AccountRepository) Delete(id int) error {
-	if _, ok := r.accounts[id]; !ok {
+	before, ok := r.accounts[id]
+	if !ok {
 		return store.ErrRecordNotFound
 	}
+	//операции в корзине тоже считаются, как и в sqlstore
+	if r.store.Transaction().(*TransactionRepository).hasHistory(id) {
+		return store.ErrAccountHasHistory
+	}
+	for _, acc := range r.accounts {
+		if acc.Parent != nil && *acc.Parent == id {
+			acc.Parent = nil
+		}
+	}
 	delete(r.accounts, id)
+	delete(r.members, id)
+	r.store.record(model.AuditEntityAccount, id, model.AuditDelete, before, nil)
 	return nil
 }
 
-func (r *AccountRepository) GetAllByUser(userID int) ([]*model.Account, error) {
+func (r *AccountRepository) Archive(id int) error {
+	a, err := r.Find(id)
+	if err != nil {
+		return err
+	}
+	if a.IsArchived() {
+		return store.ErrAccountArchived
+	}
+	before := *a
+	now := time.Now()
+	a.ArchivedAt = &now
+	a.Version++
+	r.store.record(model.AuditEntityAccount, id, model.AuditArchive, &before, a)
+	return nil
+}
+
+func (r *AccountRepository) Unarchive(id int) error {
+	a, err := r.Find(id)
+	if err != nil {
+		return err
+	}
+	if !a.IsArchived() {
+		return store.ErrAccountNotArchived
+	}
+	before := *a
+	a.ArchivedAt = nil
+	a.Version++
+	r.store.record(model.AuditEntityAccount, id, model.AuditUnarchive, &before, a)
+	return nil
+}
+
+func (r *AccountRepository) GetAllByUser(userID int, withArchived bool) ([]*model.Account, error) {
 	res := make([]*model.Account, 0)
 	for _, acc := range r.accounts {
-		if acc.User == userID {
+		if acc.IsArchived() && !withArchived {
+			continue
+		}
+		if acc.User == userID || r.members[acc.ID][userID] != "" {
 			res = append(res, acc)
 		}
 	}
 	return res, nil
 }
 
-func (r *AccountRepository) IsAccountBelongsUser(accountID, userID int) (bool, error) {
+func (r *AccountRepository) GetInterestDue(through time.Time) ([]int, error) {
+	res := make([]int, 0)
+	for _, a := range r.accounts {
+		if a.IsLiability() && a.APR > 0 && !a.IsArchived() && a.InterestAccruedThrough.Before(through) {
+			res = append(res, a.ID)
+		}
+	}
+	return res, nil
+}
+
+func (r *AccountRepository) GetDescendants(id int) ([]int, error) {
+	res := make([]int, 0)
+	seen := map[int]bool{id: true}
+	queue := []int{id}
+	for len(queue) > 0 {
+		parentID := queue[0]
+		queue = queue[1:]
+		for _, acc := range r.accounts {
+			if acc.Parent != nil && *acc.Parent == parentID && !seen[acc.ID] {
+				seen[acc.ID] = true
+				res = append(res, acc.ID)
+				queue = append(queue, acc.ID)
+			}
+		}
+	}
+	return res, nil
+}
+
+func (r *AccountRepository) GetRole(accountID, userID int) (string, error) {
 	a, err := r.Find(accountID)
 	if err != nil {
-		return false, err
+		return "", err
+	}
+	if a.User == userID {
+		return model.OwnerRole, nil
+	}
+	return r.members[accountID][userID], nil
+}
+
+func (r *AccountRepository) AddMember(m *model.AccountMember) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	a, err := r.Find(m.AccountID)
+	if err != nil {
+		return err
+	}
+	if a.User == m.UserID {
+		return store.ErrAccountOwner
+	}
+	if _, err := r.store.User().Find(m.UserID); err != nil {
+		return err
+	}
+	if r.members[m.AccountID] == nil {
+		r.members[m.AccountID] = make(map[int]string)
 	}
-	return a.User == userID, nil
+	r.members[m.AccountID][m.UserID] = m.Role
+	r.store.record(model.AuditEntityAccount, m.AccountID, model.AuditAddMember, nil, m)
+	return nil
+}
+
+func (r *AccountRepository) RemoveMember(accountID, userID int) error {
+	role, ok := r.members[accountID][userID]
+	if !ok {
+		return store.ErrRecordNotFound
+	}
+	before := &model.AccountMember{
+		AccountID: accountID,
+		UserID:    userID,
+		Role:      role,
+	}
+	if u, err := r.store.User().Find(userID); err == nil {
+		before.Email = u.Email
+	}
+	delete(r.members[accountID], userID)
+	r.store.record(model.AuditEntityAccount, accountID, model.AuditRemoveMember, before, nil)
+	return nil
+}
+
+func (r *AccountRepository) GetMembers(accountID int) ([]*model.AccountMember, error) {
+	res := make([]*model.AccountMember, 0)
+	for userID, role := range r.members[accountID] {
+		u, err := r.store.User().Find(userID)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, &model.AccountMember{
+			AccountID: accountID,
+			UserID:    userID,
+			Email:     u.Email,
+			Role:      role,
+		})
+	}
+	return res, nil
+}
+
+func (r *AccountRepository) GetUsers(accountIDs []int) (map[int][]int, error) {
+	res := make(map[int][]int)
+	for _, id := range accountIDs {
+		a, ok := r.accounts[id]
+		if !ok || res[id] != nil {
+			continue
+		}
+		res[id] = append(res[id], a.User)
+		for userID := range r.members[id] {
+			res[id] = append(res[id], userID)
+		}
+	}
+	return res, nil
 }
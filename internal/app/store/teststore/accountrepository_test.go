@@ -0,0 +1,138 @@
+package teststore_test
+
+import (
+	"testing"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store/teststore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountRepository_Parent(t *testing.T) {
+	s := teststore.New()
+	u := model.TestUser(t)
+	s.User().Create(u)
+
+	food := model.TestAccount(t, u)
+	food.Type = model.ExpenseCatogoryAccount
+	assert.NoError(t, s.Account().Create(food))
+
+	restaurants := model.TestAccount(t, u)
+	restaurants.Type = model.ExpenseCatogoryAccount
+	restaurants.Parent = &food.ID
+	assert.NoError(t, s.Account().Create(restaurants))
+
+	income := model.TestAccount(t, u)
+	income.Type = model.IncomeSourceAccount
+	income.Parent = &food.ID
+	assert.EqualError(t, s.Account().Create(income), store.ErrInvalidParent.Error())
+
+	descendants, err := s.Account().GetDescendants(food.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{restaurants.ID}, descendants)
+
+	updated := *food
+	updated.Parent = &restaurants.ID
+	assert.EqualError(t, s.Account().Save(&updated), store.ErrCategoryCycle.Error())
+}
+
+func TestAccountRepository_Archive(t *testing.T) {
+	s := teststore.New()
+	u := model.TestUser(t)
+	s.User().Create(u)
+
+	card := model.TestAccount(t, u)
+	assert.NoError(t, s.Account().Create(card))
+	food := model.TestAccount(t, u)
+	food.Type = model.ExpenseCatogoryAccount
+	assert.NoError(t, s.Account().Create(food))
+	tr := &model.TransactionDB{
+		Source:      card,
+		Destination: food,
+		Amount:      10,
+		Type:        model.ExpenseTransaction,
+	}
+	assert.NoError(t, s.Transaction().Create(tr))
+	assert.EqualError(t, s.Account().Delete(card.ID), store.ErrAccountHasHistory.Error())
+
+	assert.NoError(t, s.Account().Archive(card.ID))
+	assert.EqualError(t, s.Account().Archive(card.ID), store.ErrAccountArchived.Error())
+	accounts, err := s.Account().GetAllByUser(u.ID, false)
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 1)
+	accounts, err = s.Account().GetAllByUser(u.ID, true)
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 2)
+
+	assert.EqualError(t, s.Transaction().Create(&model.TransactionDB{
+		Source:      card,
+		Destination: food,
+		Amount:      10,
+		Type:        model.ExpenseTransaction,
+	}), store.ErrAccountArchived.Error())
+	edited := *tr
+	edited.Amount = 20
+	assert.EqualError(t, s.Transaction().Save(&edited), store.ErrAccountArchived.Error())
+
+	assert.NoError(t, s.Account().Unarchive(card.ID))
+	assert.EqualError(t, s.Account().Unarchive(card.ID), store.ErrAccountNotArchived.Error())
+	accounts, err = s.Account().GetAllByUser(u.ID, false)
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 2)
+
+	//операция в корзине все еще держит историю счета
+	assert.NoError(t, s.Transaction().Delete(tr.ToJSON()))
+	assert.EqualError(t, s.Account().Delete(card.ID), store.ErrAccountHasHistory.Error())
+
+	//id удаленного счета не достается новому
+	spare := model.TestAccount(t, u)
+	assert.NoError(t, s.Account().Create(spare))
+	assert.NoError(t, s.Account().Delete(spare.ID))
+	again := model.TestAccount(t, u)
+	assert.NoError(t, s.Account().Create(again))
+	assert.NotEqual(t, spare.ID, again.ID)
+}
+
+func TestAccountRepository_SaveVersion(t *testing.T) {
+	s := teststore.New()
+	u := model.TestUser(t)
+	s.User().Create(u)
+	a := model.TestAccount(t, u)
+	assert.NoError(t, s.Account().Create(a))
+	assert.Equal(t, 1, a.Version)
+
+	stale := *a
+	updated := *a
+	updated.Name = "Updated"
+	assert.NoError(t, s.Account().Save(&updated))
+	assert.Equal(t, 2, updated.Version)
+
+	stale.Name = "Stale"
+	assert.Equal(t, store.ErrVersionConflict, s.Account().Save(&stale))
+}
+
+func TestAccountRepository_GetUsers(t *testing.T) {
+	s := teststore.New()
+	owner := model.TestUser(t)
+	s.User().Create(owner)
+	member := model.TestUser(t)
+	member.Email = "member@example.org"
+	s.User().Create(member)
+
+	shared := model.TestAccount(t, owner)
+	assert.NoError(t, s.Account().Create(shared))
+	own := model.TestAccount(t, owner)
+	assert.NoError(t, s.Account().Create(own))
+	assert.NoError(t, s.Account().AddMember(&model.AccountMember{
+		AccountID: shared.ID,
+		UserID:    member.ID,
+		Role:      model.ViewerRole,
+	}))
+
+	users, err := s.Account().GetUsers([]int{shared.ID, own.ID, shared.ID, 42})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []int{owner.ID, member.ID}, users[shared.ID])
+	assert.Equal(t, []int{owner.ID}, users[own.ID])
+	assert.Empty(t, users[42])
+}
@@ -0,0 +1,27 @@
+package teststore
+
+import (
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+)
+
+type AnomalySettingsRepository struct {
+	store    *Store
+	settings map[int]*model.AnomalySettings
+}
+
+func (r *AnomalySettingsRepository) Find(userID int) (*model.AnomalySettings, error) {
+	if s, ok := r.settings[userID]; ok {
+		res := *s
+		return &res, nil
+	}
+	return model.DefaultAnomalySettings(userID), nil
+}
+
+func (r *AnomalySettingsRepository) Save(s *model.AnomalySettings) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	saved := *s
+	r.settings[s.User] = &saved
+	return nil
+}
@@ -0,0 +1,43 @@
+package teststore
+
+import (
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+)
+
+// auditActor is who makes the changes through a store returned by WithActor.
+type auditActor struct {
+	id        int
+	requestID string
+}
+
+type AuditRepository struct {
+	store   *Store
+	entries []*model.AuditEntry
+}
+
+func (r *AuditRepository) Create(e *model.AuditEntry) error {
+	e.ID = len(r.entries) + 1
+	e.CreatedAt = time.Now()
+	r.entries = append(r.entries, e)
+	return nil
+}
+
+func (r *AuditRepository) GetByEntity(entity string, entityID int) ([]*model.AuditEntry, error) {
+	res := make([]*model.AuditEntry, 0)
+	for _, e := range r.entries {
+		if e.Entity == entity && e.EntityID == entityID {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+// record adds an audit entry. Stores without an actor keep no log.
+func (s *Store) record(entity string, entityID int, operation string, before, after interface{}) {
+	if s.actor == nil {
+		return
+	}
+	s.Audit().Create(model.NewAuditEntry(s.actor.id, s.actor.requestID, entity, entityID, operation, before, after))
+}
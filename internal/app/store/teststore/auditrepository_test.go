@@ -0,0 +1,66 @@
+package teststore_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store/teststore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_WithActor(t *testing.T) {
+	ts := teststore.New()
+	u := model.TestUser(t)
+	ts.User().Create(u)
+	s := ts.WithActor(u.ID, "request-1")
+
+	card := model.TestAccount(t, u)
+	assert.NoError(t, s.Account().Create(card))
+	food := model.TestAccount(t, u)
+	food.Type = model.ExpenseCatogoryAccount
+	assert.NoError(t, s.Account().Create(food))
+
+	tr := &model.TransactionDB{
+		TransactionDate: time.Now(),
+		Source:          card,
+		Destination:     food,
+		Amount:          10,
+		Type:            model.ExpenseTransaction,
+	}
+	assert.NoError(t, s.Transaction().Create(tr))
+	tJSON, _ := s.Transaction().Find(tr.ID)
+	assert.NoError(t, s.Transaction().Delete(tJSON))
+	assert.NoError(t, s.Transaction().Restore(tr.ID))
+
+	history, err := ts.Audit().GetByEntity(model.AuditEntityTransaction, tr.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, history, 3) {
+		assert.Equal(t, model.AuditCreate, history[0].Operation)
+		assert.Nil(t, history[0].Before)
+		assert.Equal(t, model.AuditDelete, history[1].Operation)
+		assert.Equal(t, model.AuditRestore, history[2].Operation)
+		assert.Equal(t, u.ID, history[2].Actor)
+		assert.Equal(t, "request-1", history[2].RequestID)
+	}
+
+	updated := *card
+	updated.Name = "Card"
+	assert.NoError(t, s.Account().Save(&updated))
+	history, err = ts.Audit().GetByEntity(model.AuditEntityAccount, card.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, history, 2) {
+		before, after := &model.Account{}, &model.Account{}
+		assert.NoError(t, json.Unmarshal(history[1].Before, before))
+		assert.NoError(t, json.Unmarshal(history[1].After, after))
+		assert.Equal(t, "Test", before.Name)
+		assert.Equal(t, "Card", after.Name)
+	}
+
+	//без автора журнал не ведется
+	assert.NoError(t, ts.Account().Archive(food.ID))
+	history, err = ts.Audit().GetByEntity(model.AuditEntityAccount, food.ID)
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+}
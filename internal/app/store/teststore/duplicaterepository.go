@@ -0,0 +1,138 @@
+package teststore
+
+import (
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+)
+
+// duplicateCandidate keeps the ids of the pair,
+// so the transactions are always read in their current state.
+type duplicateCandidate struct {
+	id            int
+	transactionID int
+	duplicateOfID int
+	score         float64
+	status        string
+	createdAt     time.Time
+	resolvedAt    *time.Time
+}
+
+type DuplicateRepository struct {
+	store      *Store
+	candidates map[int]*duplicateCandidate
+}
+
+// flag records the earlier transactions t is likely a duplicate of.
+func (r *DuplicateRepository) flag(t *model.TransactionJSON) {
+	for _, other := range r.transactions().transactions {
+		if other.DeletedAt != nil || other.ID == t.ID {
+			continue
+		}
+		score, ok := model.DuplicateScore(other.ToJSON(), t)
+		if !ok || r.flagged(t.ID, other.ID) {
+			continue
+		}
+		id := len(r.candidates) + 1
+		r.candidates[id] = &duplicateCandidate{
+			id:            id,
+			transactionID: t.ID,
+			duplicateOfID: other.ID,
+			score:         score,
+			status:        model.DuplicatePending,
+			createdAt:     time.Now(),
+		}
+	}
+}
+
+func (r *DuplicateRepository) flagged(transactionID, duplicateOfID int) bool {
+	for _, c := range r.candidates {
+		if c.transactionID == transactionID && c.duplicateOfID == duplicateOfID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *DuplicateRepository) GetPending(userID int) ([]*model.DuplicateCandidate, error) {
+	transactions := r.transactions()
+	res := make([]*model.DuplicateCandidate, 0)
+	for id := 1; id <= len(r.candidates); id++ {
+		c := r.candidates[id]
+		if c.status != model.DuplicatePending {
+			continue
+		}
+		t, other := transactions.transactions[c.transactionID], transactions.transactions[c.duplicateOfID]
+		//пары с вычищенными из корзины переводами пропускаем, как каскадное удаление в базе
+		if t == nil || other == nil || t.DeletedAt != nil || other.DeletedAt != nil || !transactions.belongsUser(t, userID) {
+			continue
+		}
+		res = append(res, r.toModel(c))
+	}
+	return res, nil
+}
+
+func (r *DuplicateRepository) Find(id int) (*model.DuplicateCandidate, error) {
+	c, ok := r.candidates[id]
+	if !ok {
+		return nil, store.ErrRecordNotFound
+	}
+	transactions := r.transactions().transactions
+	if transactions[c.transactionID] == nil || transactions[c.duplicateOfID] == nil {
+		return nil, store.ErrRecordNotFound
+	}
+	return r.toModel(c), nil
+}
+
+func (r *DuplicateRepository) Resolve(id int, status string) error {
+	c, ok := r.candidates[id]
+	if !ok {
+		return store.ErrRecordNotFound
+	}
+	if c.status != model.DuplicatePending {
+		return store.ErrDuplicateResolved
+	}
+	now := time.Now()
+	c.status = status
+	c.resolvedAt = &now
+	return nil
+}
+
+func (r *DuplicateRepository) Merge(id, drop int) error {
+	c, ok := r.candidates[id]
+	if !ok {
+		return store.ErrRecordNotFound
+	}
+	if c.status != model.DuplicatePending {
+		return store.ErrDuplicateResolved
+	}
+	t, ok := r.transactions().transactions[drop]
+	if !ok || drop != c.transactionID && drop != c.duplicateOfID {
+		return store.ErrRecordNotFound
+	}
+	if err := r.transactions().Delete(t.ToJSON()); err != nil {
+		return err
+	}
+	now := time.Now()
+	c.status = model.DuplicateMerged
+	c.resolvedAt = &now
+	return nil
+}
+
+func (r *DuplicateRepository) toModel(c *duplicateCandidate) *model.DuplicateCandidate {
+	transactions := r.transactions().transactions
+	return &model.DuplicateCandidate{
+		ID:          c.id,
+		Transaction: transactions[c.transactionID].ToJSON(),
+		DuplicateOf: transactions[c.duplicateOfID].ToJSON(),
+		Score:       c.score,
+		Status:      c.status,
+		CreatedAt:   c.createdAt,
+		ResolvedAt:  c.resolvedAt,
+	}
+}
+
+func (r *DuplicateRepository) transactions() *TransactionRepository {
+	return r.store.Transaction().(*TransactionRepository)
+}
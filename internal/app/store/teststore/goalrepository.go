@@ -0,0 +1,81 @@
+package teststore
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+)
+
+type GoalRepository struct {
+	store  *Store
+	goals  map[int]*model.Goal
+	nextID int
+}
+
+func (r *GoalRepository) Create(g *model.Goal) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	r.nextID++
+	g.ID = r.nextID
+	g.CreatedAt = time.Now()
+	r.goals[g.ID] = g
+	return nil
+}
+
+func (r *GoalRepository) Save(g *model.Goal) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	saved, ok := r.goals[g.ID]
+	if !ok {
+		return store.ErrRecordNotFound
+	}
+	saved.Name = g.Name
+	saved.TargetAmount = g.TargetAmount
+	saved.TargetDate = g.TargetDate
+	saved.MonthlyContribution = g.MonthlyContribution
+	return nil
+}
+
+func (r *GoalRepository) Delete(id int) error {
+	if _, ok := r.goals[id]; !ok {
+		return store.ErrRecordNotFound
+	}
+	delete(r.goals, id)
+	return nil
+}
+
+func (r *GoalRepository) Find(id int) (*model.Goal, error) {
+	g, ok := r.goals[id]
+	if !ok {
+		return nil, store.ErrRecordNotFound
+	}
+	return g, nil
+}
+
+func (r *GoalRepository) GetAllByUser(userID int) ([]*model.Goal, error) {
+	accounts, err := r.store.Account().GetAllByUser(userID, true)
+	if err != nil {
+		return nil, err
+	}
+	visible := make(map[int]bool)
+	for _, a := range accounts {
+		visible[a.ID] = true
+	}
+	res := make([]*model.Goal, 0)
+	for _, g := range r.goals {
+		if visible[g.Account] {
+			res = append(res, g)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].TargetDate.Equal(res[j].TargetDate) {
+			return res[i].TargetDate.Before(res[j].TargetDate)
+		}
+		return res[i].ID < res[j].ID
+	})
+	return res, nil
+}
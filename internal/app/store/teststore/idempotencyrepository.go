@@ -0,0 +1,59 @@
+package teststore
+
+import (
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+)
+
+type IdempotencyKeyRepository struct {
+	store *Store
+	keys  map[int]map[string]*model.IdempotencyKey
+}
+
+func (r *IdempotencyKeyRepository) Create(k *model.IdempotencyKey) error {
+	if _, ok := r.keys[k.UserID][k.Key]; ok {
+		return store.ErrIdempotencyKeyUsed
+	}
+	if r.keys[k.UserID] == nil {
+		r.keys[k.UserID] = make(map[string]*model.IdempotencyKey)
+	}
+	k.CreatedAt = time.Now()
+	r.keys[k.UserID][k.Key] = k
+	return nil
+}
+
+func (r *IdempotencyKeyRepository) Save(k *model.IdempotencyKey) error {
+	if _, ok := r.keys[k.UserID][k.Key]; !ok {
+		return store.ErrRecordNotFound
+	}
+	r.keys[k.UserID][k.Key] = k
+	return nil
+}
+
+func (r *IdempotencyKeyRepository) Delete(userID int, key string) error {
+	delete(r.keys[userID], key)
+	return nil
+}
+
+func (r *IdempotencyKeyRepository) Find(userID int, key string) (*model.IdempotencyKey, error) {
+	k, ok := r.keys[userID][key]
+	if !ok {
+		return nil, store.ErrRecordNotFound
+	}
+	return k, nil
+}
+
+func (r *IdempotencyKeyRepository) Purge(before time.Time) (int, error) {
+	count := 0
+	for _, keys := range r.keys {
+		for key, k := range keys {
+			if k.CreatedAt.Before(before) {
+				delete(keys, key)
+				count++
+			}
+		}
+	}
+	return count, nil
+}
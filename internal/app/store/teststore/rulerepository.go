@@ -0,0 +1,74 @@
+package teststore
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+)
+
+type RuleRepository struct {
+	store  *Store
+	rules  map[int]*model.Rule
+	nextID int
+}
+
+func (r *RuleRepository) Create(rule *model.Rule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	rule.Tags = model.NormalizeTags(rule.Tags)
+	r.nextID++
+	rule.ID = r.nextID
+	rule.CreatedAt = time.Now()
+	r.rules[rule.ID] = rule
+	return nil
+}
+
+func (r *RuleRepository) Save(rule *model.Rule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	old, ok := r.rules[rule.ID]
+	if !ok {
+		return store.ErrRecordNotFound
+	}
+	rule.Tags = model.NormalizeTags(rule.Tags)
+	rule.User = old.User
+	rule.CreatedAt = old.CreatedAt
+	r.rules[rule.ID] = rule
+	return nil
+}
+
+func (r *RuleRepository) Delete(id int) error {
+	if _, ok := r.rules[id]; !ok {
+		return store.ErrRecordNotFound
+	}
+	delete(r.rules, id)
+	return nil
+}
+
+func (r *RuleRepository) Find(id int) (*model.Rule, error) {
+	rule, ok := r.rules[id]
+	if !ok {
+		return nil, store.ErrRecordNotFound
+	}
+	return rule, nil
+}
+
+func (r *RuleRepository) GetAllByUser(userID int) ([]*model.Rule, error) {
+	res := make([]*model.Rule, 0)
+	for _, rule := range r.rules {
+		if rule.User == userID {
+			res = append(res, rule)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Priority != res[j].Priority {
+			return res[i].Priority < res[j].Priority
+		}
+		return res[i].ID < res[j].ID
+	})
+	return res, nil
+}
@@ -6,15 +6,55 @@ import (
 )
 
 type Store struct {
-	userRepository        *UserRepository
-	accountRepository     *AccountRepository
-	transactionRepository *TransactionRepository
+	actor                     *auditActor
+	userRepository            *UserRepository
+	accountRepository         *AccountRepository
+	transactionRepository     *TransactionRepository
+	auditRepository           *AuditRepository
+	idempotencyRepository     *IdempotencyKeyRepository
+	webhookRepository         *WebhookRepository
+	anomalySettingsRepository *AnomalySettingsRepository
+	goalRepository            *GoalRepository
+	ruleRepository            *RuleRepository
+	duplicateRepository       *DuplicateRepository
 }
 
 func New() *Store {
 	return &Store{}
 }
 
+// WithActor returns a store over the same data whose account and
+// transaction changes are written to the audit log on behalf of actor.
+func (s *Store) WithActor(actor int, requestID string) store.Store {
+	//все репозитории общие, чтобы копия видела те же данные
+	s.User()
+	s.Account()
+	s.Transaction()
+	s.Audit()
+	s.IdempotencyKey()
+	s.Webhook()
+	s.AnomalySettings()
+	s.Goal()
+	s.Rule()
+	s.Duplicate()
+	res := *s
+	res.actor = &auditActor{
+		id:        actor,
+		requestID: requestID,
+	}
+	//счета, операции и слияние дублей пишут журнал от имени своего хранилища
+	accounts := *s.accountRepository
+	accounts.store = &res
+	res.accountRepository = &accounts
+	transactions := *s.transactionRepository
+	transactions.store = &res
+	res.transactionRepository = &transactions
+	duplicates := *s.duplicateRepository
+	duplicates.store = &res
+	res.duplicateRepository = &duplicates
+	return &res
+}
+
 func (s *Store) User() store.UserRepo {
 	if s.userRepository == nil {
 		s.userRepository = &UserRepository{
@@ -30,6 +70,8 @@ func (s *Store) Account() store.AccountRepo {
 		s.accountRepository = &AccountRepository{
 			store:    s,
 			accounts: make(map[int]*model.Account),
+			members:  make(map[int]map[int]string),
+			lastID:   new(int),
 		}
 	}
 	return s.accountRepository
@@ -40,7 +82,77 @@ func (s *Store) Transaction() store.TransactionRepo {
 		s.transactionRepository = &TransactionRepository{
 			store:        s,
 			transactions: make(map[int]*model.TransactionDB),
+			lastID:       new(int),
 		}
 	}
 	return s.transactionRepository
 }
+
+func (s *Store) Audit() store.AuditRepo {
+	if s.auditRepository == nil {
+		s.auditRepository = &AuditRepository{
+			store: s,
+		}
+	}
+	return s.auditRepository
+}
+
+func (s *Store) IdempotencyKey() store.IdempotencyKeyRepo {
+	if s.idempotencyRepository == nil {
+		s.idempotencyRepository = &IdempotencyKeyRepository{
+			store: s,
+			keys:  make(map[int]map[string]*model.IdempotencyKey),
+		}
+	}
+	return s.idempotencyRepository
+}
+
+func (s *Store) Webhook() store.WebhookRepo {
+	if s.webhookRepository == nil {
+		s.webhookRepository = &WebhookRepository{
+			store:    s,
+			webhooks: make(map[int]*model.Webhook),
+		}
+	}
+	return s.webhookRepository
+}
+
+func (s *Store) AnomalySettings() store.AnomalySettingsRepo {
+	if s.anomalySettingsRepository == nil {
+		s.anomalySettingsRepository = &AnomalySettingsRepository{
+			store:    s,
+			settings: make(map[int]*model.AnomalySettings),
+		}
+	}
+	return s.anomalySettingsRepository
+}
+
+func (s *Store) Goal() store.GoalRepo {
+	if s.goalRepository == nil {
+		s.goalRepository = &GoalRepository{
+			store: s,
+			goals: make(map[int]*model.Goal),
+		}
+	}
+	return s.goalRepository
+}
+
+func (s *Store) Rule() store.RuleRepo {
+	if s.ruleRepository == nil {
+		s.ruleRepository = &RuleRepository{
+			store: s,
+			rules: make(map[int]*model.Rule),
+		}
+	}
+	return s.ruleRepository
+}
+
+func (s *Store) Duplicate() store.DuplicateRepo {
+	if s.duplicateRepository == nil {
+		s.duplicateRepository = &DuplicateRepository{
+			store:      s,
+			candidates: make(map[int]*duplicateCandidate),
+		}
+	}
+	return s.duplicateRepository
+}
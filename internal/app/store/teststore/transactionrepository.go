@@ -1,8 +1,10 @@
 package teststore
 
 import (
+	"sort"
 	"time"
 
+	"github.com/Aza-9798/costs-rest-api/internal/app/events"
 	"github.com/Aza-9798/costs-rest-api/internal/app/model"
 	"github.com/Aza-9798/costs-rest-api/internal/app/store"
 )
@@ -10,35 +12,154 @@ import (
 type TransactionRepository struct {
 	store        *Store
 	transactions map[int]*model.TransactionDB
+	// lastID is shared with the copies made by WithActor, so that ids are
+	// never reused after PurgeTrash, the same as with a sequence.
+	lastID *int
+}
+
+// insert stores t under a new id.
+func (r *TransactionRepository) insert(t *model.TransactionDB) {
+	*r.lastID++
+	t.ID = *r.lastID
+	t.Version = 1
+	r.transactions[t.ID] = t
 }
 
 func (r *TransactionRepository) Create(t *model.TransactionDB) error {
 	if err := t.Validate(); err != nil {
 		return err
 	}
+	if t.Source.IsArchived() || t.Destination.IsArchived() {
+		return store.ErrAccountArchived
+	}
+	if err := r.checkFunds(t.ToJSON()); err != nil {
+		return err
+	}
+	r.applyBalance(t.ToJSON(), 1)
 
-	t.ID = len(r.transactions)
-	r.transactions[t.ID] = t
+	t.Tags = model.NormalizeTags(t.Tags)
+	r.insert(t)
+	r.store.record(model.AuditEntityTransaction, t.ID, model.AuditCreate, nil, t.ToJSON())
+	r.webhooks().enqueue(events.TransactionCreated, t.ToJSON(), t.Source.ID, t.Destination.ID)
+	r.duplicates().flag(t.ToJSON())
 	return nil
 }
 
+func (r *TransactionRepository) CreateBatch(ts []*model.TransactionDB, atomic bool) ([]error, error) {
+	errs := make([]error, len(ts))
+	created := make([]*model.TransactionDB, 0, len(ts))
+	queued := len(r.webhooks().deliveries)
+	flagged := len(r.duplicates().candidates)
+	logged := len(r.audit().entries)
+	for i, t := range ts {
+		if errs[i] = r.Create(t); errs[i] == nil {
+			created = append(created, t)
+			continue
+		}
+		if atomic {
+			//id не освобождаются, как и последовательность в базе
+			for j := len(created) - 1; j >= 0; j-- {
+				r.applyBalance(created[j].ToJSON(), -1)
+				delete(r.transactions, created[j].ID)
+			}
+			r.webhooks().deliveries = r.webhooks().deliveries[:queued]
+			for id := len(r.duplicates().candidates); id > flagged; id-- {
+				delete(r.duplicates().candidates, id)
+			}
+			r.audit().entries = r.audit().entries[:logged]
+			return errs, nil
+		}
+	}
+	return errs, nil
+}
+
 func (r *TransactionRepository) Delete(t *model.TransactionJSON) error {
-	if t1, ok := r.transactions[t.ID]; !ok {
+	t1, ok := r.transactions[t.ID]
+	if !ok || t1.DeletedAt != nil {
 		return store.ErrRecordNotFound
-	} else {
-		delete(r.transactions, t1.ID)
-		return nil
 	}
+	before := t1.ToJSON()
+	now := time.Now()
+	t1.DeletedAt = &now
+	t1.Version++
+	r.applyBalance(t1.ToJSON(), -1)
+	r.store.record(model.AuditEntityTransaction, t1.ID, model.AuditDelete, before, t1.ToJSON())
+	r.webhooks().enqueue(events.TransactionDeleted, t1.ToJSON(), t1.Source.ID, t1.Destination.ID)
+	return nil
 }
 
-func (r *TransactionRepository) Save(t *model.TransactionDB) error {
+func (r *TransactionRepository) Restore(id int) error {
+	t, ok := r.transactions[id]
+	if !ok || t.DeletedAt == nil {
+		return store.ErrRecordNotFound
+	}
+	if t.Source.IsArchived() || t.Destination.IsArchived() {
+		return store.ErrAccountArchived
+	}
+	if err := r.checkFunds(t.ToJSON()); err != nil {
+		return err
+	}
+	before := t.ToJSON()
+	t.DeletedAt = nil
+	t.Version++
+	r.applyBalance(t.ToJSON(), 1)
+	r.store.record(model.AuditEntityTransaction, id, model.AuditRestore, before, t.ToJSON())
+	r.webhooks().enqueue(events.TransactionRestored, t.ToJSON(), t.Source.ID, t.Destination.ID)
+	return nil
+}
 
+func (r *TransactionRepository) PurgeTrash(before time.Time) (int, error) {
+	count := 0
+	for id, t := range r.transactions {
+		if t.DeletedAt != nil && t.DeletedAt.Before(before) {
+			delete(r.transactions, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *TransactionRepository) Save(t *model.TransactionDB) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	old, ok := r.transactions[t.ID]
+	if !ok || old.DeletedAt != nil {
+		return store.ErrRecordNotFound
+	}
+	if old.Version != t.Version {
+		return store.ErrVersionConflict
+	}
+	if old.Source.IsArchived() || old.Destination.IsArchived() ||
+		t.Source.IsArchived() || t.Destination.IsArchived() {
+		return store.ErrAccountArchived
+	}
+	r.applyBalance(old.ToJSON(), -1)
+	if err := r.checkFunds(t.ToJSON()); err != nil {
+		r.applyBalance(old.ToJSON(), 1)
+		return err
+	}
+	r.applyBalance(t.ToJSON(), 1)
+	t.Tags = model.NormalizeTags(t.Tags)
+	t.CreationDate = old.CreationDate
+	t.Version++
+	r.transactions[t.ID] = t
+	r.store.record(model.AuditEntityTransaction, t.ID, model.AuditUpdate, old.ToJSON(), t.ToJSON())
+	r.webhooks().enqueue(events.TransactionUpdated, t.ToJSON(), old.Source.ID, old.Destination.ID, t.Source.ID, t.Destination.ID)
 	return nil
 }
 
 func (r *TransactionRepository) Find(id int) (*model.TransactionJSON, error) {
 	t, ok := r.transactions[id]
-	if !ok {
+	if !ok || t.DeletedAt != nil {
+		return nil, store.ErrRecordNotFound
+	}
+	return t.ToJSON(), nil
+}
+
+func (r *TransactionRepository) FindDeleted(id int) (*model.TransactionJSON, error) {
+	t, ok := r.transactions[id]
+	if !ok || t.DeletedAt == nil {
 		return nil, store.ErrRecordNotFound
 	}
 	return t.ToJSON(), nil
@@ -47,6 +168,9 @@ func (r *TransactionRepository) Find(id int) (*model.TransactionJSON, error) {
 func (r *TransactionRepository) GetAllByAccount(accountID int) ([]*model.TransactionJSON, error) {
 	res := make([]*model.TransactionJSON, 0)
 	for _, t := range r.transactions {
+		if t.DeletedAt != nil {
+			continue
+		}
 		if t.Source.ID == accountID || t.Destination.ID == accountID {
 			res = append(res, t.ToJSON())
 		}
@@ -54,14 +178,381 @@ func (r *TransactionRepository) GetAllByAccount(accountID int) ([]*model.Transac
 	return res, nil
 }
 
-func (r *TransactionRepository) GetAllByAccountAndPeriod(userID int, DateStart, DateEnd time.Time) ([]*model.TransactionJSON, error) {
-	return nil, nil
+// hasHistory reports whether any transaction, trashed ones included, uses the account.
+func (r *TransactionRepository) hasHistory(accountID int) bool {
+	for _, t := range r.transactions {
+		if t.Source.ID == accountID || t.Destination.ID == accountID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *TransactionRepository) GetAllByAccountAndPeriod(accountID int, DateStart, DateEnd time.Time) ([]*model.TransactionJSON, error) {
+	res := make([]*model.TransactionJSON, 0)
+	for _, t := range r.transactions {
+		if t.DeletedAt != nil {
+			continue
+		}
+		if t.Source.ID != accountID && t.Destination.ID != accountID {
+			continue
+		}
+		if t.TransactionDate.Before(DateStart) || t.TransactionDate.After(DateEnd) {
+			continue
+		}
+		res = append(res, t.ToJSON())
+	}
+	return res, nil
 }
 
 func (r *TransactionRepository) GetAllByUser(userID int) ([]*model.TransactionJSON, error) {
-	return nil, nil
+	res := make([]*model.TransactionJSON, 0)
+	for _, t := range r.transactions {
+		if t.DeletedAt == nil && r.belongsUser(t, userID) {
+			res = append(res, t.ToJSON())
+		}
+	}
+	return res, nil
+}
+
+func (r *TransactionRepository) GetAllByUserAndPeriod(userID int, DateStart, DateEnd time.Time) ([]*model.TransactionJSON, error) {
+	res := make([]*model.TransactionJSON, 0)
+	for _, t := range r.transactions {
+		if t.DeletedAt != nil || !r.belongsUser(t, userID) {
+			continue
+		}
+		if t.TransactionDate.Before(DateStart) || t.TransactionDate.After(DateEnd) {
+			continue
+		}
+		res = append(res, t.ToJSON())
+	}
+	return res, nil
+}
+
+func (r *TransactionRepository) GetTrash(userID int) ([]*model.TransactionJSON, error) {
+	res := make([]*model.TransactionJSON, 0)
+	for _, t := range r.transactions {
+		if t.DeletedAt != nil && r.belongsUser(t, userID) {
+			res = append(res, t.ToJSON())
+		}
+	}
+	return res, nil
 }
 
 func (r *TransactionRepository) GetSummary(userID int, DateStart, DateEnd time.Time) (*model.Summary, error) {
-	return nil, nil
+	res := &model.Summary{
+		DateStart: DateStart,
+		DateEnd:   DateEnd,
+	}
+	for _, t := range r.transactions {
+		if t.DeletedAt != nil {
+			continue
+		}
+		if t.TransactionDate.Before(DateStart) || t.TransactionDate.After(DateEnd) {
+			continue
+		}
+		switch t.Type {
+		case model.IncomeTransaction:
+			if role, _ := r.store.Account().GetRole(t.Destination.ID, userID); role != "" {
+				res.Income += t.Amount
+			}
+		case model.ExpenseTransaction:
+			if role, _ := r.store.Account().GetRole(t.Source.ID, userID); role != "" {
+				res.Expense += t.Amount
+			}
+		}
+	}
+	return res, nil
+}
+
+func (r *TransactionRepository) GetCategoryTotals(userID int, DateStart, DateEnd time.Time) (map[int]float64, error) {
+	res := make(map[int]float64)
+	for _, t := range r.transactions {
+		if t.DeletedAt != nil {
+			continue
+		}
+		if t.TransactionDate.Before(DateStart) || t.TransactionDate.After(DateEnd) {
+			continue
+		}
+		for _, a := range []*model.Account{t.Source, t.Destination} {
+			if !a.IsCategory() {
+				continue
+			}
+			if role, _ := r.store.Account().GetRole(a.ID, userID); role != "" {
+				res[a.ID] += t.Amount
+			}
+		}
+	}
+	return res, nil
+}
+
+func (r *TransactionRepository) GetAccountFlows(accountIDs []int, DateStart, DateEnd time.Time) (*model.AccountFlows, error) {
+	ids := make(map[int]bool)
+	for _, id := range accountIDs {
+		ids[id] = true
+	}
+	res := &model.AccountFlows{}
+	for _, t := range r.transactions {
+		if t.DeletedAt != nil {
+			continue
+		}
+		if t.TransactionDate.Before(DateStart) || t.TransactionDate.After(DateEnd) {
+			continue
+		}
+		if ids[t.Destination.ID] {
+			res.Inflow += t.Amount
+		}
+		if ids[t.Source.ID] {
+			res.Outflow += t.Amount
+		}
+	}
+	return res, nil
+}
+
+func (r *TransactionRepository) GetTimeSeries(userID int, DateStart, DateEnd time.Time, interval, groupBy string) ([]*model.TimeSeriesRow, error) {
+	type key struct {
+		bucket time.Time
+		group  int
+	}
+	rows := make(map[key]*model.TimeSeriesRow)
+	for _, t := range r.transactions {
+		if t.DeletedAt != nil {
+			continue
+		}
+		if t.TransactionDate.Before(DateStart) || t.TransactionDate.After(DateEnd) {
+			continue
+		}
+		var own, other *model.Account
+		switch t.Type {
+		case model.IncomeTransaction:
+			own, other = t.Destination, t.Source
+		case model.ExpenseTransaction:
+			own, other = t.Source, t.Destination
+		default:
+			continue
+		}
+		//по категориям считаются итоги самих категорий, как в GetCategoryTotals
+		visible := own
+		if groupBy == model.GroupByCategory {
+			visible = other
+		}
+		if role, _ := r.store.Account().GetRole(visible.ID, userID); role == "" {
+			continue
+		}
+		k := key{bucket: model.TruncateDate(t.TransactionDate, interval)}
+		switch groupBy {
+		case model.GroupByAccount:
+			k.group = own.ID
+		case model.GroupByCategory:
+			k.group = other.ID
+		}
+		row, ok := rows[k]
+		if !ok {
+			row = &model.TimeSeriesRow{Bucket: k.bucket, Group: k.group}
+			rows[k] = row
+		}
+		if t.Type == model.IncomeTransaction {
+			row.Income += t.Amount
+		} else {
+			row.Expense += t.Amount
+		}
+	}
+	res := make([]*model.TimeSeriesRow, 0, len(rows))
+	for _, row := range rows {
+		res = append(res, row)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].Bucket.Equal(res[j].Bucket) {
+			return res[i].Bucket.Before(res[j].Bucket)
+		}
+		return res[i].Group < res[j].Group
+	})
+	return res, nil
+}
+
+func (r *TransactionRepository) GetBalanceChanges(userID int, since time.Time, interval string) ([]*model.BalanceChange, error) {
+	type key struct {
+		account int
+		bucket  time.Time
+	}
+	changes := make(map[key]float64)
+	add := func(a *model.Account, bucket time.Time, amount float64) {
+		if role, _ := r.store.Account().GetRole(a.ID, userID); role != "" {
+			changes[key{a.ID, bucket}] += amount
+		}
+	}
+	for _, t := range r.transactions {
+		if t.DeletedAt != nil || t.TransactionDate.Before(since) {
+			continue
+		}
+		bucket := model.TruncateDate(t.TransactionDate, interval)
+		if t.Type == model.StandardTransaction || t.Type == model.ExpenseTransaction {
+			add(t.Source, bucket, -t.Amount)
+		}
+		if t.Type == model.IncomeTransaction || t.Type == model.StandardTransaction {
+			add(t.Destination, bucket, t.Amount)
+		}
+	}
+	res := make([]*model.BalanceChange, 0, len(changes))
+	for k, amount := range changes {
+		res = append(res, &model.BalanceChange{Account: k.account, Bucket: k.bucket, Amount: amount})
+	}
+	return res, nil
+}
+
+func (r *TransactionRepository) GetCategoryComparison(userID int, DateStart, DateEnd, prevStart time.Time) ([]*model.CategoryComparison, error) {
+	totals := make(map[int]*model.CategoryComparison)
+	for _, t := range r.transactions {
+		if t.DeletedAt != nil {
+			continue
+		}
+		if t.TransactionDate.Before(prevStart) || t.TransactionDate.After(DateEnd) {
+			continue
+		}
+		//доходы считаются по источнику, расходы по получателю
+		for _, a := range []*model.Account{t.Source, t.Destination} {
+			if a == t.Source && a.Type != model.IncomeSourceAccount ||
+				a == t.Destination && a.Type != model.ExpenseCatogoryAccount {
+				continue
+			}
+			if role, _ := r.store.Account().GetRole(a.ID, userID); role == "" {
+				continue
+			}
+			c, ok := totals[a.ID]
+			if !ok {
+				c = &model.CategoryComparison{Account: a.ID}
+				totals[a.ID] = c
+			}
+			if t.TransactionDate.Before(DateStart) {
+				c.Previous += t.Amount
+			} else {
+				c.Current += t.Amount
+			}
+		}
+	}
+	res := make([]*model.CategoryComparison, 0, len(totals))
+	for _, c := range totals {
+		res = append(res, c)
+	}
+	return res, nil
+}
+
+func (r *TransactionRepository) AccrueInterest(accountID int, through time.Time) ([]*model.TransactionDB, error) {
+	a, err := r.store.Account().Find(accountID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*model.TransactionDB, 0)
+	var category *model.Account
+	month := model.MonthEnd(a.InterestAccruedThrough.AddDate(0, 0, 1))
+	for ; !month.After(through); month = model.MonthEnd(month.AddDate(0, 0, 1)) {
+		//баланс на конец месяца: без операций после него, но с процентами прошлых месяцев
+		balance := a.Balance
+		for _, t := range r.transactions {
+			if t.DeletedAt == nil && model.TruncateDate(t.TransactionDate, model.IntervalDay).After(month) {
+				balance -= t.ToJSON().BalanceEffect(a.ID)
+			}
+		}
+		interest := a.MonthlyInterest(balance)
+		if interest <= 0 {
+			continue
+		}
+		if category == nil {
+			if category, err = r.interestCategory(a.User); err != nil {
+				return nil, err
+			}
+		}
+		t := &model.TransactionDB{
+			TransactionDate: month.AddDate(0, 0, 1),
+			Source:          a,
+			Destination:     category,
+			Amount:          interest,
+			Type:            model.ExpenseTransaction,
+			Description:     model.InterestDescription(month),
+		}
+		if err := t.Validate(); err != nil {
+			return nil, err
+		}
+		r.applyBalance(t.ToJSON(), 1)
+		r.insert(t)
+		r.store.record(model.AuditEntityTransaction, t.ID, model.AuditCreate, nil, t.ToJSON())
+		r.webhooks().enqueue(events.TransactionCreated, t.ToJSON(), t.Source.ID, t.Destination.ID)
+		res = append(res, t)
+	}
+	a.InterestAccruedThrough = through
+	return res, nil
+}
+
+func (r *TransactionRepository) interestCategory(userID int) (*model.Account, error) {
+	accounts, err := r.store.Account().GetAllByUser(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	var res *model.Account
+	for _, a := range accounts {
+		if a.User == userID && a.Type == model.ExpenseCatogoryAccount && a.Name == model.InterestCategoryName {
+			if res == nil || a.ID < res.ID {
+				res = a
+			}
+		}
+	}
+	if res != nil {
+		return res, nil
+	}
+	res = &model.Account{
+		User: userID,
+		Name: model.InterestCategoryName,
+		Type: model.ExpenseCatogoryAccount,
+	}
+	return res, r.store.Account().Create(res)
+}
+
+func (r *TransactionRepository) audit() *AuditRepository {
+	return r.store.Audit().(*AuditRepository)
+}
+
+func (r *TransactionRepository) webhooks() *WebhookRepository {
+	return r.store.Webhook().(*WebhookRepository)
+}
+
+func (r *TransactionRepository) duplicates() *DuplicateRepository {
+	return r.store.Duplicate().(*DuplicateRepository)
+}
+
+func (r *TransactionRepository) belongsUser(t *model.TransactionDB, userID int) bool {
+	if role, _ := r.store.Account().GetRole(t.Source.ID, userID); role != "" {
+		return true
+	}
+	role, _ := r.store.Account().GetRole(t.Destination.ID, userID)
+	return role != ""
+}
+
+func (r *TransactionRepository) checkFunds(t *model.TransactionJSON) error {
+	if t.Type != model.StandardTransaction && t.Type != model.ExpenseTransaction {
+		return nil
+	}
+	a, err := r.store.Account().Find(t.Source)
+	if err != nil {
+		return err
+	}
+	if a.Available() >= t.Amount {
+		return nil
+	}
+	if a.IsLiability() {
+		return store.ErrCreditLimitExceeded
+	}
+	return store.ErrInsufficientFunds
+}
+
+func (r *TransactionRepository) applyBalance(t *model.TransactionJSON, sign float64) {
+	if t.Type == model.StandardTransaction || t.Type == model.ExpenseTransaction {
+		if a, err := r.store.Account().Find(t.Source); err == nil {
+			a.Balance -= sign * t.Amount
+		}
+	}
+	if t.Type == model.IncomeTransaction || t.Type == model.StandardTransaction {
+		if a, err := r.store.Account().Find(t.Destination); err == nil {
+			a.Balance += sign * t.Amount
+		}
+	}
 }
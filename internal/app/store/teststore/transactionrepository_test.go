@@ -0,0 +1,420 @@
+package teststore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store/teststore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionRepository_DeleteRestore(t *testing.T) {
+	s := teststore.New()
+	u := model.TestUser(t)
+	s.User().Create(u)
+	card := model.TestAccount(t, u)
+	s.Account().Create(card)
+	food := model.TestAccount(t, u)
+	food.Type = model.ExpenseCatogoryAccount
+	food.Balance = 0
+	s.Account().Create(food)
+
+	tr := &model.TransactionDB{
+		TransactionDate: time.Now(),
+		Source:          card,
+		Destination:     food,
+		Amount:          30,
+		Type:            model.ExpenseTransaction,
+	}
+	assert.NoError(t, s.Transaction().Create(tr))
+	assert.Equal(t, 70.0, card.Balance)
+
+	tJSON, err := s.Transaction().Find(tr.ID)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Transaction().Delete(tJSON))
+	assert.Equal(t, 100.0, card.Balance)
+	assert.Equal(t, 0.0, food.Balance)
+
+	_, err = s.Transaction().Find(tr.ID)
+	assert.EqualError(t, err, store.ErrRecordNotFound.Error())
+	trash, err := s.Transaction().GetTrash(u.ID)
+	assert.NoError(t, err)
+	assert.Len(t, trash, 1)
+
+	assert.NoError(t, s.Transaction().Restore(tr.ID))
+	assert.Equal(t, 70.0, card.Balance)
+	assert.EqualError(t, s.Transaction().Restore(tr.ID), store.ErrRecordNotFound.Error())
+
+	assert.NoError(t, s.Transaction().Delete(tJSON))
+	count, err := s.Transaction().PurgeTrash(time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	_, err = s.Transaction().FindDeleted(tr.ID)
+	assert.EqualError(t, err, store.ErrRecordNotFound.Error())
+
+	//id вычищенной операции не достается новой
+	kept := &model.TransactionDB{
+		TransactionDate: time.Now(),
+		Source:          card,
+		Destination:     food,
+		Amount:          10,
+		Type:            model.ExpenseTransaction,
+	}
+	assert.NoError(t, s.Transaction().Create(kept))
+	next := &model.TransactionDB{
+		TransactionDate: time.Now(),
+		Source:          card,
+		Destination:     food,
+		Amount:          20,
+		Type:            model.ExpenseTransaction,
+	}
+	assert.NoError(t, s.Transaction().Create(next))
+	assert.NotEqual(t, tr.ID, kept.ID)
+	assert.NotEqual(t, kept.ID, next.ID)
+	tJSON, err = s.Transaction().Find(kept.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 10.0, tJSON.Amount)
+}
+
+func TestTransactionRepository_CreateBatch(t *testing.T) {
+	s := teststore.New()
+	u := model.TestUser(t)
+	s.User().Create(u)
+	card := model.TestAccount(t, u)
+	s.Account().Create(card)
+	food := model.TestAccount(t, u)
+	food.Type = model.ExpenseCatogoryAccount
+	food.Balance = 0
+	s.Account().Create(food)
+	batch := func(amounts ...float64) []*model.TransactionDB {
+		res := make([]*model.TransactionDB, 0, len(amounts))
+		for _, a := range amounts {
+			res = append(res, &model.TransactionDB{
+				TransactionDate: time.Now(),
+				Source:          card,
+				Destination:     food,
+				Amount:          a,
+				Type:            model.ExpenseTransaction,
+			})
+		}
+		return res
+	}
+
+	errs, err := s.Transaction().CreateBatch(batch(30, 80), true)
+	assert.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assert.Equal(t, store.ErrInsufficientFunds, errs[1])
+	assert.Equal(t, 100.0, card.Balance)
+	ts, _ := s.Transaction().GetAllByAccount(card.ID)
+	assert.Len(t, ts, 0)
+
+	errs, err = s.Transaction().CreateBatch(batch(30, 80, 20), false)
+	assert.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assert.Equal(t, store.ErrInsufficientFunds, errs[1])
+	assert.NoError(t, errs[2])
+	assert.Equal(t, 50.0, card.Balance)
+	ts, _ = s.Transaction().GetAllByAccount(card.ID)
+	assert.Len(t, ts, 2)
+}
+
+func TestTransactionRepository_GetTimeSeries(t *testing.T) {
+	s := teststore.New()
+	u := model.TestUser(t)
+	s.User().Create(u)
+	card := model.TestAccount(t, u)
+	s.Account().Create(card)
+	salary := model.TestAccount(t, u)
+	salary.Type = model.IncomeSourceAccount
+	salary.Balance = 0
+	s.Account().Create(salary)
+	food := model.TestAccount(t, u)
+	food.Type = model.ExpenseCatogoryAccount
+	food.Balance = 0
+	s.Account().Create(food)
+
+	create := func(date time.Time, src, dst *model.Account, amount float64, typ string) {
+		t.Helper()
+		assert.NoError(t, s.Transaction().Create(&model.TransactionDB{
+			TransactionDate: date,
+			Source:          src,
+			Destination:     dst,
+			Amount:          amount,
+			Type:            typ,
+		}))
+	}
+	create(time.Date(2023, time.January, 5, 0, 0, 0, 0, time.UTC), salary, card, 500, model.IncomeTransaction)
+	create(time.Date(2023, time.January, 20, 0, 0, 0, 0, time.UTC), card, food, 40, model.ExpenseTransaction)
+	create(time.Date(2023, time.February, 3, 0, 0, 0, 0, time.UTC), card, food, 60, model.ExpenseTransaction)
+
+	from := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, time.March, 31, 0, 0, 0, 0, time.UTC)
+	rows, err := s.Transaction().GetTimeSeries(u.ID, from, to, model.IntervalMonth, "")
+	assert.NoError(t, err)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, 500.0, rows[0].Income)
+	assert.Equal(t, 40.0, rows[0].Expense)
+	assert.Equal(t, 60.0, rows[1].Expense)
+
+	rows, err = s.Transaction().GetTimeSeries(u.ID, from, to, model.IntervalMonth, model.GroupByCategory)
+	assert.NoError(t, err)
+	assert.Len(t, rows, 3)
+	assert.Equal(t, salary.ID, rows[0].Group)
+	assert.Equal(t, food.ID, rows[1].Group)
+
+	rows, err = s.Transaction().GetTimeSeries(u.ID+1, from, to, model.IntervalMonth, "")
+	assert.NoError(t, err)
+	assert.Empty(t, rows)
+}
+
+func TestTransactionRepository_GetCategoryComparison(t *testing.T) {
+	s := teststore.New()
+	u := model.TestUser(t)
+	s.User().Create(u)
+	card := model.TestAccount(t, u)
+	s.Account().Create(card)
+	salary := model.TestAccount(t, u)
+	salary.Type = model.IncomeSourceAccount
+	salary.Balance = 0
+	s.Account().Create(salary)
+	food := model.TestAccount(t, u)
+	food.Type = model.ExpenseCatogoryAccount
+	food.Balance = 0
+	s.Account().Create(food)
+
+	for _, tr := range []*model.TransactionDB{
+		{TransactionDate: time.Date(2023, time.February, 5, 0, 0, 0, 0, time.UTC), Source: salary, Destination: card, Amount: 500, Type: model.IncomeTransaction},
+		{TransactionDate: time.Date(2023, time.February, 20, 0, 0, 0, 0, time.UTC), Source: card, Destination: food, Amount: 20, Type: model.ExpenseTransaction},
+		{TransactionDate: time.Date(2023, time.March, 5, 0, 0, 0, 0, time.UTC), Source: card, Destination: food, Amount: 30, Type: model.ExpenseTransaction},
+	} {
+		assert.NoError(t, s.Transaction().Create(tr))
+	}
+
+	res, err := s.Transaction().GetCategoryComparison(
+		u.ID,
+		time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, time.March, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC),
+	)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []*model.CategoryComparison{
+		{Account: salary.ID, Current: 0, Previous: 500},
+		{Account: food.ID, Current: 30, Previous: 20},
+	}, res)
+}
+
+func TestTransactionRepository_GetBalanceChanges(t *testing.T) {
+	s := teststore.New()
+	u := model.TestUser(t)
+	s.User().Create(u)
+	card := model.TestAccount(t, u)
+	s.Account().Create(card)
+	saving := model.TestAccount(t, u)
+	saving.Type = model.SavingAccount
+	saving.Balance = 0
+	s.Account().Create(saving)
+	food := model.TestAccount(t, u)
+	food.Type = model.ExpenseCatogoryAccount
+	food.Balance = 0
+	s.Account().Create(food)
+
+	jan := time.Date(2023, time.January, 10, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2023, time.February, 10, 0, 0, 0, 0, time.UTC)
+	for _, tr := range []*model.TransactionDB{
+		{TransactionDate: jan, Source: card, Destination: saving, Amount: 30, Type: model.StandardTransaction},
+		{TransactionDate: feb, Source: card, Destination: food, Amount: 20, Type: model.ExpenseTransaction},
+	} {
+		assert.NoError(t, s.Transaction().Create(tr))
+	}
+
+	changes, err := s.Transaction().GetBalanceChanges(u.ID, time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC), model.IntervalMonth)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 1)
+	assert.Equal(t, card.ID, changes[0].Account)
+	assert.Equal(t, -20.0, changes[0].Amount)
+}
+
+func TestTransactionRepository_GetAccountFlows(t *testing.T) {
+	s := teststore.New()
+	u := model.TestUser(t)
+	s.User().Create(u)
+	card := model.TestAccount(t, u)
+	s.Account().Create(card)
+	saving := model.TestAccount(t, u)
+	saving.Type = model.SavingAccount
+	s.Account().Create(saving)
+
+	day := time.Date(2023, time.May, 10, 0, 0, 0, 0, time.UTC)
+	for _, tr := range []*model.TransactionDB{
+		{TransactionDate: day, Source: card, Destination: saving, Amount: 30, Type: model.StandardTransaction},
+		{TransactionDate: day, Source: saving, Destination: card, Amount: 10, Type: model.StandardTransaction},
+		{TransactionDate: day.AddDate(0, 1, 0), Source: card, Destination: saving, Amount: 50, Type: model.StandardTransaction},
+	} {
+		assert.NoError(t, s.Transaction().Create(tr))
+	}
+
+	flows, err := s.Transaction().GetAccountFlows([]int{card.ID}, day, day.AddDate(0, 0, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, &model.AccountFlows{Inflow: 10, Outflow: 30}, flows)
+}
+
+func TestTransactionRepository_Debt(t *testing.T) {
+	s := teststore.New()
+	u := model.TestUser(t)
+	s.User().Create(u)
+	card := model.TestAccount(t, u)
+	card.Type = model.DebtAccount
+	card.Balance = 0
+	card.CreditLimit = 100
+	card.APR = 24
+	assert.NoError(t, s.Account().Create(card))
+	food := model.TestAccount(t, u)
+	food.Type = model.ExpenseCatogoryAccount
+	food.Balance = 0
+	s.Account().Create(food)
+
+	expense := func(amount float64) error {
+		return s.Transaction().Create(&model.TransactionDB{
+			TransactionDate: time.Now(),
+			Source:          card,
+			Destination:     food,
+			Amount:          amount,
+			Type:            model.ExpenseTransaction,
+		})
+	}
+	assert.NoError(t, expense(80))
+	assert.Equal(t, store.ErrCreditLimitExceeded, expense(30))
+
+	through := card.InterestAccruedThrough.AddDate(0, 2, 0)
+	ts, err := s.Transaction().AccrueInterest(card.ID, model.MonthEnd(through))
+	assert.NoError(t, err)
+	assert.Len(t, ts, 2)
+	assert.Equal(t, 1.6, ts[0].Amount)
+	assert.Equal(t, model.InterestCategoryName, ts[0].Destination.Name)
+	assert.Equal(t, ts[0].Destination, ts[1].Destination)
+	assert.InDelta(t, -83.23, card.Balance, 0.001)
+
+	due, _ := s.Account().GetInterestDue(model.MonthEnd(through))
+	assert.Empty(t, due)
+}
+
+func TestTransactionRepository_AccrueInterestMonthEnd(t *testing.T) {
+	s := teststore.New()
+	u := model.TestUser(t)
+	s.User().Create(u)
+	cash := model.TestAccount(t, u)
+	cash.Balance = 1000
+	s.Account().Create(cash)
+	card := model.TestAccount(t, u)
+	card.Type = model.DebtAccount
+	card.Balance = -1000
+	card.CreditLimit = 2000
+	card.APR = 12
+	assert.NoError(t, s.Account().Create(card))
+	through := card.InterestAccruedThrough
+	card.InterestAccruedThrough = model.MonthEnd(through.AddDate(0, -2, 0))
+
+	//платёж в последний день второго месяца не уменьшает проценты первого
+	assert.NoError(t, s.Transaction().Create(&model.TransactionDB{
+		TransactionDate: through,
+		Source:          cash,
+		Destination:     card,
+		Amount:          500,
+		Type:            model.StandardTransaction,
+	}))
+	ts, err := s.Transaction().AccrueInterest(card.ID, through)
+	assert.NoError(t, err)
+	assert.Len(t, ts, 2)
+	assert.Equal(t, 10.0, ts[0].Amount)
+	assert.Equal(t, 5.1, ts[1].Amount)
+	assert.InDelta(t, -515.1, card.Balance, 0.001)
+}
+
+func TestTransactionRepository_FlagDuplicates(t *testing.T) {
+	s := teststore.New()
+	u := model.TestUser(t)
+	s.User().Create(u)
+	card := model.TestAccount(t, u)
+	s.Account().Create(card)
+	food := model.TestAccount(t, u)
+	food.Type = model.ExpenseCatogoryAccount
+	food.Balance = 0
+	s.Account().Create(food)
+	coffee := func(description string) *model.TransactionDB {
+		return &model.TransactionDB{
+			TransactionDate: time.Now(),
+			Source:          card,
+			Destination:     food,
+			Amount:          4.5,
+			Type:            model.ExpenseTransaction,
+			Description:     description,
+		}
+	}
+
+	first := coffee("Coffee")
+	assert.NoError(t, s.Transaction().Create(first))
+	second := coffee("COFFEE SHOP")
+	assert.NoError(t, s.Transaction().Create(second))
+	assert.NoError(t, s.Transaction().Create(coffee("Bus ticket")))
+
+	pending, err := s.Duplicate().GetPending(u.ID)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, second.ID, pending[0].Transaction.ID)
+	assert.Equal(t, first.ID, pending[0].DuplicateOf.ID)
+
+	//атомарный пакет откатывает и отмеченные дубликаты
+	invalid := coffee("Coffee")
+	invalid.Amount = 0
+	errs, _ := s.Transaction().CreateBatch([]*model.TransactionDB{coffee("Coffee"), invalid}, true)
+	assert.Error(t, errs[1])
+	pending, _ = s.Duplicate().GetPending(u.ID)
+	assert.Len(t, pending, 1)
+
+	assert.NoError(t, s.Duplicate().Resolve(pending[0].ID, model.DuplicateDismissed))
+	assert.Equal(t, store.ErrDuplicateResolved, s.Duplicate().Resolve(pending[0].ID, model.DuplicateMerged))
+	pending, _ = s.Duplicate().GetPending(u.ID)
+	assert.Empty(t, pending)
+}
+
+func TestDuplicateRepository_Merge(t *testing.T) {
+	ts := teststore.New()
+	u := model.TestUser(t)
+	ts.User().Create(u)
+	s := ts.WithActor(u.ID, "request-1")
+	card := model.TestAccount(t, u)
+	s.Account().Create(card)
+	food := model.TestAccount(t, u)
+	food.Type = model.ExpenseCatogoryAccount
+	food.Balance = 0
+	s.Account().Create(food)
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, s.Transaction().Create(&model.TransactionDB{
+			TransactionDate: time.Now(),
+			Source:          card,
+			Destination:     food,
+			Amount:          5,
+			Type:            model.ExpenseTransaction,
+			Description:     "Coffee",
+		}))
+	}
+	pending, _ := s.Duplicate().GetPending(u.ID)
+	assert.Len(t, pending, 1)
+	d := pending[0]
+
+	assert.NoError(t, s.Duplicate().Merge(d.ID, d.Transaction.ID))
+	assert.Equal(t, 95.0, card.Balance)
+	history, _ := ts.Audit().GetByEntity(model.AuditEntityTransaction, d.Transaction.ID)
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, model.AuditDelete, history[1].Operation)
+		assert.Equal(t, u.ID, history[1].Actor)
+	}
+
+	//закрытую пару нельзя слить ещё раз, второй перевод остаётся
+	assert.Equal(t, store.ErrDuplicateResolved, s.Duplicate().Merge(d.ID, d.DuplicateOf.ID))
+	_, err := s.Transaction().Find(d.DuplicateOf.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 95.0, card.Balance)
+}
@@ -27,6 +27,17 @@ func (ur *UserRepository) Create(u *model.User) error {
 	return nil
 }
 
+func (ur *UserRepository) Save(u *model.User) error {
+	if err := u.Validate(); err != nil {
+		return err
+	}
+	if _, ok := ur.users[u.ID]; !ok {
+		return store.ErrRecordNotFound
+	}
+	ur.users[u.ID] = u
+	return nil
+}
+
 func (ur *UserRepository) Find(id int) (*model.User, error) {
 	u, ok := ur.users[id]
 	if !ok {
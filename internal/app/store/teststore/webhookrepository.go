@@ -0,0 +1,127 @@
+package teststore
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+)
+
+type WebhookRepository struct {
+	store      *Store
+	webhooks   map[int]*model.Webhook
+	deliveries []*model.WebhookDelivery
+}
+
+func (r *WebhookRepository) Create(w *model.Webhook) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	w.ID = len(r.webhooks) + 1
+	w.CreatedAt = time.Now()
+	r.webhooks[w.ID] = w
+	return nil
+}
+
+func (r *WebhookRepository) Delete(id int) error {
+	if _, ok := r.webhooks[id]; !ok {
+		return store.ErrRecordNotFound
+	}
+	delete(r.webhooks, id)
+	deliveries := r.deliveries[:0]
+	for _, d := range r.deliveries {
+		if d.WebhookID != id {
+			deliveries = append(deliveries, d)
+		}
+	}
+	r.deliveries = deliveries
+	return nil
+}
+
+func (r *WebhookRepository) Find(id int) (*model.Webhook, error) {
+	w, ok := r.webhooks[id]
+	if !ok {
+		return nil, store.ErrRecordNotFound
+	}
+	return w, nil
+}
+
+func (r *WebhookRepository) GetAllByUser(userID int) ([]*model.Webhook, error) {
+	res := make([]*model.Webhook, 0)
+	for _, w := range r.webhooks {
+		if w.User == userID {
+			res = append(res, w)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res, nil
+}
+
+func (r *WebhookRepository) GetDeliveries(webhookID int) ([]*model.WebhookDelivery, error) {
+	res := make([]*model.WebhookDelivery, 0)
+	for i := len(r.deliveries) - 1; i >= 0; i-- {
+		if r.deliveries[i].WebhookID == webhookID {
+			res = append(res, r.deliveries[i])
+		}
+	}
+	return res, nil
+}
+
+func (r *WebhookRepository) ClaimDeliveries(now, lease time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	res := make([]*model.WebhookDelivery, 0)
+	for _, d := range r.deliveries {
+		if len(res) == limit {
+			break
+		}
+		if d.Status == model.DeliveryPending && !d.NextAttemptAt.After(now) {
+			d.NextAttemptAt = lease
+			res = append(res, d)
+		}
+	}
+	return res, nil
+}
+
+func (r *WebhookRepository) SaveDelivery(d *model.WebhookDelivery) error {
+	for i, d1 := range r.deliveries {
+		if d1.ID == d.ID {
+			r.deliveries[i] = d
+			return nil
+		}
+	}
+	return store.ErrRecordNotFound
+}
+
+// enqueue mirrors enqueueWebhooks of the sql store.
+func (r *WebhookRepository) enqueue(event string, data interface{}, accountIDs ...int) {
+	users := make(map[int]bool)
+	for _, id := range accountIDs {
+		if a, err := r.store.Account().Find(id); err == nil {
+			users[a.User] = true
+		}
+		members, _ := r.store.Account().GetMembers(id)
+		for _, m := range members {
+			users[m.UserID] = true
+		}
+	}
+	payload, err := json.Marshal(&model.WebhookPayload{Event: event, Data: data})
+	if err != nil {
+		return
+	}
+	for _, w := range r.webhooks {
+		if !users[w.User] || !w.Subscribed(event) {
+			continue
+		}
+		now := time.Now()
+		r.deliveries = append(r.deliveries, &model.WebhookDelivery{
+			ID:            len(r.deliveries) + 1,
+			WebhookID:     w.ID,
+			Event:         event,
+			Payload:       payload,
+			Status:        model.DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+	}
+}
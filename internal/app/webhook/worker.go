@@ -0,0 +1,163 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// Worker sends queued deliveries and retries failed ones with exponential backoff.
+type Worker struct {
+	store  store.Store
+	client *http.Client
+	logger *logrus.Logger
+
+	BatchSize   int
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	// Lease is how long a claimed delivery is hidden from other workers.
+	Lease time.Duration
+}
+
+func NewWorker(s store.Store, logger *logrus.Logger) *Worker {
+	return &Worker{
+		store:       s,
+		client:      newClient(),
+		logger:      logger,
+		BatchSize:   20,
+		MaxAttempts: 8,
+		Backoff:     30 * time.Second,
+		MaxBackoff:  6 * time.Hour,
+		Lease:       time.Minute,
+	}
+}
+
+// Run sends due deliveries every interval.
+func (w *Worker) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for ; true; <-ticker.C {
+		if _, err := w.SendDue(time.Now()); err != nil {
+			w.logger.Errorf("send webhooks: %v", err)
+		}
+	}
+}
+
+// SendDue sends the deliveries due at now and returns how many were attempted.
+func (w *Worker) SendDue(now time.Time) (int, error) {
+	deliveries, err := w.store.Webhook().ClaimDeliveries(now, now.Add(w.Lease), w.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for _, d := range deliveries {
+		w.send(d, now)
+		if err := w.store.Webhook().SaveDelivery(d); err != nil {
+			w.logger.Errorf("save webhook delivery %d: %v", d.ID, err)
+		}
+	}
+	return len(deliveries), nil
+}
+
+// newClient returns a client that refuses to connect to addresses webhooks
+// must not reach. The check runs on the resolved address, so host names that
+// resolve or redirect to a private network are rejected too.
+func newClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !model.IsPublicIP(ip) {
+				return model.ErrWebhookPrivateAddress
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+	}
+}
+
+func (w *Worker) send(d *model.WebhookDelivery, now time.Time) {
+	d.Attempts++
+	hook, err := w.store.Webhook().Find(d.WebhookID)
+	if err != nil {
+		w.fail(d, now, err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		w.fail(d, now, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, d.Event)
+	req.Header.Set(DeliveryHeader, fmt.Sprint(d.ID))
+	req.Header.Set(SignatureHeader, SignatureHeaderValue(hook.Secret, now.Unix(), d.Payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		w.fail(d, now, err)
+		return
+	}
+	resp.Body.Close()
+	d.LastStatusCode = &resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		w.fail(d, now, fmt.Errorf("endpoint responded with %d", resp.StatusCode))
+		return
+	}
+	d.Status = model.DeliveryDelivered
+	d.LastError = ""
+	d.DeliveredAt = &now
+}
+
+// fail schedules the next attempt or gives up after MaxAttempts.
+func (w *Worker) fail(d *model.WebhookDelivery, now time.Time, err error) {
+	d.LastError = err.Error()
+	if d.Attempts >= w.MaxAttempts {
+		d.Status = model.DeliveryFailed
+		return
+	}
+	backoff := w.Backoff << uint(d.Attempts-1)
+	if backoff <= 0 || backoff > w.MaxBackoff {
+		backoff = w.MaxBackoff
+	}
+	d.NextAttemptAt = now.Add(backoff)
+}
+
+// Sign returns the hex HMAC-SHA256 of "timestamp.payload" under secret.
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignatureHeaderValue formats the signature header as "t=<timestamp>,v1=<signature>".
+// Receivers should recompute Sign and reject old timestamps to prevent replays.
+func SignatureHeaderValue(secret string, timestamp int64, payload []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, Sign(secret, timestamp, payload))
+}
@@ -0,0 +1,120 @@
+package webhook_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Aza-9798/costs-rest-api/internal/app/events"
+	"github.com/Aza-9798/costs-rest-api/internal/app/model"
+	"github.com/Aza-9798/costs-rest-api/internal/app/store/teststore"
+	"github.com/Aza-9798/costs-rest-api/internal/app/webhook"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorker_SendDue(t *testing.T) {
+	status := http.StatusInternalServerError
+	var body []byte
+	var signature string
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(webhook.SignatureHeader)
+		w.WriteHeader(status)
+	}))
+	defer endpoint.Close()
+
+	s := teststore.New()
+	u := model.TestUser(t)
+	s.User().Create(u)
+	card := model.TestAccount(t, u)
+	s.Account().Create(card)
+	food := model.TestAccount(t, u)
+	food.Type = model.ExpenseCatogoryAccount
+	food.Balance = 0
+	s.Account().Create(food)
+	hook := &model.Webhook{
+		User:   u.ID,
+		URL:    "https://example.org/hook",
+		Secret: "secret",
+		Events: []string{events.TransactionCreated},
+	}
+	assert.NoError(t, s.Webhook().Create(hook))
+	//адрес на loopback не пройдёт проверку при регистрации
+	hook.URL = endpoint.URL
+	assert.NoError(t, s.Transaction().Create(&model.TransactionDB{
+		TransactionDate: time.Now(),
+		Source:          card,
+		Destination:     food,
+		Amount:          10,
+		Type:            model.ExpenseTransaction,
+	}))
+
+	w := webhook.NewWorker(s, logrus.New())
+	w.AllowPrivateAddresses()
+	w.MaxAttempts = 2
+	now := time.Now()
+	n, err := w.SendDue(now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	deliveries, _ := s.Webhook().GetDeliveries(hook.ID)
+	d := deliveries[0]
+	assert.Equal(t, model.DeliveryPending, d.Status)
+	assert.Equal(t, 1, d.Attempts)
+	assert.Equal(t, now.Add(w.Backoff), d.NextAttemptAt)
+	assert.Equal(t, webhook.SignatureHeaderValue("secret", now.Unix(), body), signature)
+
+	//до следующей попытки ничего не отправляется
+	n, _ = w.SendDue(now.Add(time.Second))
+	assert.Equal(t, 0, n)
+
+	status = http.StatusNoContent
+	n, _ = w.SendDue(d.NextAttemptAt)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, model.DeliveryDelivered, d.Status)
+	assert.NotNil(t, d.DeliveredAt)
+}
+
+func TestWorker_RejectsPrivateAddresses(t *testing.T) {
+	called := false
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer endpoint.Close()
+
+	s := teststore.New()
+	u := model.TestUser(t)
+	s.User().Create(u)
+	card := model.TestAccount(t, u)
+	s.Account().Create(card)
+	food := model.TestAccount(t, u)
+	food.Type = model.ExpenseCatogoryAccount
+	food.Balance = 0
+	s.Account().Create(food)
+	hook := &model.Webhook{
+		User:   u.ID,
+		URL:    "https://example.org/hook",
+		Secret: "secret",
+		Events: []string{events.TransactionCreated},
+	}
+	assert.NoError(t, s.Webhook().Create(hook))
+	//имя хоста может указывать на внутренний адрес уже после регистрации
+	hook.URL = endpoint.URL
+	assert.NoError(t, s.Transaction().Create(&model.TransactionDB{
+		TransactionDate: time.Now(),
+		Source:          card,
+		Destination:     food,
+		Amount:          10,
+		Type:            model.ExpenseTransaction,
+	}))
+
+	n, err := webhook.NewWorker(s, logrus.New()).SendDue(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.False(t, called)
+	deliveries, _ := s.Webhook().GetDeliveries(hook.ID)
+	assert.Equal(t, model.DeliveryPending, deliveries[0].Status)
+	assert.Contains(t, deliveries[0].LastError, model.ErrWebhookPrivateAddress.Error())
+}
@@ -0,0 +1,6 @@
+create table account_members (
+    account_id bigint not null references accounts(id) on delete cascade,
+    user_id bigint not null references users(id),
+    role varchar not null,
+    primary key (account_id, user_id)
+);
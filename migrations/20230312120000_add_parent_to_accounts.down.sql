@@ -0,0 +1,2 @@
+alter table accounts
+drop column parent_id;
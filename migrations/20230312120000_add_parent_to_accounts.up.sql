@@ -0,0 +1,2 @@
+alter table accounts
+add column parent_id bigint references accounts(id) on delete set null;
@@ -0,0 +1,2 @@
+alter table accounts
+drop column archived_at;
@@ -0,0 +1,2 @@
+alter table accounts
+add column archived_at timestamp;
@@ -0,0 +1,4 @@
+drop index transactions_deleted_at_idx;
+
+alter table transactions
+drop column deleted_at;
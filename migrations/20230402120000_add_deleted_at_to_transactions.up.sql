@@ -0,0 +1,5 @@
+alter table transactions
+add column deleted_at timestamp;
+
+create index transactions_deleted_at_idx on transactions (deleted_at)
+    where deleted_at is not null;
@@ -0,0 +1,16 @@
+create table audit_log (
+    id bigserial not null primary key,
+    created_at timestamp not null default now(),
+    actor_id bigint not null references users(id),
+    request_id varchar not null,
+    entity varchar not null,
+    entity_id bigint not null,
+    operation varchar not null,
+    before jsonb,
+    after jsonb
+);
+
+create index audit_log_entity_idx on audit_log (entity, entity_id);
+
+create rule audit_log_no_update as on update to audit_log do instead nothing;
+create rule audit_log_no_delete as on delete to audit_log do instead nothing;
@@ -0,0 +1,5 @@
+alter table accounts
+drop column version;
+
+alter table transactions
+drop column version;
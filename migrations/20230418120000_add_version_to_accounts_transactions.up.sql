@@ -0,0 +1,5 @@
+alter table accounts
+add column version int not null default 1;
+
+alter table transactions
+add column version int not null default 1;
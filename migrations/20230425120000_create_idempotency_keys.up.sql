@@ -0,0 +1,12 @@
+create table idempotency_keys (
+    user_id bigint not null references users(id) on delete cascade,
+    key varchar(255) not null,
+    request_hash varchar not null,
+    status_code int not null default 0,
+    header jsonb,
+    body bytea,
+    created_at timestamp not null default now(),
+    primary key (user_id, key)
+);
+
+create index idempotency_keys_created_at_idx on idempotency_keys (created_at);
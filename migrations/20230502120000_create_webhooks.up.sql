@@ -0,0 +1,27 @@
+create table webhooks (
+    id bigserial not null primary key,
+    user_id bigint not null references users(id) on delete cascade,
+    url varchar not null,
+    secret varchar not null,
+    events varchar[] not null,
+    created_at timestamp not null default now()
+);
+
+create index webhooks_user_id_idx on webhooks (user_id);
+
+create table webhook_deliveries (
+    id bigserial not null primary key,
+    webhook_id bigint not null references webhooks(id) on delete cascade,
+    event varchar not null,
+    payload jsonb not null,
+    status varchar not null default 'pending',
+    attempts int not null default 0,
+    next_attempt_at timestamp not null default now(),
+    last_status_code int,
+    last_error varchar not null default '',
+    created_at timestamp not null default now(),
+    delivered_at timestamp
+);
+
+create index webhook_deliveries_due_idx on webhook_deliveries (next_attempt_at) where status = 'pending';
+create index webhook_deliveries_webhook_id_idx on webhook_deliveries (webhook_id);
@@ -0,0 +1 @@
+drop table anomaly_settings;
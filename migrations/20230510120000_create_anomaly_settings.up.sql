@@ -0,0 +1,7 @@
+create table anomaly_settings (
+    user_id bigint not null primary key references users(id) on delete cascade,
+    lookback_periods int not null,
+    category_threshold numeric(6, 2) not null,
+    min_change_percent numeric(8, 2) not null,
+    transaction_threshold numeric(6, 2) not null
+);
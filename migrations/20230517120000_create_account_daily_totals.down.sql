@@ -0,0 +1 @@
+drop table account_daily_totals;
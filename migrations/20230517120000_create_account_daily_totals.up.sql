@@ -0,0 +1,20 @@
+create table account_daily_totals (
+    account_id bigint not null references accounts(id) on delete cascade,
+    day date not null,
+    transaction_type varchar not null,
+    inflow numeric(16, 3) not null default 0,
+    outflow numeric(16, 3) not null default 0,
+    transactions int not null default 0,
+    primary key (account_id, day, transaction_type)
+);
+
+insert into account_daily_totals (account_id, day, transaction_type, inflow, outflow, transactions)
+select account_id, day, transaction_type, sum(inflow), sum(outflow), count(*)
+from (
+    select destination account_id, transaction_date day, type transaction_type, amount inflow, 0 outflow
+    from transactions where deleted_at is null
+    union all
+    select source, transaction_date, type, 0, amount
+    from transactions where deleted_at is null
+) flows
+group by account_id, day, transaction_type;
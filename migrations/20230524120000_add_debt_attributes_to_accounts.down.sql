@@ -0,0 +1,6 @@
+alter table accounts
+    drop column credit_limit,
+    drop column apr,
+    drop column minimum_payment,
+    drop column due_day,
+    drop column interest_accrued_through;
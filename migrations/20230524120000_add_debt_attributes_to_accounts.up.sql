@@ -0,0 +1,7 @@
+alter table accounts
+    add column credit_limit numeric(11, 3) not null default 0,
+    add column apr numeric(6, 3) not null default 0,
+    add column minimum_payment numeric(11, 3) not null default 0,
+    add column due_day int not null default 0,
+    add column interest_accrued_through date not null
+        default (date_trunc('month', current_date) - interval '1 day')::date;
@@ -0,0 +1,11 @@
+create table goals (
+    id bigserial not null primary key,
+    account_id bigint not null references accounts(id) on delete cascade,
+    name varchar not null,
+    target_amount numeric(12, 2) not null,
+    target_date date not null,
+    monthly_contribution numeric(12, 2) not null default 0,
+    created_at timestamp not null default now()
+);
+
+create index goals_account_id_idx on goals (account_id);
@@ -0,0 +1,2 @@
+alter table transactions
+drop column tags;
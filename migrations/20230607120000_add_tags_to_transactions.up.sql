@@ -0,0 +1,2 @@
+alter table transactions
+add column tags varchar[] not null default '{}';
@@ -0,0 +1,17 @@
+create table rules (
+    id bigserial not null primary key,
+    user_id bigint not null references users(id) on delete cascade,
+    name varchar not null,
+    priority int not null default 0,
+    description_contains varchar not null default '',
+    description_regexp varchar not null default '',
+    amount_min numeric(12, 2),
+    amount_max numeric(12, 2),
+    source_id bigint references accounts(id) on delete cascade,
+    destination_id bigint references accounts(id) on delete cascade,
+    transaction_type varchar not null default '',
+    tags varchar[] not null default '{}',
+    created_at timestamp not null default now()
+);
+
+create index rules_user_id_idx on rules (user_id, priority);
@@ -0,0 +1 @@
+drop table duplicate_candidates;
@@ -0,0 +1,13 @@
+create table duplicate_candidates (
+    id bigserial not null primary key,
+    transaction_id bigint not null references transactions(id) on delete cascade,
+    duplicate_of_id bigint not null references transactions(id) on delete cascade,
+    score numeric(3, 2) not null,
+    status varchar not null default 'pending',
+    created_at timestamp not null default now(),
+    resolved_at timestamp,
+    unique (transaction_id, duplicate_of_id)
+);
+
+create index duplicate_candidates_pending_idx on duplicate_candidates (transaction_id) where status = 'pending';
+create index duplicate_candidates_duplicate_of_id_idx on duplicate_candidates (duplicate_of_id);
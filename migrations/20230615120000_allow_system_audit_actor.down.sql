@@ -0,0 +1 @@
+alter table audit_log alter column actor_id set not null;